@@ -77,10 +77,12 @@ type testRunner struct {
 }
 
 type testGroup struct {
-	Disable bool
-	Dir     string
-	Modules []string
-	Tests   []string
+	Disable     bool
+	Dir         string
+	Modules     []string
+	Tests       []string
+	Overflow    string // Overflow mode, defaults to wrap
+	BoundsCheck string // on or off, defaults to on
 }
 
 type testResult struct {
@@ -226,7 +228,7 @@ func (t *testRunner) runTestGroups(groups []*testGroup) {
 			line := toTestLine(testName, testIndex, t.total)
 			fmt.Printf("test %s ... ", line)
 
-			result := t.runTest(testName, testDir, testFile, group.Modules)
+			result := t.runTest(testName, testDir, testFile, group)
 			t.updateStats(result.status)
 			testIndex++
 
@@ -251,10 +253,10 @@ func (t *testRunner) updateStats(res status) {
 	}
 }
 
-func (t *testRunner) runTest(testName string, testDir string, testFile string, testModules []string) *testResult {
+func (t *testRunner) runTest(testName string, testDir string, testFile string, group *testGroup) *testResult {
 	var filenames []string
 	filenames = append(filenames, filepath.Join(t.baseDir, testDir, testFile))
-	for _, mod := range testModules {
+	for _, mod := range group.Modules {
 		filename := filepath.Join(t.baseDir, testDir, mod)
 		filenames = append(filenames, filename)
 	}
@@ -270,6 +272,27 @@ func (t *testRunner) runTest(testName string, testDir string, testFile string, t
 	var expectedExeOutput []*testOutputPattern
 
 	result := &testResult{status: statusSuccess}
+
+	if len(group.Overflow) > 0 {
+		mode, ok := common.ParseOverflowMode(group.Overflow)
+		if !ok {
+			result.status = statusInvalid
+			result.addReason("invalid overflow mode '%s'", group.Overflow)
+			return result
+		}
+		ctx.Overflow = mode
+	}
+
+	switch group.BoundsCheck {
+	case "", "on":
+	case "off":
+		ctx.BoundsCheck = false
+	default:
+		result.status = statusInvalid
+		result.addReason("invalid bounds check '%s'", group.BoundsCheck)
+		return result
+	}
+
 	fileMatrix, _ := frontend.Load(ctx, filenames)
 
 	if fileMatrix != nil {
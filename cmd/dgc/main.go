@@ -27,8 +27,27 @@ func main() {
 	flag.StringVar(&ctx.Exe, "exe", "dgexe", "Name of executable")
 	flag.BoolVar(&ctx.Verbose, "verbose", false, "Print compilation info")
 	flag.BoolVar(&ctx.LLVMIR, "dump-llvm-ir", false, "Print LLVM IR")
+	boundsCheck := flag.String("bounds-check", "on", "Check index and slice expressions at runtime (on|off)")
+	overflow := flag.String("overflow", "wrap", "Behavior of integer overflow at runtime (wrap|trap|panic)")
 	flag.Parse()
 
+	switch *boundsCheck {
+	case "on":
+		ctx.BoundsCheck = true
+	case "off":
+		ctx.BoundsCheck = false
+	default:
+		fmt.Printf("%s: invalid value '%s' for -bounds-check (expected on or off)\n", common.BoldRed(common.ErrorMsg.String()), *boundsCheck)
+		os.Exit(1)
+	}
+
+	if mode, ok := common.ParseOverflowMode(*overflow); ok {
+		ctx.Overflow = mode
+	} else {
+		fmt.Printf("%s: invalid value '%s' for -overflow (expected wrap, trap or panic)\n", common.BoldRed(common.ErrorMsg.String()), *overflow)
+		os.Exit(1)
+	}
+
 	if len(flag.Args()) == 0 {
 		fmt.Printf("%s: no input files\n", common.BoldRed(common.ErrorMsg.String()))
 		os.Exit(0)
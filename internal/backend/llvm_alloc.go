@@ -0,0 +1,133 @@
+package backend
+
+import (
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Memory is allocated by new and make with the alloc handler of the program if it has one, otherwise with
+// malloc, or aligned_alloc if the type is aligned beyond what malloc guarantees. Likewise, delete frees
+// memory with the free handler or free. The program panics if an allocation fails, since the result of
+// new and make can't be null.
+
+func (cb *llvmCodeBuilder) buildNewExpr(expr *ir.NewExpr) llvm.Value {
+	tptr := ir.ToBaseType(expr.T).(*ir.PointerType)
+	telem := cb.llvmType(tptr.Elem)
+
+	var init llvm.Value
+	if expr.Init != nil {
+		init = cb.buildExprVal(expr.Init)
+	} else {
+		init = cb.buildDefaultInit(tptr.Elem)
+	}
+
+	size := llvm.ConstInt(llvmSizeType(), cb.target.data.TypeAllocSize(telem), false)
+	mem := cb.createAlloc(size, cb.target.alignof(tptr.Elem, &cb.typeMap), expr.Pos())
+	ptr := cb.b.CreateBitCast(mem, llvm.PointerType(telem, 0), "")
+	cb.b.CreateStore(init, ptr)
+	return ptr
+}
+
+func (cb *llvmCodeBuilder) buildMakeExpr(expr *ir.MakeExpr) llvm.Value {
+	tslice := ir.ToBaseType(expr.T).(*ir.SliceType)
+	telem := cb.llvmType(tslice.Elem)
+
+	tcount := expr.Size.Type()
+	count := cb.createPointerIndex(cb.buildExprVal(expr.Size), tcount)
+	if ir.IsSignedType(tcount) {
+		positive := cb.b.CreateICmp(llvm.IntSGE, count, llvm.ConstInt(llvmSizeType(), 0, false), "")
+		cb.createRuntimeCheck(positive, expr.Pos(), "negative size")
+	}
+
+	elemSize := llvm.ConstInt(llvmSizeType(), cb.target.data.TypeAllocSize(telem), false)
+	size, overflow := cb.createOverflowOp(token.Mul, ir.TBuiltinUSize, count, elemSize)
+	cb.createRuntimeCheck(cb.b.CreateNot(overflow, ""), expr.Pos(), "allocation size overflow")
+
+	mem := cb.createAlloc(size, cb.target.alignof(tslice.Elem, &cb.typeMap), expr.Pos())
+	ptr := cb.b.CreateBitCast(mem, llvm.PointerType(telem, 0), "")
+	cb.createInitLoop(ptr, count, tslice.Elem, expr.Pos())
+	return cb.createSliceStruct(ptr, count, tslice)
+}
+
+// mallocAlign is the alignment guaranteed by malloc on the supported targets.
+const mallocAlign = 16
+
+// createAlloc allocates size bytes with the given alignment, and panics if the allocation fails.
+// Zero-sized allocations are allowed to return null.
+func (cb *llvmCodeBuilder) createAlloc(size llvm.Value, align int, pos token.Position) llvm.Value {
+	var mem llvm.Value
+	if handler := cb.runtimeHandlerFunc(ir.AttrAllocHandler); !handler.IsNil() {
+		alignVal := llvm.ConstInt(llvmSizeType(), uint64(align), false)
+		mem = cb.b.CreateCall(handler, []llvm.Value{size, alignVal}, "")
+	} else if align > mallocAlign {
+		// The size passed to aligned_alloc must be a multiple of the alignment
+		mask := llvm.ConstInt(llvmSizeType(), uint64(align-1), false)
+		padded, overflow := cb.createOverflowOp(token.Add, ir.TBuiltinUSize, size, mask)
+		cb.createRuntimeCheck(cb.b.CreateNot(overflow, ""), pos, "allocation size overflow")
+		rounded := cb.b.CreateAnd(padded, cb.b.CreateNot(mask, ""), "")
+		alignVal := llvm.ConstInt(llvmSizeType(), uint64(align), false)
+		mem = cb.b.CreateCall(cb.alignedAllocFunc(), []llvm.Value{alignVal, rounded}, "")
+	} else {
+		mem = cb.b.CreateCall(cb.mallocFunc(), []llvm.Value{size}, "")
+	}
+	empty := cb.b.CreateICmp(llvm.IntEQ, size, llvm.ConstInt(llvmSizeType(), 0, false), "")
+	ok := cb.b.CreateOr(cb.b.CreateIsNotNull(mem, ""), empty, "")
+	cb.createRuntimeCheck(ok, pos, "out of memory")
+	return mem
+}
+
+// The elements are initialized in a loop since default values aren't necessarily zero.
+func (cb *llvmCodeBuilder) createInitLoop(ptr llvm.Value, count llvm.Value, telem ir.Type, pos token.Position) {
+	entryBlock := cb.b.GetInsertBlock()
+	loopBlock := llvm.AddBasicBlock(cb.fun, formatLabel("make.loop", pos))
+	bodyBlock := llvm.AddBasicBlock(cb.fun, formatLabel("make.body", pos))
+	endBlock := llvm.AddBasicBlock(cb.fun, formatLabel("make.end", pos))
+	cb.b.CreateBr(loopBlock)
+
+	loopBlock.MoveAfter(entryBlock)
+	cb.b.SetInsertPointAtEnd(loopBlock)
+	index := cb.b.CreatePHI(llvmSizeType(), "")
+	cb.b.CreateCondBr(cb.b.CreateICmp(llvm.IntULT, index, count, ""), bodyBlock, endBlock)
+
+	bodyBlock.MoveAfter(loopBlock)
+	cb.b.SetInsertPointAtEnd(bodyBlock)
+	elem := cb.b.CreateGEP(ptr, []llvm.Value{index}, "")
+	cb.b.CreateStore(cb.buildDefaultInit(telem), elem)
+	next := cb.b.CreateAdd(index, llvm.ConstInt(llvmSizeType(), 1, false), "")
+	cb.b.CreateBr(loopBlock)
+
+	zero := llvm.ConstInt(llvmSizeType(), 0, false)
+	index.AddIncoming([]llvm.Value{zero, next}, []llvm.BasicBlock{entryBlock, cb.b.GetInsertBlock()})
+
+	endBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(endBlock)
+}
+
+// Deleting a closure frees its environment, which is null if the closure doesn't capture anything.
+func (cb *llvmCodeBuilder) buildDeleteExpr(expr *ir.DeleteExpr) llvm.Value {
+	ptr := cb.buildExprVal(expr.X)
+	switch ir.ToBaseType(expr.X.Type()).(type) {
+	case *ir.SliceType:
+		ptr = cb.b.CreateExtractValue(ptr, ptrFieldIndex, "")
+	case *ir.ClosureType:
+		ptr = cb.b.CreateExtractValue(ptr, closureEnvIndex, "")
+	}
+	mem := cb.b.CreateBitCast(ptr, llvm.PointerType(llvm.Int8Type(), 0), "")
+	if handler := cb.runtimeHandlerFunc(ir.AttrFreeHandler); !handler.IsNil() {
+		return cb.b.CreateCall(handler, []llvm.Value{mem}, "")
+	}
+	return cb.b.CreateCall(cb.freeFunc(), []llvm.Value{mem}, "")
+}
+
+func (cb *llvmCodeBuilder) freeFunc() llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	tfree := llvm.FunctionType(llvm.VoidType(), []llvm.Type{tptr}, false)
+	return cb.cFunc("free", tfree)
+}
+
+func (cb *llvmCodeBuilder) alignedAllocFunc() llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	talloc := llvm.FunctionType(tptr, []llvm.Type{llvmSizeType(), llvmSizeType()}, false)
+	return cb.cFunc("aligned_alloc", talloc)
+}
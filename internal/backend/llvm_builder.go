@@ -22,11 +22,13 @@ type llvmCodeBuilder struct {
 	target          *llvmTarget
 	objectFiles     []string
 	externalNameMap map[string]*ir.Symbol
+	handlers        map[string]*ir.Symbol // Runtime handlers of the program by attribute
 
 	mod        llvm.Module
 	declList   *ir.DeclList
 	valueMap   map[ir.SymbolKey]llvm.Value
 	typeMap    llvmTypeMap
+	fieldMap   map[ir.SymbolKey][]int // Index of each struct field in the LLVM body
 	signature  bool
 	inFunction bool
 
@@ -34,17 +36,30 @@ type llvmCodeBuilder struct {
 	fun           llvm.Value
 	retValue      llvm.Value
 	retBlock      llvm.BasicBlock
+	errFlag       llvm.Value // True if the function returns an error
 	loops         []*loopContext
 	defers        []*deferContext
 	ifMergeBlocks []llvm.BasicBlock
 }
 
 type loopContext struct {
+	label     string
 	condBlock llvm.BasicBlock
 	exitBlock llvm.BasicBlock
 	level     int
 }
 
+// rangeLoop is the state of a range-based loop. The counter is the index of the element,
+// or the current integer in an integer range.
+type rangeLoop struct {
+	r        *ir.RangeClause
+	counter  llvm.Value
+	tcounter ir.Type
+	end      llvm.Value
+	base     llvm.Value // Pointer to the array or to the first element of the slice
+	array    bool
+}
+
 type deferContext struct {
 	headerBlock        llvm.BasicBlock
 	mainBlock          llvm.BasicBlock
@@ -61,6 +76,7 @@ type deferStmt struct {
 	stmt  ir.Stmt
 	block llvm.BasicBlock
 	br    bool
+	err   bool // Only run if the function returns an error
 }
 
 func (ctx *deferContext) topStmt() *deferStmt {
@@ -83,6 +99,23 @@ func (ctx *deferContext) stmtBrBlock() llvm.BasicBlock {
 const ptrFieldIndex = 0
 const lenFieldIndex = 1
 
+// Field indexes for union struct.
+const unionTagIndex = 0
+const unionPayloadIndex = 1
+
+// Field indexes for closure struct.
+const closureFunIndex = 0
+const closureEnvIndex = 1
+
+// Field indexes for interface struct.
+const interfaceDataIndex = 0
+const interfaceTableIndex = 1
+
+// Field indexes for result struct.
+const resultOkIndex = 0
+const resultValueIndex = 1
+const resultErrorIndex = 2
+
 // BuildLLVM code.
 func BuildLLVM(ctx *common.BuildContext, target ir.Target, matrix ir.DeclMatrix) bool {
 	ctx.SetCheckpoint()
@@ -96,6 +129,8 @@ func BuildLLVM(ctx *common.BuildContext, target ir.Target, matrix ir.DeclMatrix)
 		return false
 	}
 
+	cb.handlers = findRuntimeHandlers(matrix)
+
 	defer cb.deleteObjects()
 
 	for _, list := range matrix {
@@ -131,7 +166,7 @@ func (cb *llvmCodeBuilder) addExternalNameEntries(matrix ir.DeclMatrix) {
 	for _, list := range matrix {
 		for _, decl := range list.Decls {
 			sym := decl.Symbol()
-			if isExternalLLVMLinkage(sym) && sym.IsDefined() &&
+			if isExternalLLVMLinkage(sym) && sym.IsDefined() && !sym.IsField() &&
 				(sym.Kind == ir.FuncSymbol || sym.Kind == ir.ValSymbol) {
 				name := cb.mangle(sym)
 				if existing, ok := cb.externalNameMap[name]; ok {
@@ -198,16 +233,30 @@ func (cb *llvmCodeBuilder) buildLLVModule(list *ir.DeclList) bool {
 	cb.declList = list
 	cb.valueMap = make(map[ir.SymbolKey]llvm.Value)
 	cb.typeMap = make(llvmTypeMap)
+	cb.fieldMap = make(map[ir.SymbolKey][]int)
 	cb.inFunction = false
 	cb.signature = true
 	cb.buildIntrinsics()
 	for _, decl := range list.Decls {
+		sym := decl.Symbol()
+		if sym.Kind == ir.FuncSymbol && sym.CUID != list.CUID && !isExternalLLVMLinkage(sym) {
+			// Private functions in other compilation units are only
+			// dependencies of other functions and can't be referenced here
+			continue
+		}
 		cb.buildDecl(decl)
 	}
 	cb.signature = false
+	// Type bodies must be complete before they are used in function bodies,
+	// which is not guaranteed by the dependency order since calls are weak dependencies.
+	for _, decl := range list.Decls {
+		if decl.Symbol().Kind == ir.TypeSymbol {
+			cb.buildDecl(decl)
+		}
+	}
 	for _, decl := range list.Decls {
 		sym := decl.Symbol()
-		if sym.Kind == ir.TypeSymbol || sym.CUID == list.CUID {
+		if sym.Kind != ir.TypeSymbol && sym.CUID == list.CUID {
 			cb.buildDecl(decl)
 		}
 	}
@@ -252,7 +301,7 @@ func (cb *llvmCodeBuilder) finalizeLLVModule(list *ir.DeclList) bool {
 }
 
 func (cb *llvmCodeBuilder) llvmType(t ir.Type) llvm.Type {
-	return llvmType(t, &cb.typeMap)
+	return cb.target.llvmType(t, &cb.typeMap)
 }
 
 func (cb *llvmCodeBuilder) mangle(sym *ir.Symbol) string {
@@ -290,6 +339,11 @@ func (cb *llvmCodeBuilder) buildDecl(decl ir.Decl) {
 		cb.buildFuncDecl(decl)
 	case *ir.StructDecl:
 		cb.buildStructDecl(decl)
+	case *ir.UnionDecl:
+		cb.buildUnionDecl(decl)
+	case *ir.EnumDecl:
+	case *ir.InterfaceDecl:
+	case *ir.StaticAssertDecl:
 	default:
 		panic(fmt.Sprintf("Unhandled decl %T", decl))
 	}
@@ -307,17 +361,27 @@ func (cb *llvmCodeBuilder) buildValTopDecl(decl *ir.ValDecl) {
 	init := cb.buildExprVal(decl.Initializer)
 	loc := cb.valueMap[sym.Key]
 	loc.SetInitializer(init)
+	cb.setAlignment(loc, sym.T)
 }
 
 func (cb *llvmCodeBuilder) buildValDecl(decl *ir.ValDecl) {
 	sym := decl.Sym
 	loc := cb.b.CreateAlloca(cb.llvmType(sym.T), sym.Name)
+	cb.setAlignment(loc, sym.T)
 	cb.valueMap[decl.Sym.Key] = loc
 
 	init := cb.buildExprVal(decl.Initializer)
 	cb.b.CreateStore(init, loc)
 }
 
+// setAlignment raises the alignment of a variable if its type requires
+// a stricter alignment than the LLVM type.
+func (cb *llvmCodeBuilder) setAlignment(loc llvm.Value, t ir.Type) {
+	if align := cb.target.alignof(t, &cb.typeMap); align > cb.target.data.ABITypeAlignment(loc.Type().ElementType()) {
+		loc.SetAlignment(align)
+	}
+}
+
 func (cb *llvmCodeBuilder) buildFuncDecl(decl *ir.FuncDecl) {
 	name := mangle(decl.Sym)
 	fun := cb.mod.NamedFunction(name)
@@ -329,16 +393,20 @@ func (cb *llvmCodeBuilder) buildFuncDecl(decl *ir.FuncDecl) {
 			return
 		}
 
-		var paramTypes []llvm.Type
-		for _, p := range tfun.Params {
-			paramTypes = append(paramTypes, cb.llvmType(p.T))
+		var funType llvm.Type
+		if len(decl.Captures) > 0 {
+			funType = cb.target.llvmEnvFuncType(tfun, &cb.typeMap).ElementType()
+		} else {
+			funType = cb.llvmType(tfun).ElementType()
 		}
-		retType := cb.llvmType(tfun.Return)
-
-		funType := llvm.FunctionType(retType, paramTypes, false)
 		fun = llvm.AddFunction(cb.mod, name, funType)
 
 		fun.SetLinkage(llvmLinkage(decl.Sym))
+		for _, attr := range decl.Sym.Attributes {
+			if kind, ok := llvmFuncAttributes[attr.Name.Literal]; ok {
+				fun.AddFunctionAttr(cb.llvmEnumAttribute(kind, 0))
+			}
+		}
 		return
 	} else if decl.SignatureOnly() {
 		return
@@ -351,7 +419,25 @@ func (cb *llvmCodeBuilder) buildFuncDecl(decl *ir.FuncDecl) {
 		cb.retValue = cb.b.CreateAlloca(cb.llvmType(tfun.Return), ".retval")
 	}
 
-	for i, p := range fun.Params() {
+	cb.errFlag = llvm.Value{}
+	tres, result := ir.ToBaseType(tfun.Return).(*ir.ResultType)
+	if result {
+		cb.errFlag = cb.b.CreateAlloca(llvm.Int1Type(), ".errflag")
+		cb.b.CreateStore(llvm.ConstInt(llvm.Int1Type(), 0, false), cb.errFlag)
+	}
+
+	params := fun.Params()
+	if len(decl.Captures) > 0 {
+		// Captured variables are accessed directly in the environment
+		tenv := llvm.PointerType(cb.llvmEnvType(decl), 0)
+		env := cb.b.CreateBitCast(params[0], tenv, ".env")
+		for i, capture := range decl.Captures {
+			cb.valueMap[capture.Inner.Key] = cb.b.CreateStructGEP(env, i, capture.Inner.Name)
+		}
+		params = params[1:]
+	}
+
+	for i, p := range params {
 		sym := decl.Params[i].Sym
 		loc := cb.b.CreateAlloca(p.Type(), sym.Name)
 		cb.b.CreateStore(p, loc)
@@ -368,6 +454,13 @@ func (cb *llvmCodeBuilder) buildFuncDecl(decl *ir.FuncDecl) {
 	cb.inFunction = false
 
 	if !terminated {
+		if result && tres.T.Kind() == ir.TVoid {
+			// A function which returns a result without a value is ok if it doesn't return explicitly
+			ok := llvm.ConstInt(llvm.Int1Type(), 1, false)
+			res := cb.b.CreateInsertValue(llvm.ConstNull(cb.llvmType(tres)), ok, resultOkIndex, "")
+			cb.b.CreateStore(res, cb.retValue)
+			terminated = true
+		}
 		cb.b.CreateBr(retBlock)
 	}
 
@@ -397,14 +490,33 @@ func (cb *llvmCodeBuilder) buildStructDecl(decl *ir.StructDecl) {
 		return
 	}
 
-	tstruct := cb.typeMap[decl.Sym.Key]
+	tstruct := ir.ToBaseType(decl.Sym.T).(*ir.StructType)
+	layout := cb.target.llvmStructLayout(tstruct, &cb.typeMap)
+	structt := cb.typeMap[decl.Sym.Key]
+	structt.StructSetBody(layout.body, tstruct.Packed)
+	cb.fieldMap[decl.Sym.Key] = layout.indices
+}
+
+// fieldIndex returns the index of a field in the LLVM body of a struct.
+func (cb *llvmCodeBuilder) fieldIndex(t *ir.StructType, index int) int {
+	indices, ok := cb.fieldMap[t.Sym.Key]
+	if !ok {
+		indices = cb.target.llvmStructLayout(t, &cb.typeMap).indices
+		cb.fieldMap[t.Sym.Key] = indices
+	}
+	return indices[index]
+}
 
-	var types []llvm.Type
-	for _, field := range decl.Fields {
-		types = append(types, cb.llvmType(field.Sym.T))
+func (cb *llvmCodeBuilder) buildUnionDecl(decl *ir.UnionDecl) {
+	if cb.signature {
+		uniont := cb.mod.Context().StructCreateNamed(mangle(decl.Sym))
+		cb.typeMap[decl.Sym.Key] = uniont
+		return
 	}
 
-	tstruct.StructSetBody(types, false)
+	tunion := ir.ToBaseType(decl.Sym.T).(*ir.UnionType)
+	uniont := cb.typeMap[decl.Sym.Key]
+	uniont.StructSetBody(cb.target.llvmUnionBody(tunion, &cb.typeMap), false)
 }
 
 func (cb *llvmCodeBuilder) buildStmt(stmt ir.Stmt) bool {
@@ -414,26 +526,39 @@ func (cb *llvmCodeBuilder) buildStmt(stmt ir.Stmt) bool {
 		terminate = cb.buildBlockStmt(stmt2, true)
 	case *ir.DeclStmt:
 		cb.buildDecl(stmt2.D)
+	case *ir.DestructureStmt:
+		cb.buildValDecl(stmt2.Tuple)
+		for _, elem := range stmt2.Elems {
+			cb.buildValDecl(elem)
+		}
 	case *ir.IfStmt:
 		terminate = cb.buildIfStmt(stmt2)
+	case *ir.MatchStmt:
+		terminate = cb.buildMatchStmt(stmt2)
 	case *ir.ForStmt:
 		cb.buildForStmt(stmt2)
 	case *ir.AssignStmt:
 		cb.buildAssignStmt(stmt2)
 	case *ir.ExprStmt:
 		cb.buildExprVal(stmt2.X)
+	case *ir.PanicStmt:
+		cb.buildPanicStmt(stmt2)
+		terminate = true
+	case *ir.AssertStmt:
+		cb.buildAssertStmt(stmt2)
+	case *ir.StaticAssertStmt:
 	default:
 		panic(fmt.Sprintf("Unhandled stmt %T at %s", stmt2, stmt2.Pos()))
 	}
 	return terminate
 }
 
-func (cb *llvmCodeBuilder) buildDeferBrTargets(branchTok token.Token) {
+func (cb *llvmCodeBuilder) buildDeferBrTargets(branchTok token.Token, loop *loopContext) {
 	if len(cb.defers) == 0 {
 		return
 	}
 
-	level := cb.branchTargetLevel(branchTok)
+	level := cb.branchTargetLevel(branchTok, loop)
 	index := len(cb.defers) - 1
 
 	if cb.defers[index].level > level {
@@ -444,7 +569,7 @@ func (cb *llvmCodeBuilder) buildDeferBrTargets(branchTok token.Token) {
 			cb.defers[index].potentialBrTargets = append(cb.defers[index].potentialBrTargets, block)
 		}
 
-		target := cb.branchTarget(branchTok)
+		target := cb.branchTarget(branchTok, loop)
 		addr := llvm.BlockAddress(cb.fun, target)
 
 		cb.b.CreateStore(addr, cb.defers[index].brTarget)
@@ -452,39 +577,49 @@ func (cb *llvmCodeBuilder) buildDeferBrTargets(branchTok token.Token) {
 	}
 }
 
-func (cb *llvmCodeBuilder) branchTargetLevel(branchTok token.Token) int {
+func (cb *llvmCodeBuilder) branchTargetLevel(branchTok token.Token, loop *loopContext) int {
 	switch branchTok {
 	case token.Return:
 		return 0
 	case token.Continue, token.Break:
-		return cb.loops[len(cb.loops)-1].level
+		return loop.level
 	default:
 		panic(fmt.Sprintf("Unhandled branch token %s", branchTok))
 	}
 }
 
-func (cb *llvmCodeBuilder) branchTarget(branchTok token.Token) llvm.BasicBlock {
+func (cb *llvmCodeBuilder) branchTarget(branchTok token.Token, loop *loopContext) llvm.BasicBlock {
 	switch branchTok {
 	case token.Return:
 		return cb.retBlock
 	case token.Continue:
-		return cb.loops[len(cb.loops)-1].condBlock
+		return loop.condBlock
 	case token.Break:
-		return cb.loops[len(cb.loops)-1].exitBlock
+		return loop.exitBlock
 	default:
 		panic(fmt.Sprintf("Unhandled branch token %s", branchTok))
 	}
 }
 
-func (cb *llvmCodeBuilder) deferOrBranchTarget(branchTok token.Token) llvm.BasicBlock {
+func (cb *llvmCodeBuilder) deferOrBranchTarget(branchTok token.Token, loop *loopContext) llvm.BasicBlock {
 	ndefers := len(cb.defers)
 	if ndefers > 0 {
-		level := cb.branchTargetLevel(branchTok)
+		level := cb.branchTargetLevel(branchTok, loop)
 		if cb.defers[ndefers-1].level > level {
 			return cb.defers[ndefers-1].stmtBrBlock()
 		}
 	}
-	return cb.branchTarget(branchTok)
+	return cb.branchTarget(branchTok, loop)
+}
+
+// branchLoop returns the loop targeted by a break or continue. An unlabeled branch targets the innermost loop.
+func (cb *llvmCodeBuilder) branchLoop(label *ir.Ident) *loopContext {
+	for i := len(cb.loops) - 1; i >= 0; i-- {
+		if label == nil || cb.loops[i].label == label.Literal {
+			return cb.loops[i]
+		}
+	}
+	panic(fmt.Sprintf("Unknown loop label %s", label.Literal))
 }
 
 func (cb *llvmCodeBuilder) saveStackAddr() llvm.Value {
@@ -529,6 +664,7 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 	}
 
 	branchTok := token.Invalid
+	var branchLoop *loopContext
 	terminate := false
 	cb.level++
 
@@ -537,16 +673,21 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 		case *ir.DeferStmt:
 			deferCtx := cb.defers[len(cb.defers)-1]
 			block := llvm.AddBasicBlock(cb.fun, formatLabel("defer.stmt", stmt.Pos()))
-			deferCtx.stmts = append(deferCtx.stmts, &deferStmt{stmt: stmt.S, block: block})
+			deferCtx.stmts = append(deferCtx.stmts, &deferStmt{stmt: stmt.S, block: block, err: stmt.Tok.Is(token.Errdefer)})
 		case *ir.ReturnStmt:
 			if stmt.X.Type().Kind() != ir.TVoid {
 				val := cb.buildExprVal(stmt.X)
 				cb.b.CreateStore(val, cb.retValue)
+				if !cb.errFlag.IsNil() {
+					ok := cb.b.CreateExtractValue(val, resultOkIndex, "")
+					cb.b.CreateStore(cb.b.CreateNot(ok, ""), cb.errFlag)
+				}
 			}
 			branchTok = token.Return
 			terminate = true
 		case *ir.BranchStmt:
 			branchTok = stmt.Tok
+			branchLoop = cb.branchLoop(stmt.Label)
 			terminate = true
 		default:
 			terminate = cb.buildStmt(stmt)
@@ -562,7 +703,7 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 	cb.level--
 
 	if branchTok != token.Invalid {
-		cb.buildDeferBrTargets(branchTok)
+		cb.buildDeferBrTargets(branchTok, branchLoop)
 	}
 
 	if blockStmt.Scope.Defer {
@@ -577,7 +718,7 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 			topStmt := deferCtx.topStmt()
 			topStmt.block.MoveAfter(cb.b.GetInsertBlock())
 			cb.b.SetInsertPointAtEnd(topStmt.block)
-			cb.buildStmt(topStmt.stmt)
+			cb.buildDeferredStmt(topStmt)
 
 			for i := len(deferCtx.stmts) - 2; i >= 0; i-- {
 				stmt := deferCtx.stmts[i]
@@ -588,7 +729,7 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 				} else {
 					stmt.block.EraseFromParent()
 				}
-				cb.buildStmt(stmt.stmt)
+				cb.buildDeferredStmt(stmt)
 			}
 
 			cb.b.CreateBr(deferCtx.footerBlock)
@@ -610,6 +751,11 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 			if restoreStack {
 				cb.restoreStackAddr(stackAddr)
 			}
+
+			if terminate {
+				// The block was terminated by a panic, so the footer is never reached
+				cb.b.CreateUnreachable()
+			}
 		} else {
 			cb.b.CreateBr(deferCtx.headerBlock)
 			deferCtx.mainBlock.MoveAfter(deferCtx.headerBlock)
@@ -645,17 +791,39 @@ func (cb *llvmCodeBuilder) buildBlockStmt(blockStmt *ir.BlockStmt, restoreStack
 			}
 		}
 	} else {
-		if restoreStack {
+		if restoreStack && (!terminate || branchTok != token.Invalid) {
 			cb.restoreStackAddr(stackAddr)
 		}
 		if branchTok != token.Invalid {
-			cb.b.CreateBr(cb.deferOrBranchTarget(branchTok))
+			cb.b.CreateBr(cb.deferOrBranchTarget(branchTok, branchLoop))
 		}
 	}
 
 	return terminate
 }
 
+// buildDeferredStmt builds a deferred statement. An errdefer statement is skipped unless the function returns an error.
+func (cb *llvmCodeBuilder) buildDeferredStmt(stmt *deferStmt) {
+	if !stmt.err {
+		cb.buildStmt(stmt.stmt)
+		return
+	}
+
+	errBlock := llvm.AddBasicBlock(cb.fun, formatLabel("errdefer.err", stmt.stmt.Pos()))
+	nextBlock := llvm.AddBasicBlock(cb.fun, formatLabel("errdefer.next", stmt.stmt.Pos()))
+
+	flag := cb.b.CreateLoad(cb.errFlag, "")
+	cb.b.CreateCondBr(flag, errBlock, nextBlock)
+
+	errBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(errBlock)
+	cb.buildStmt(stmt.stmt)
+	cb.b.CreateBr(nextBlock)
+
+	nextBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(nextBlock)
+}
+
 func formatTokLabel(tok token.Token, name string, pos token.Position) string {
 	return formatLabel(fmt.Sprintf("%s.%s", tok, name), pos)
 }
@@ -732,6 +900,92 @@ func (cb *llvmCodeBuilder) buildIfStmt(stmt *ir.IfStmt) bool {
 	return terminate
 }
 
+func (cb *llvmCodeBuilder) buildMatchStmt(stmt *ir.MatchStmt) bool {
+	var cond llvm.Value
+	var payloadPtr llvm.Value
+	tunion, isUnion := ir.ToBaseType(stmt.X.Type()).(*ir.UnionType)
+
+	if isUnion {
+		loc := cb.buildExprPtr(stmt.X)
+		if loc.Type().TypeKind() != llvm.PointerTypeKind {
+			loc = cb.createTempStorage(loc)
+		}
+		tagPtr := cb.b.CreateStructGEP(loc, unionTagIndex, "")
+		cond = cb.b.CreateLoad(tagPtr, "")
+		if loc.Type().ElementType().StructElementTypesCount() > unionPayloadIndex {
+			payloadPtr = cb.b.CreateStructGEP(loc, unionPayloadIndex, "")
+		}
+	} else {
+		cond = cb.buildExprVal(stmt.X)
+	}
+
+	mergeBlock := llvm.AddBasicBlock(cb.fun, formatLabel("match.merge", stmt.EndPos()))
+	elseBlock := mergeBlock
+	if stmt.Else != nil {
+		elseBlock = llvm.AddBasicBlock(cb.fun, formatLabel("match.else", stmt.Else.Pos()))
+	}
+
+	ncases := 0
+	for _, arm := range stmt.Arms {
+		ncases += len(arm.Patterns)
+	}
+
+	switchVal := cb.b.CreateSwitch(cond, elseBlock, ncases)
+	terminate := true
+
+	for _, arm := range stmt.Arms {
+		armBlock := llvm.AddBasicBlock(cb.fun, formatLabel("match.arm", arm.Pos()))
+		for _, pattern := range arm.Patterns {
+			if lit, ok := pattern.(*ir.UnionLit); ok {
+				switchVal.AddCase(llvm.ConstInt(llvmUnionTagType(), uint64(lit.Tag), false), armBlock)
+			} else {
+				switchVal.AddCase(cb.buildExprVal(pattern), armBlock)
+			}
+		}
+
+		armBlock.MoveAfter(cb.b.GetInsertBlock())
+		cb.b.SetInsertPointAtEnd(armBlock)
+
+		if len(arm.Bindings) > 0 {
+			tag := arm.Patterns[0].(*ir.UnionLit).Tag
+			variantType := cb.target.llvmVariantType(tunion.Variants[tag], &cb.typeMap)
+			variantPtr := cb.b.CreateBitCast(payloadPtr, llvm.PointerType(variantType, 0), "")
+			for _, binding := range arm.Bindings {
+				sym := binding.Name.Sym
+				fieldPtr := cb.b.CreateStructGEP(variantPtr, binding.Field, "")
+				loc := cb.b.CreateAlloca(cb.llvmType(sym.T), sym.Name)
+				cb.b.CreateStore(cb.b.CreateLoad(fieldPtr, ""), loc)
+				cb.valueMap[sym.Key] = loc
+			}
+		}
+
+		if !cb.buildBlockStmt(arm.Body, true) {
+			cb.b.CreateBr(mergeBlock)
+			terminate = false
+		}
+	}
+
+	if stmt.Else != nil {
+		elseBlock.MoveAfter(cb.b.GetInsertBlock())
+		cb.b.SetInsertPointAtEnd(elseBlock)
+		if !cb.buildBlockStmt(stmt.Else, true) {
+			cb.b.CreateBr(mergeBlock)
+			terminate = false
+		}
+	} else {
+		terminate = false
+	}
+
+	mergeBlock.MoveAfter(cb.b.GetInsertBlock())
+	if terminate {
+		mergeBlock.EraseFromParent()
+	} else {
+		cb.b.SetInsertPointAtEnd(mergeBlock)
+	}
+
+	return terminate
+}
+
 func (cb *llvmCodeBuilder) buildForStmt(stmt *ir.ForStmt) {
 	stackAddr := cb.saveStackAddr()
 	defer cb.restoreStackAddr(stackAddr)
@@ -740,25 +994,35 @@ func (cb *llvmCodeBuilder) buildForStmt(stmt *ir.ForStmt) {
 		cb.buildStmt(stmt.Init)
 	}
 
+	var rng *rangeLoop
+	if stmt.Range != nil {
+		rng = cb.buildRangeInit(stmt.Range)
+	}
+	hasInc := stmt.Inc != nil || rng != nil
+	hasCond := stmt.Cond != nil || rng != nil
+
 	loopBlock := llvm.AddBasicBlock(cb.fun, formatTokLabel(stmt.Tok, "body", stmt.Pos()))
 	exitBlock := llvm.AddBasicBlock(cb.fun, formatTokLabel(stmt.Tok, "exit", stmt.EndPos()))
 
 	var incBlock llvm.BasicBlock
-	if stmt.Inc != nil {
+	if hasInc {
 		incBlock = llvm.AddBasicBlock(cb.fun, formatTokLabel(stmt.Tok, "inc", stmt.Pos()))
 	}
 
 	var condBlock llvm.BasicBlock
-	if stmt.Cond != nil {
+	if hasCond {
 		condBlock = llvm.AddBasicBlock(cb.fun, formatTokLabel(stmt.Tok, "cond", stmt.Pos()))
 	}
 
 	loopCtx := &loopContext{}
 	loopCtx.level = cb.level
+	if stmt.Label != nil {
+		loopCtx.label = stmt.Label.Literal
+	}
 
-	if stmt.Inc != nil {
+	if hasInc {
 		loopCtx.condBlock = incBlock
-	} else if stmt.Cond != nil {
+	} else if hasCond {
 		loopCtx.condBlock = condBlock
 	} else {
 		loopCtx.condBlock = loopBlock
@@ -767,10 +1031,15 @@ func (cb *llvmCodeBuilder) buildForStmt(stmt *ir.ForStmt) {
 	loopCtx.exitBlock = exitBlock
 	cb.loops = append(cb.loops, loopCtx)
 
-	if stmt.Cond != nil {
+	if hasCond {
 		cb.b.CreateBr(condBlock)
 		cb.b.SetInsertPointAtEnd(condBlock)
-		cond := cb.buildExprVal(stmt.Cond)
+		var cond llvm.Value
+		if rng != nil {
+			cond = cb.buildRangeCond(rng)
+		} else {
+			cond = cb.buildExprVal(stmt.Cond)
+		}
 		cb.b.CreateCondBr(cond, loopBlock, exitBlock)
 	} else {
 		cb.b.CreateBr(loopBlock)
@@ -779,17 +1048,24 @@ func (cb *llvmCodeBuilder) buildForStmt(stmt *ir.ForStmt) {
 	last := cb.b.GetInsertBlock()
 	loopBlock.MoveAfter(last)
 	cb.b.SetInsertPointAtEnd(loopBlock)
+	if rng != nil {
+		cb.buildRangeVars(rng)
+	}
 	cb.buildBlockStmt(stmt.Body, false)
 
-	if stmt.Inc != nil {
+	if hasInc {
 		cb.b.CreateBr(incBlock)
 		last = cb.b.GetInsertBlock()
 		incBlock.MoveAfter(last)
 		cb.b.SetInsertPointAtEnd(incBlock)
-		cb.buildStmt(stmt.Inc)
+		if rng != nil {
+			cb.buildRangeInc(rng)
+		} else {
+			cb.buildStmt(stmt.Inc)
+		}
 	}
 
-	if stmt.Cond != nil {
+	if hasCond {
 		cb.b.CreateBr(condBlock)
 	} else {
 		cb.b.CreateBr(loopBlock)
@@ -802,19 +1078,105 @@ func (cb *llvmCodeBuilder) buildForStmt(stmt *ir.ForStmt) {
 	cb.loops = cb.loops[:len(cb.loops)-1]
 }
 
+// The range is evaluated once before the loop, and the loop variables are allocated
+// once and assigned at the start of every iteration.
+func (cb *llvmCodeBuilder) buildRangeInit(r *ir.RangeClause) *rangeLoop {
+	rng := &rangeLoop{r: r}
+	var start llvm.Value
+	if r.End != nil {
+		rng.tcounter = r.X.Type()
+		start = cb.buildExprVal(r.X)
+		rng.end = cb.buildExprVal(r.End)
+	} else {
+		rng.tcounter = ir.TBuiltinUSize
+		start = llvm.ConstInt(llvmSizeType(), 0, false)
+		switch t := ir.ToBaseType(r.X.Type()).(type) {
+		case *ir.ArrayType:
+			val := cb.buildExprPtr(r.X)
+			if val.Type().TypeKind() != llvm.PointerTypeKind {
+				val = cb.createTempStorage(val)
+			}
+			rng.base = val
+			rng.end = llvm.ConstInt(llvmSizeType(), uint64(t.Size), false)
+			rng.array = true
+		case *ir.SliceType:
+			val := cb.buildExprVal(r.X)
+			rng.base = cb.b.CreateExtractValue(val, ptrFieldIndex, "")
+			rng.end = cb.b.CreateExtractValue(val, lenFieldIndex, "")
+		default:
+			panic(fmt.Sprintf("Unhandled range type %s", t))
+		}
+	}
+
+	rng.counter = cb.b.CreateAlloca(cb.llvmType(rng.tcounter), ".range.counter")
+	cb.b.CreateStore(start, rng.counter)
+
+	if r.Index != nil {
+		cb.valueMap[r.Index.Sym.Key] = cb.b.CreateAlloca(cb.llvmType(r.Index.Sym.T), r.Index.Sym.Name)
+	}
+	cb.valueMap[r.Elem.Sym.Key] = cb.b.CreateAlloca(cb.llvmType(r.Elem.Sym.T), r.Elem.Sym.Name)
+
+	return rng
+}
+
+func (cb *llvmCodeBuilder) buildRangeCond(rng *rangeLoop) llvm.Value {
+	counter := cb.b.CreateLoad(rng.counter, "")
+	return cb.b.CreateICmp(intPredicate(token.Lt, rng.tcounter), counter, rng.end, "")
+}
+
+func (cb *llvmCodeBuilder) buildRangeVars(rng *rangeLoop) {
+	r := rng.r
+	counter := cb.b.CreateLoad(rng.counter, "")
+	if r.Index != nil {
+		cb.b.CreateStore(counter, cb.valueMap[r.Index.Sym.Key])
+	}
+	elem := cb.valueMap[r.Elem.Sym.Key]
+	if r.End != nil {
+		cb.b.CreateStore(counter, elem)
+		return
+	}
+	var gep llvm.Value
+	if rng.array {
+		gep = cb.b.CreateInBoundsGEP(rng.base, []llvm.Value{llvm.ConstInt(llvm.Int64Type(), 0, false), counter}, "")
+	} else {
+		gep = cb.b.CreateInBoundsGEP(rng.base, []llvm.Value{counter}, "")
+	}
+	if r.Ref {
+		cb.b.CreateStore(gep, elem)
+	} else {
+		cb.b.CreateStore(cb.b.CreateLoad(gep, ""), elem)
+	}
+}
+
+func (cb *llvmCodeBuilder) buildRangeInc(rng *rangeLoop) {
+	counter := cb.b.CreateLoad(rng.counter, "")
+	one := llvm.ConstInt(cb.llvmType(rng.tcounter), 1, false)
+	cb.b.CreateStore(cb.b.CreateAdd(counter, one, ""), rng.counter)
+}
+
 func (cb *llvmCodeBuilder) buildAssignStmt(stmt *ir.AssignStmt) {
 	loc := cb.buildExprPtr(stmt.Left)
 	val := cb.buildExprVal(stmt.Right)
 
-	switch stmt.Assign {
-	case token.AddAssign, token.SubAssign, token.MulAssign, token.DivAssign, token.ModAssign:
+	if ir.IsRawPointerType(stmt.Left.Type()) && stmt.Assign != token.Assign {
 		left := cb.b.CreateLoad(loc, "")
-		val = cb.createMathOp(stmt.Assign, stmt.Left.Type(), left, val)
+		val = cb.createPointerOffset(stmt.Assign, left, val, stmt.Right.Type())
+	} else if stmt.Assign != token.Assign {
+		left := cb.b.CreateLoad(loc, "")
+		val = cb.createMathOp(stmt.Assign, stmt.Left.Type(), left, val, stmt.Pos())
 	}
 
 	cb.b.CreateStore(val, loc)
 }
 
+// Function attributes which map directly onto LLVM attributes.
+var llvmFuncAttributes = map[string]string{
+	ir.AttrInline:   "alwaysinline",
+	ir.AttrNoInline: "noinline",
+	ir.AttrNoReturn: "noreturn",
+	ir.AttrCold:     "cold",
+}
+
 func (cb *llvmCodeBuilder) llvmEnumAttribute(name string, val uint64) llvm.Attribute {
 	kind := llvm.AttributeKindID(name)
 	ctx := llvm.GlobalContext()
@@ -842,12 +1204,25 @@ func (cb *llvmCodeBuilder) buildExpr(expr ir.Expr, load bool) llvm.Value {
 		return cb.buildBasicLit(expr)
 	case *ir.ArrayLit:
 		return cb.buildArrayLit(expr)
+	case *ir.TupleLit:
+		return cb.buildTupleLit(expr)
+	case *ir.TupleElemExpr:
+		tuple := cb.buildExprVal(expr.X)
+		return cb.b.CreateExtractValue(tuple, expr.Index, "")
+	case *ir.UnionLit:
+		return cb.buildUnionLit(expr)
 	case *ir.BinaryExpr:
 		return cb.buildBinaryExpr(expr)
 	case *ir.UnaryExpr:
 		return cb.buildUnaryExpr(expr)
 	case *ir.AddrExpr:
 		return cb.buildAddrExpr(expr)
+	case *ir.TryExpr:
+		return cb.buildTryExpr(expr)
+	case *ir.IfExpr:
+		return cb.buildIfExpr(expr)
+	case *ir.BlockExpr:
+		return cb.buildBlockExpr(expr)
 	case *ir.DerefExpr:
 		return cb.buildDerefExpr(expr, load)
 	case *ir.DotExpr:
@@ -856,12 +1231,22 @@ func (cb *llvmCodeBuilder) buildExpr(expr ir.Expr, load bool) llvm.Value {
 		return cb.buildIndexExpr(expr, load)
 	case *ir.SliceExpr:
 		return cb.buildSliceExpr(expr)
+	case *ir.FuncLit:
+		return cb.buildFuncLit(expr)
 	case *ir.AppExpr:
 		return cb.buildAppExpr(expr)
 	case *ir.CastExpr:
 		return cb.buildCastExpr(expr)
 	case *ir.LenExpr:
 		return cb.buildLenExpr(expr)
+	case *ir.OverflowExpr:
+		return cb.buildOverflowExpr(expr)
+	case *ir.NewExpr:
+		return cb.buildNewExpr(expr)
+	case *ir.MakeExpr:
+		return cb.buildMakeExpr(expr)
+	case *ir.DeleteExpr:
+		return cb.buildDeleteExpr(expr)
 	case *ir.ConstExpr:
 		return cb.buildExpr(expr.X, load)
 	case *ir.DefaultInit:
@@ -900,10 +1285,18 @@ func (cb *llvmCodeBuilder) buildDefaultInit(t ir.Type) llvm.Value {
 		}
 		panic(fmt.Sprintf("Unhandled type %T", t))
 	case *ir.StructType:
+		if t.CUnion {
+			return llvm.ConstNull(tllvm)
+		}
 		val := llvm.Undef(cb.typeMap[t.Sym.Key])
 		for i, field := range t.Fields {
-			fieldVal := cb.buildDefaultInit(field.T)
-			val = cb.b.CreateInsertValue(val, fieldVal, i, "")
+			var fieldVal llvm.Value
+			if field.Default != nil {
+				fieldVal = cb.buildExprVal(field.Default)
+			} else {
+				fieldVal = cb.buildDefaultInit(field.T)
+			}
+			val = cb.b.CreateInsertValue(val, fieldVal, cb.fieldIndex(t, i), "")
 		}
 		return val
 	case *ir.ArrayType:
@@ -913,6 +1306,22 @@ func (cb *llvmCodeBuilder) buildDefaultInit(t ir.Type) llvm.Value {
 			val = cb.b.CreateInsertValue(val, elemVal, i, "")
 		}
 		return val
+	case *ir.TupleType:
+		val := llvm.Undef(tllvm)
+		for i, elem := range t.Elems {
+			elemVal := cb.buildDefaultInit(elem)
+			val = cb.b.CreateInsertValue(val, elemVal, i, "")
+		}
+		return val
+	case *ir.UnionType:
+		return llvm.ConstNull(tllvm)
+	case *ir.EnumType:
+		// The default value is the first member, since 0 isn't necessarily a member
+		value := t.Members[0].Value
+		if value.Sign() < 0 {
+			return llvm.ConstInt(tllvm, uint64(value.Int64()), true)
+		}
+		return llvm.ConstInt(tllvm, value.Uint64(), false)
 	case *ir.PointerType:
 		return llvm.ConstPointerNull(tllvm)
 	case *ir.SliceType:
@@ -922,6 +1331,12 @@ func (cb *llvmCodeBuilder) buildDefaultInit(t ir.Type) llvm.Value {
 		return cb.createSliceStruct(ptr, size, t)
 	case *ir.FuncType:
 		return llvm.ConstPointerNull(tllvm)
+	case *ir.ClosureType:
+		return llvm.ConstNull(tllvm)
+	case *ir.InterfaceType:
+		return llvm.ConstNull(tllvm)
+	case *ir.ResultType:
+		return llvm.ConstNull(tllvm)
 	default:
 		panic(fmt.Sprintf("Unhandled type %T", t))
 	}
@@ -979,6 +1394,8 @@ func (cb *llvmCodeBuilder) buildBasicLit(expr *ir.BasicLit) llvm.Value {
 			return cb.createSliceStruct(ptr, size, t)
 		case *ir.PointerType, *ir.FuncType:
 			return llvm.ConstPointerNull(llvmType)
+		case *ir.ClosureType:
+			return llvm.ConstNull(llvmType)
 		}
 	}
 
@@ -1009,41 +1426,130 @@ func (cb *llvmCodeBuilder) buildArrayLit(expr *ir.ArrayLit) llvm.Value {
 	return arrayLit
 }
 
-func (cb *llvmCodeBuilder) createMathOp(op token.Token, t ir.Type, left llvm.Value, right llvm.Value) llvm.Value {
+func (cb *llvmCodeBuilder) buildTupleLit(expr *ir.TupleLit) llvm.Value {
+	tupleLit := llvm.Undef(cb.llvmType(expr.T))
+
+	for index, elem := range expr.Elems {
+		val := cb.buildExprVal(elem)
+		tupleLit = cb.b.CreateInsertValue(tupleLit, val, index, "")
+	}
+
+	return tupleLit
+}
+
+func (cb *llvmCodeBuilder) buildUnionLit(expr *ir.UnionLit) llvm.Value {
+	llvmType := cb.llvmType(expr.T)
+	tag := llvm.ConstInt(llvmUnionTagType(), uint64(expr.Tag), false)
+
+	if len(expr.Args) == 0 {
+		return cb.b.CreateInsertValue(llvm.ConstNull(llvmType), tag, unionTagIndex, "")
+	}
+
+	tunion := ir.ToBaseType(expr.T).(*ir.UnionType)
+	variantType := cb.target.llvmVariantType(tunion.Variants[expr.Tag], &cb.typeMap)
+	variantLit := llvm.Undef(variantType)
+
+	for argIndex, arg := range expr.Args {
+		init := cb.buildExprVal(arg.Value)
+		variantLit = cb.b.CreateInsertValue(variantLit, init, argIndex, "")
+	}
+
+	loc := cb.b.CreateAlloca(llvmType, ".union")
+	tagPtr := cb.b.CreateStructGEP(loc, unionTagIndex, "")
+	cb.b.CreateStore(tag, tagPtr)
+	payloadPtr := cb.b.CreateStructGEP(loc, unionPayloadIndex, "")
+	payloadPtr = cb.b.CreateBitCast(payloadPtr, llvm.PointerType(variantType, 0), "")
+	cb.b.CreateStore(variantLit, payloadPtr)
+
+	return cb.b.CreateLoad(loc, "")
+}
+
+// The argument of a cunion literal is stored at the start of zero-initialized storage.
+func (cb *llvmCodeBuilder) buildCUnionLit(tstruct *ir.StructType, llvmType llvm.Type, args []*ir.ArgExpr) llvm.Value {
+	if len(args) == 0 {
+		return llvm.ConstNull(llvmType)
+	}
+
+	loc := cb.createTempStorage(llvm.ConstNull(llvmType))
+	for _, arg := range args {
+		tfield := tstruct.Fields[tstruct.FieldIndex(arg.Name.Literal)].T
+		fieldPtr := cb.b.CreateBitCast(loc, llvm.PointerType(cb.llvmType(tfield), 0), "")
+		cb.b.CreateStore(cb.buildExprVal(arg.Value), fieldPtr)
+	}
+
+	return cb.b.CreateLoad(loc, "")
+}
+
+func (cb *llvmCodeBuilder) createMathOp(op token.Token, t ir.Type, left llvm.Value, right llvm.Value, pos token.Position) llvm.Value {
 	switch op {
 	case token.Add, token.AddAssign:
 		if ir.IsFloatType(t) {
 			return cb.b.CreateFAdd(left, right, "")
+		} else if cb.overflowCheck() {
+			return cb.createCheckedMathOp(op, t, left, right, pos)
 		}
 		return cb.b.CreateAdd(left, right, "")
 	case token.Sub, token.SubAssign:
 		if ir.IsFloatType(t) {
 			return cb.b.CreateFSub(left, right, "")
+		} else if cb.overflowCheck() {
+			return cb.createCheckedMathOp(op, t, left, right, pos)
 		}
 		return cb.b.CreateSub(left, right, "")
 	case token.Mul, token.MulAssign:
 		if ir.IsFloatType(t) {
 			return cb.b.CreateFMul(left, right, "")
+		} else if cb.overflowCheck() {
+			return cb.createCheckedMathOp(op, t, left, right, pos)
 		}
 		return cb.b.CreateMul(left, right, "")
-	case token.Div:
+	case token.Div, token.DivAssign:
 		if ir.IsFloatType(t) {
 			return cb.b.CreateFDiv(left, right, "")
 		} else if ir.IsSignedType(t) {
 			return cb.b.CreateSDiv(left, right, "")
 		}
 		return cb.b.CreateUDiv(left, right, "")
-	case token.Mod:
+	case token.Mod, token.ModAssign:
 		if ir.IsFloatType(t) {
 			return cb.b.CreateFRem(left, right, "")
 		} else if ir.IsUnsignedType(t) {
 			return cb.b.CreateURem(left, right, "")
 		}
 		return cb.b.CreateSRem(left, right, "")
+	case token.BitAnd, token.BitAndAssign:
+		return cb.b.CreateAnd(left, right, "")
+	case token.BitOr, token.BitOrAssign:
+		return cb.b.CreateOr(left, right, "")
+	case token.BitXor, token.BitXorAssign:
+		return cb.b.CreateXor(left, right, "")
+	case token.Shl, token.ShlAssign:
+		right = cb.createShiftCount(left, right)
+		return cb.b.CreateShl(left, right, "")
+	case token.Shr, token.ShrAssign:
+		right = cb.createShiftCount(left, right)
+		if ir.IsSignedType(t) {
+			return cb.b.CreateAShr(left, right, "")
+		}
+		return cb.b.CreateLShr(left, right, "")
 	}
 	panic(fmt.Sprintf("Unhandled arithmetic op %s", op))
 }
 
+// LLVM requires the shift count to have the same type as the shifted value.
+// The shift count is masked to the bit size of the shifted value, since LLVM gives a poison value
+// if the count is not less than the bit size.
+func (cb *llvmCodeBuilder) createShiftCount(left llvm.Value, right llvm.Value) llvm.Value {
+	cmpBitSize := cb.target.compareBitSize(left.Type(), right.Type())
+	if cmpBitSize > 0 {
+		right = cb.b.CreateZExt(right, left.Type(), "")
+	} else if cmpBitSize < 0 {
+		right = cb.b.CreateTrunc(right, left.Type(), "")
+	}
+	mask := llvm.ConstInt(left.Type(), uint64(left.Type().IntTypeWidth()-1), false)
+	return cb.b.CreateAnd(right, mask, "")
+}
+
 func floatPredicate(op token.Token) llvm.FloatPredicate {
 	switch op {
 	case token.Eq:
@@ -1096,9 +1602,16 @@ func (cb *llvmCodeBuilder) buildBinaryExpr(expr *ir.BinaryExpr) llvm.Value {
 	left := cb.buildExprVal(expr.Left)
 
 	switch expr.Op {
-	case token.Add, token.Sub, token.Mul, token.Div, token.Mod:
+	case token.Add, token.Sub:
+		if ir.IsRawPointerType(expr.Left.Type()) || ir.IsRawPointerType(expr.Right.Type()) {
+			return cb.buildPointerArithmetic(expr, left)
+		}
 		right := cb.buildExprVal(expr.Right)
-		return cb.createMathOp(expr.Op, expr.T, left, right)
+		return cb.createMathOp(expr.Op, expr.T, left, right, expr.Pos())
+	case token.Mul, token.Div, token.Mod,
+		token.BitAnd, token.BitOr, token.BitXor, token.Shl, token.Shr:
+		right := cb.buildExprVal(expr.Right)
+		return cb.createMathOp(expr.Op, expr.T, left, right, expr.Pos())
 	case token.Eq, token.Neq, token.Gt, token.GtEq, token.Lt, token.LtEq:
 		right := cb.buildExprVal(expr.Right)
 		if ir.IsFloatType(expr.Left.Type()) {
@@ -1148,7 +1661,7 @@ func (cb *llvmCodeBuilder) buildUnaryExpr(expr *ir.UnaryExpr) llvm.Value {
 			return cb.b.CreateFNeg(val, "")
 		}
 		return cb.b.CreateNeg(val, "")
-	case token.Lnot:
+	case token.Lnot, token.BitNot:
 		val := cb.buildExprVal(expr.X)
 		return cb.b.CreateNot(val, "")
 	default:
@@ -1174,6 +1687,78 @@ func (cb *llvmCodeBuilder) buildDerefExpr(expr *ir.DerefExpr, load bool) llvm.Va
 	return val
 }
 
+// A failed try stores the error in the return value and returns through the deferred statements.
+func (cb *llvmCodeBuilder) buildTryExpr(expr *ir.TryExpr) llvm.Value {
+	res := cb.buildExprVal(expr.X)
+
+	okBlock := llvm.AddBasicBlock(cb.fun, formatLabel("try.ok", expr.Pos()))
+	errBlock := llvm.AddBasicBlock(cb.fun, formatLabel("try.err", expr.Pos()))
+
+	ok := cb.b.CreateExtractValue(res, resultOkIndex, "")
+	cb.b.CreateCondBr(ok, okBlock, errBlock)
+
+	errBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(errBlock)
+	err := cb.b.CreateExtractValue(res, resultErrorIndex, "")
+	ret := cb.b.CreateInsertValue(llvm.ConstNull(cb.retValue.Type().ElementType()), err, resultErrorIndex, "")
+	cb.b.CreateStore(ret, cb.retValue)
+	cb.b.CreateStore(llvm.ConstInt(llvm.Int1Type(), 1, false), cb.errFlag)
+	cb.buildDeferBrTargets(token.Return, nil)
+	cb.b.CreateBr(cb.deferOrBranchTarget(token.Return, nil))
+
+	okBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(okBlock)
+	return cb.b.CreateExtractValue(res, resultValueIndex, "")
+}
+
+// The value of an if expression is a phi node in the merge block, which is structured like
+// the short-circuit and/or operators.
+func (cb *llvmCodeBuilder) buildIfExpr(expr *ir.IfExpr) llvm.Value {
+	cond := cb.buildExprVal(expr.Cond)
+
+	thenBlock := llvm.AddBasicBlock(cb.fun, formatTokLabel(expr.Tok, "expr.true", expr.Then.Pos()))
+	elseBlock := llvm.AddBasicBlock(cb.fun, formatTokLabel(expr.Tok, "expr.false", expr.Else.Pos()))
+	mergeBlock := llvm.AddBasicBlock(cb.fun, formatTokLabel(expr.Tok, "expr.merge", expr.EndPos()))
+	cb.b.CreateCondBr(cond, thenBlock, elseBlock)
+
+	thenBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(thenBlock)
+	thenVal := cb.buildExprVal(expr.Then)
+	thenBlock = cb.b.GetInsertBlock()
+	cb.b.CreateBr(mergeBlock)
+
+	elseBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(elseBlock)
+	elseVal := cb.buildExprVal(expr.Else)
+	elseBlock = cb.b.GetInsertBlock()
+	cb.b.CreateBr(mergeBlock)
+
+	mergeBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(mergeBlock)
+
+	phi := cb.b.CreatePHI(cb.llvmType(expr.T), "")
+	phi.AddIncoming([]llvm.Value{thenVal, elseVal}, []llvm.BasicBlock{thenBlock, elseBlock})
+	return phi
+}
+
+// Statements in a block expression cannot branch out of the block, so the block is built
+// without a defer context.
+func (cb *llvmCodeBuilder) buildBlockExpr(expr *ir.BlockExpr) llvm.Value {
+	if len(expr.Block.Stmts) == 0 {
+		return cb.buildExprVal(expr.X)
+	}
+	stackAddr := cb.saveStackAddr()
+	for _, stmt := range expr.Block.Stmts {
+		if cb.buildStmt(stmt) {
+			// The remainder of the block is built in an unreachable block after a panic
+			cb.b.SetInsertPointAtEnd(llvm.AddBasicBlock(cb.fun, formatLabel("block.dead", stmt.EndPos())))
+		}
+	}
+	val := cb.buildExprVal(expr.X)
+	cb.restoreStackAddr(stackAddr)
+	return val
+}
+
 func (cb *llvmCodeBuilder) createTempStorage(val llvm.Value) llvm.Value {
 	loc := cb.b.CreateAlloca(val.Type(), ".tmp")
 	cb.b.CreateStore(val, loc)
@@ -1197,7 +1782,29 @@ func (cb *llvmCodeBuilder) buildDotExpr(expr *ir.DotExpr, load bool) llvm.Value
 			val = cb.createTempStorage(val)
 		}
 
-		index := t.FieldIndex(expr.Name.Literal)
+		var gep llvm.Value
+		if t.CUnion {
+			tfield := t.Fields[t.FieldIndex(expr.Name.Literal)].T
+			gep = cb.b.CreateBitCast(val, llvm.PointerType(cb.llvmType(tfield), 0), "")
+		} else {
+			gep = cb.b.CreateStructGEP(val, cb.fieldIndex(t, t.FieldIndex(expr.Name.Literal)), "")
+		}
+		if load {
+			return cb.b.CreateLoad(gep, "")
+		}
+		return gep
+	case *ir.ResultType:
+		val := cb.buildExprPtr(expr.X)
+		if val.Type().TypeKind() != llvm.PointerTypeKind {
+			val = cb.createTempStorage(val)
+		}
+
+		index := resultValueIndex
+		if expr.Name.Literal == ir.ResultOk {
+			index = resultOkIndex
+		} else if expr.Name.Literal == ir.ResultError {
+			index = resultErrorIndex
+		}
 		gep := cb.b.CreateStructGEP(val, index, "")
 		if load {
 			return cb.b.CreateLoad(gep, "")
@@ -1209,6 +1816,16 @@ func (cb *llvmCodeBuilder) buildDotExpr(expr *ir.DotExpr, load bool) llvm.Value
 }
 
 func (cb *llvmCodeBuilder) buildIndexExpr(expr *ir.IndexExpr, load bool) llvm.Value {
+	if ir.IsRawPointerType(expr.X.Type()) {
+		ptr := cb.buildExprVal(expr.X)
+		index := cb.buildExprVal(expr.Index)
+		gep := cb.createPointerOffset(token.Add, ptr, index, expr.Index.Type())
+		if load {
+			return cb.b.CreateLoad(gep, "")
+		}
+		return gep
+	}
+
 	val := cb.buildExprPtr(expr.X)
 	if val.Type().TypeKind() != llvm.PointerTypeKind {
 		val = cb.createTempStorage(val)
@@ -1219,10 +1836,19 @@ func (cb *llvmCodeBuilder) buildIndexExpr(expr *ir.IndexExpr, load bool) llvm.Va
 	var gep llvm.Value
 
 	if expr.X.Type().Kind() == ir.TSlice {
+		if cb.boundsCheck() {
+			size := cb.b.CreateLoad(cb.b.CreateStructGEP(val, lenFieldIndex, ""), "")
+			cb.createIndexCheck(index, expr.Index.Type(), size, expr.Pos())
+		}
 		slicePtr := cb.b.CreateStructGEP(val, ptrFieldIndex, "")
 		slicePtr = cb.b.CreateLoad(slicePtr, "")
 		gep = cb.b.CreateInBoundsGEP(slicePtr, []llvm.Value{index}, "")
 	} else {
+		if cb.boundsCheck() {
+			tarray := ir.ToBaseType(expr.X.Type()).(*ir.ArrayType)
+			size := llvm.ConstInt(llvmSizeType(), uint64(tarray.Size), false)
+			cb.createIndexCheck(index, expr.Index.Type(), size, expr.Pos())
+		}
 		gep = cb.b.CreateInBoundsGEP(val, []llvm.Value{llvm.ConstInt(llvm.Int64Type(), 0, false), index}, "")
 	}
 
@@ -1233,6 +1859,35 @@ func (cb *llvmCodeBuilder) buildIndexExpr(expr *ir.IndexExpr, load bool) llvm.Va
 	return gep
 }
 
+// createPointerIndex extends an integer to the width of a pointer offset.
+func (cb *llvmCodeBuilder) createPointerIndex(index llvm.Value, t ir.Type) llvm.Value {
+	if cb.target.compareBitSize(llvm.Int64Type(), index.Type()) > 0 {
+		if ir.IsUnsignedType(t) {
+			return cb.b.CreateZExt(index, llvm.Int64Type(), "")
+		}
+		return cb.b.CreateSExt(index, llvm.Int64Type(), "")
+	}
+	return index
+}
+
+func (cb *llvmCodeBuilder) createPointerOffset(op token.Token, ptr llvm.Value, index llvm.Value, t ir.Type) llvm.Value {
+	index = cb.createPointerIndex(index, t)
+	if op.OneOf(token.Sub, token.SubAssign) {
+		index = cb.b.CreateNeg(index, "")
+	}
+	return cb.b.CreateGEP(ptr, []llvm.Value{index}, "")
+}
+
+func (cb *llvmCodeBuilder) buildPointerArithmetic(expr *ir.BinaryExpr, left llvm.Value) llvm.Value {
+	right := cb.buildExprVal(expr.Right)
+	if !ir.IsRawPointerType(expr.Left.Type()) {
+		return cb.createPointerOffset(expr.Op, right, left, expr.Left.Type())
+	} else if ir.IsRawPointerType(expr.Right.Type()) {
+		return cb.b.CreatePtrDiff(left, right, "")
+	}
+	return cb.createPointerOffset(expr.Op, left, right, expr.Right.Type())
+}
+
 func (cb *llvmCodeBuilder) buildSliceExpr(expr *ir.SliceExpr) llvm.Value {
 	val := cb.buildExprPtr(expr.X)
 	start := cb.buildExprVal(expr.Start)
@@ -1243,14 +1898,26 @@ func (cb *llvmCodeBuilder) buildSliceExpr(expr *ir.SliceExpr) llvm.Value {
 
 	switch t := ir.ToBaseType(expr.X.Type()).(type) {
 	case *ir.ArrayType:
+		if cb.boundsCheck() {
+			size := llvm.ConstInt(llvmSizeType(), uint64(t.Size), false)
+			cb.createSliceCheck(start, end, size, expr.Pos())
+		}
 		gep = cb.b.CreateInBoundsGEP(val, []llvm.Value{llvm.ConstInt(llvm.Int64Type(), 0, false), start}, "")
 		tptr = llvm.PointerType(cb.llvmType(t.Elem), 0)
 	case *ir.SliceType:
+		if cb.boundsCheck() {
+			size := cb.b.CreateLoad(cb.b.CreateStructGEP(val, lenFieldIndex, ""), "")
+			cb.createSliceCheck(start, end, size, expr.Pos())
+		}
 		slicePtr := cb.b.CreateStructGEP(val, ptrFieldIndex, "")
 		slicePtr = cb.b.CreateLoad(slicePtr, "")
 		gep = cb.b.CreateInBoundsGEP(slicePtr, []llvm.Value{start}, "")
 		tptr = llvm.PointerType(cb.llvmType(t.Elem), 0)
 	case *ir.PointerType:
+		if cb.boundsCheck() {
+			// The length of the memory behind a pointer is unknown
+			cb.createSliceCheck(start, end, llvm.Value{}, expr.Pos())
+		}
 		tmp := cb.b.CreateLoad(val, "")
 		gep = cb.b.CreateInBoundsGEP(tmp, []llvm.Value{start}, "")
 		tptr = llvm.PointerType(cb.llvmType(t.Elem), 0)
@@ -1270,28 +1937,212 @@ func (cb *llvmCodeBuilder) buildSliceExpr(expr *ir.SliceExpr) llvm.Value {
 	return cb.createSliceStruct(ptr, size, expr.Type())
 }
 
+func (cb *llvmCodeBuilder) boundsCheck() bool {
+	return cb.ctx.BoundsCheck && cb.inFunction
+}
+
+func (cb *llvmCodeBuilder) overflowCheck() bool {
+	return cb.ctx.Overflow != common.OverflowWrap && cb.inFunction
+}
+
+// createIndexCheck panics if the index is not less than the length. Negative indexes are
+// sign-extended and fail the unsigned comparison.
+func (cb *llvmCodeBuilder) createIndexCheck(index llvm.Value, tindex ir.Type, size llvm.Value, pos token.Position) {
+	index = cb.createPointerIndex(index, tindex)
+	inBounds := cb.b.CreateICmp(llvm.IntULT, index, size, "")
+	cb.createRuntimeCheck(inBounds, pos, "index out of bounds")
+}
+
+// createSliceCheck panics unless start <= end <= size. The size is nil if the length is unknown.
+func (cb *llvmCodeBuilder) createSliceCheck(start llvm.Value, end llvm.Value, size llvm.Value, pos token.Position) {
+	inBounds := cb.b.CreateICmp(llvm.IntULE, start, end, "")
+	if !size.IsNil() {
+		inBounds = cb.b.CreateAnd(inBounds, cb.b.CreateICmp(llvm.IntULE, end, size, ""), "")
+	}
+	cb.createRuntimeCheck(inBounds, pos, "slice indices out of bounds")
+}
+
 func (cb *llvmCodeBuilder) buildAppExpr(expr *ir.AppExpr) llvm.Value {
 	if expr.IsStruct {
 		tstruct := ir.ToBaseType(expr.T).(*ir.StructType)
 		llvmType := cb.typeMap[tstruct.Sym.Key]
+		if tstruct.CUnion {
+			return cb.buildCUnionLit(tstruct, llvmType, expr.Args)
+		}
 		structLit := llvm.Undef(llvmType)
 
 		for argIndex, arg := range expr.Args {
 			init := cb.buildExprVal(arg.Value)
-			structLit = cb.b.CreateInsertValue(structLit, init, argIndex, "")
+			structLit = cb.b.CreateInsertValue(structLit, init, cb.fieldIndex(tstruct, argIndex), "")
 		}
 
 		return structLit
 	}
+	if dot, ok := expr.X.(*ir.DotExpr); ok {
+		if tiface, ok := ir.ToBaseType(dot.X.Type()).(*ir.InterfaceType); ok {
+			return cb.buildInterfaceCall(tiface, dot, expr.Args)
+		}
+	}
 	fun := cb.buildExprVal(expr.X)
 	var args []llvm.Value
 	for _, arg := range expr.Args {
 		args = append(args, cb.buildExprVal(arg.Value))
 	}
+	if tclosure, ok := ir.ToBaseType(expr.X.Type()).(*ir.ClosureType); ok {
+		return cb.buildClosureCall(tclosure, fun, args)
+	}
 	return cb.b.CreateCall(fun, args, "")
 }
 
+func (cb *llvmCodeBuilder) llvmEnvType(decl *ir.FuncDecl) llvm.Type {
+	var fieldTypes []llvm.Type
+	for _, capture := range decl.Captures {
+		fieldTypes = append(fieldTypes, cb.llvmType(capture.Inner.T))
+	}
+	return llvm.StructType(fieldTypes, false)
+}
+
+// The environment of a closure is allocated on the stack if the closure doesn't escape,
+// otherwise it's allocated on the heap in the same way as new, and freed when the closure is deleted.
+func (cb *llvmCodeBuilder) buildFuncLit(expr *ir.FuncLit) llvm.Value {
+	decl := expr.Decl
+	fun := cb.mod.NamedFunction(cb.mangle(decl.Sym))
+	if len(decl.Captures) == 0 {
+		return fun
+	}
+
+	tenv := cb.llvmEnvType(decl)
+	var env llvm.Value
+	if expr.Stack {
+		env = cb.b.CreateAlloca(tenv, ".env")
+	} else {
+		size := llvm.ConstInt(llvmSizeType(), cb.target.data.TypeAllocSize(tenv), false)
+		mem := cb.createAlloc(size, cb.target.data.ABITypeAlignment(tenv), expr.Pos())
+		env = cb.b.CreateBitCast(mem, llvm.PointerType(tenv, 0), ".env")
+	}
+
+	for i, capture := range decl.Captures {
+		val := cb.b.CreateLoad(cb.valueMap[capture.Outer.Key], capture.Outer.Name)
+		cb.b.CreateStore(val, cb.b.CreateStructGEP(env, i, ""))
+	}
+
+	return cb.createClosureStruct(fun, env)
+}
+
+func (cb *llvmCodeBuilder) mallocFunc() llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	tmalloc := llvm.FunctionType(tptr, []llvm.Type{llvmSizeType()}, false)
+	return cb.cFunc("malloc", tmalloc)
+}
+
+// cFunc declares a function from the C library.
+func (cb *llvmCodeBuilder) cFunc(name string, t llvm.Type) llvm.Value {
+	fun := cb.mod.NamedFunction(name)
+	if fun.IsNil() {
+		return llvm.AddFunction(cb.mod, name, t)
+	}
+	// The function may already be declared with a different signature
+	return llvm.ConstBitCast(fun, llvm.PointerType(t, 0))
+}
+
+func (cb *llvmCodeBuilder) createClosureStruct(fun llvm.Value, env llvm.Value) llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	closure := llvm.Undef(llvmClosureType())
+	closure = cb.b.CreateInsertValue(closure, cb.b.CreateBitCast(fun, tptr, ""), closureFunIndex, "")
+	closure = cb.b.CreateInsertValue(closure, cb.b.CreateBitCast(env, tptr, ""), closureEnvIndex, "")
+	return closure
+}
+
+// A closure without an environment is called as a regular function.
+func (cb *llvmCodeBuilder) buildClosureCall(t *ir.ClosureType, closure llvm.Value, args []llvm.Value) llvm.Value {
+	fun := cb.b.CreateExtractValue(closure, closureFunIndex, "")
+	env := cb.b.CreateExtractValue(closure, closureEnvIndex, "")
+
+	funBlock := llvm.AddBasicBlock(cb.fun, ".closure.fun")
+	envBlock := llvm.AddBasicBlock(cb.fun, ".closure.env")
+	join := llvm.AddBasicBlock(cb.fun, ".closure.join")
+
+	isNull := cb.b.CreateIsNull(env, "")
+	cb.b.CreateCondBr(isNull, funBlock, envBlock)
+
+	funBlock.MoveAfter(cb.fun.LastBasicBlock())
+	cb.b.SetInsertPointAtEnd(funBlock)
+	tfun := cb.target.llvmFuncType(t.F, &cb.typeMap)
+	res1 := cb.b.CreateCall(cb.b.CreateBitCast(fun, tfun, ""), args, "")
+	cb.b.CreateBr(join)
+
+	envBlock.MoveAfter(cb.fun.LastBasicBlock())
+	cb.b.SetInsertPointAtEnd(envBlock)
+	tenvFun := cb.target.llvmEnvFuncType(t.F, &cb.typeMap)
+	envArgs := append([]llvm.Value{env}, args...)
+	res2 := cb.b.CreateCall(cb.b.CreateBitCast(fun, tenvFun, ""), envArgs, "")
+	cb.b.CreateBr(join)
+
+	join.MoveAfter(cb.fun.LastBasicBlock())
+	cb.b.SetInsertPointAtEnd(join)
+
+	if t.F.Return.Kind() == ir.TVoid {
+		return res1
+	}
+	phi := cb.b.CreatePHI(cb.llvmType(t.F.Return), "")
+	phi.AddIncoming([]llvm.Value{res1, res2}, []llvm.BasicBlock{funBlock, envBlock})
+	return phi
+}
+
+// The method is loaded from the method table and called with the data pointer as the first argument.
+func (cb *llvmCodeBuilder) buildInterfaceCall(t *ir.InterfaceType, dot *ir.DotExpr, args []*ir.ArgExpr) llvm.Value {
+	iface := cb.buildExprVal(dot.X)
+	data := cb.b.CreateExtractValue(iface, interfaceDataIndex, "")
+	table := cb.b.CreateExtractValue(iface, interfaceTableIndex, "")
+
+	index := t.MethodIndex(dot.Name.Literal)
+	method := t.Methods[index]
+	gep := cb.b.CreateGEP(table, []llvm.Value{llvm.ConstInt(llvm.Int32Type(), uint64(index), false)}, "")
+	fun := cb.b.CreateLoad(gep, "")
+
+	callArgs := []llvm.Value{data}
+	for _, arg := range args {
+		callArgs = append(callArgs, cb.buildExprVal(arg.Value))
+	}
+	tfun := cb.target.llvmEnvFuncType(method.T, &cb.typeMap)
+	return cb.b.CreateCall(cb.b.CreateBitCast(fun, tfun, ""), callArgs, "")
+}
+
+func (cb *llvmCodeBuilder) createInterfaceStruct(ptr llvm.Value, tstruct *ir.StructType, tiface *ir.InterfaceType) llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	iface := llvm.Undef(llvmInterfaceType())
+	iface = cb.b.CreateInsertValue(iface, cb.b.CreateBitCast(ptr, tptr, ""), interfaceDataIndex, "")
+	iface = cb.b.CreateInsertValue(iface, cb.methodTable(tstruct, tiface), interfaceTableIndex, "")
+	return iface
+}
+
+// methodTable returns the table of methods which implement the interface for the struct.
+// The table is created once per module.
+func (cb *llvmCodeBuilder) methodTable(tstruct *ir.StructType, tiface *ir.InterfaceType) llvm.Value {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	name := ".vtable." + mangleType(tstruct) + mangleType(tiface)
+	table := cb.mod.NamedGlobal(name)
+	if table.IsNil() {
+		var funs []llvm.Value
+		for _, method := range tiface.Methods {
+			sym := tstruct.Scope().Lookup(method.Name)
+			fun := cb.mod.NamedFunction(cb.mangle(sym))
+			funs = append(funs, llvm.ConstBitCast(fun, tptr))
+		}
+		init := llvm.ConstArray(tptr, funs)
+		table = llvm.AddGlobal(cb.mod, init.Type(), name)
+		table.SetLinkage(llvm.PrivateLinkage)
+		table.SetInitializer(init)
+		table.SetGlobalConstant(true)
+	}
+	return llvm.ConstBitCast(table, llvm.PointerType(tptr, 0))
+}
+
 func (cb *llvmCodeBuilder) buildCastExpr(expr *ir.CastExpr) llvm.Value {
+	if tres, ok := ir.ToBaseType(expr.T).(*ir.ResultType); ok && !expr.X.Type().Equals(tres) {
+		return cb.buildResult(expr.X, tres)
+	}
+
 	val := cb.buildExprVal(expr.X)
 
 	to := expr.Type()
@@ -1300,6 +2151,17 @@ func (cb *llvmCodeBuilder) buildCastExpr(expr *ir.CastExpr) llvm.Value {
 		return val
 	}
 
+	// Enums are cast through their backing type
+	if tenum, ok := ir.ToBaseType(from).(*ir.EnumType); ok {
+		from = tenum.Backing
+	}
+	if tenum, ok := ir.ToBaseType(to).(*ir.EnumType); ok {
+		to = tenum.Backing
+	}
+	if from.Equals(to) {
+		return val
+	}
+
 	fromLLVM := cb.llvmType(from)
 	toLLVM := cb.llvmType(to)
 
@@ -1336,7 +2198,7 @@ func (cb *llvmCodeBuilder) buildCastExpr(expr *ir.CastExpr) llvm.Value {
 			}
 		case ir.IsIntegerType(to):
 			if cmpBitSize > 0 {
-				if ir.IsSignedType(to) {
+				if ir.IsSignedType(from) {
 					res = cb.b.CreateSExt(val, toLLVM, "")
 				} else {
 					res = cb.b.CreateZExt(val, toLLVM, "")
@@ -1344,12 +2206,23 @@ func (cb *llvmCodeBuilder) buildCastExpr(expr *ir.CastExpr) llvm.Value {
 			} else {
 				res = cb.b.CreateTrunc(val, toLLVM, "")
 			}
+		case to.Kind() == ir.TPointer:
+			res = cb.b.CreateIntToPtr(val, toLLVM, "")
 		default:
 			unhandled = true
 		}
 	default:
-		if from.Kind() == ir.TPointer && to.Kind() == ir.TPointer {
+		if from.Kind() == ir.TFunc && to.Kind() == ir.TClosure {
+			tptr := llvm.PointerType(llvm.Int8Type(), 0)
+			res = cb.createClosureStruct(val, llvm.ConstPointerNull(tptr))
+		} else if from.Kind() == ir.TPointer && to.Kind() == ir.TInterface {
+			tstruct := ir.ToBaseType(ir.ToBaseType(from).(*ir.PointerType).Elem).(*ir.StructType)
+			tiface := ir.ToBaseType(to).(*ir.InterfaceType)
+			res = cb.createInterfaceStruct(val, tstruct, tiface)
+		} else if from.Kind() == ir.TPointer && to.Kind() == ir.TPointer {
 			res = cb.b.CreateBitCast(val, cb.llvmType(to), "")
+		} else if from.Kind() == ir.TPointer && to.Kind() == ir.TUSize {
+			res = cb.b.CreatePtrToInt(val, toLLVM, "")
 		} else if from.Kind() == ir.TSlice && to.Kind() == ir.TSlice {
 			slice1 := ir.ToBaseType(from).(*ir.SliceType)
 			slice2 := ir.ToBaseType(to).(*ir.SliceType)
@@ -1370,6 +2243,22 @@ func (cb *llvmCodeBuilder) buildCastExpr(expr *ir.CastExpr) llvm.Value {
 	return res
 }
 
+// buildResult converts a value or an error to a result.
+func (cb *llvmCodeBuilder) buildResult(x ir.Expr, t *ir.ResultType) llvm.Value {
+	res := llvm.ConstNull(cb.llvmType(t))
+	if x.Type().Equals(t.E) {
+		return cb.b.CreateInsertValue(res, cb.buildExprVal(x), resultErrorIndex, "")
+	}
+	res = cb.b.CreateInsertValue(res, llvm.ConstInt(llvm.Int1Type(), 1, false), resultOkIndex, "")
+	if t.T.Kind() != ir.TVoid {
+		res = cb.b.CreateInsertValue(res, cb.buildExprVal(x), resultValueIndex, "")
+	} else if ident, ok := x.(*ir.Ident); !ok || ident.Tok != token.Placeholder {
+		// Evaluated for side effects, e.g. a call which returns void
+		cb.buildExprVal(x)
+	}
+	return res
+}
+
 func (cb *llvmCodeBuilder) buildLenExpr(expr *ir.LenExpr) llvm.Value {
 	switch t := ir.ToBaseType(expr.X.Type()).(type) {
 	case *ir.ArrayType:
@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"fmt"
+
+	"github.com/cjo5/dingo/internal/common"
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Integer addition, subtraction and multiplication wrap around by default. In the trap and panic overflow
+// modes the operations are computed with the LLVM overflow intrinsics, and the program traps or panics
+// if the overflow flag is set. The overflow builtins behave the same regardless of the mode.
+
+// createCheckedMathOp is called instead of a plain add, sub or mul if overflow is checked at runtime.
+func (cb *llvmCodeBuilder) createCheckedMathOp(op token.Token, t ir.Type, left llvm.Value, right llvm.Value, pos token.Position) llvm.Value {
+	res, overflow := cb.createOverflowOp(op, t, left, right)
+	noOverflow := cb.b.CreateNot(overflow, "")
+	if cb.ctx.Overflow == common.OverflowTrap {
+		okBlock := cb.createCheckBranch(noOverflow, pos)
+		cb.b.CreateCall(cb.trapIntrinsic(), nil, "")
+		cb.b.CreateUnreachable()
+		cb.endCheckBranch(okBlock)
+	} else {
+		cb.createRuntimeCheck(noOverflow, pos, "integer overflow")
+	}
+	return res
+}
+
+// createOverflowOp returns the wrapped result of the operation and a flag which is true if it overflowed.
+func (cb *llvmCodeBuilder) createOverflowOp(op token.Token, t ir.Type, left llvm.Value, right llvm.Value) (llvm.Value, llvm.Value) {
+	res := cb.b.CreateCall(cb.overflowIntrinsic(op, t), []llvm.Value{left, right}, "")
+	return cb.b.CreateExtractValue(res, 0, ""), cb.b.CreateExtractValue(res, 1, "")
+}
+
+func (cb *llvmCodeBuilder) overflowIntrinsic(op token.Token, t ir.Type) llvm.Value {
+	opName := ""
+	switch op {
+	case token.Add, token.AddAssign, token.CheckedAdd, token.SaturatingAdd:
+		opName = "add"
+	case token.Sub, token.SubAssign:
+		opName = "sub"
+	case token.Mul, token.MulAssign:
+		opName = "mul"
+	default:
+		panic(fmt.Sprintf("Unhandled overflow op %s", op))
+	}
+
+	sign := "u"
+	if ir.IsSignedType(t) {
+		sign = "s"
+	}
+
+	tint := cb.llvmType(t)
+	name := fmt.Sprintf("llvm.%s%s.with.overflow.i%d", sign, opName, tint.IntTypeWidth())
+	if fun := cb.mod.NamedFunction(name); !fun.IsNil() {
+		return fun
+	}
+
+	tres := llvm.StructType([]llvm.Type{tint, llvm.Int1Type()}, false)
+	return llvm.AddFunction(cb.mod, name, llvm.FunctionType(tres, []llvm.Type{tint, tint}, false))
+}
+
+func (cb *llvmCodeBuilder) trapIntrinsic() llvm.Value {
+	name := "llvm.trap"
+	if fun := cb.mod.NamedFunction(name); !fun.IsNil() {
+		return fun
+	}
+	fun := llvm.AddFunction(cb.mod, name, llvm.FunctionType(llvm.VoidType(), nil, false))
+	fun.AddFunctionAttr(cb.llvmEnumAttribute("noreturn", 0))
+	return fun
+}
+
+func (cb *llvmCodeBuilder) buildOverflowExpr(expr *ir.OverflowExpr) llvm.Value {
+	left := cb.buildExprVal(expr.Left)
+	right := cb.buildExprVal(expr.Right)
+
+	switch expr.Op {
+	case token.WrappingAdd:
+		return cb.b.CreateAdd(left, right, "")
+	case token.CheckedAdd:
+		sum, overflow := cb.createOverflowOp(expr.Op, expr.Left.Type(), left, right)
+		res := llvm.Undef(cb.llvmType(expr.T))
+		res = cb.b.CreateInsertValue(res, sum, 0, "")
+		return cb.b.CreateInsertValue(res, overflow, 1, "")
+	case token.SaturatingAdd:
+		sum, overflow := cb.createOverflowOp(expr.Op, expr.T, left, right)
+		tint := cb.llvmType(expr.T)
+		width := uint(tint.IntTypeWidth())
+		var limit llvm.Value
+		if ir.IsSignedType(expr.T) {
+			// A signed addition can only overflow if both operands have the same sign
+			max := llvm.ConstInt(tint, (uint64(1)<<(width-1))-1, false)
+			min := llvm.ConstInt(tint, uint64(1)<<(width-1), false)
+			negative := cb.b.CreateICmp(llvm.IntSLT, right, llvm.ConstInt(tint, 0, false), "")
+			limit = cb.b.CreateSelect(negative, min, max, "")
+		} else {
+			limit = llvm.ConstInt(tint, ^uint64(0)>>(64-width), false)
+		}
+		return cb.b.CreateSelect(overflow, limit, sum, "")
+	}
+
+	panic(fmt.Sprintf("Unhandled overflow op %s", expr.Op))
+}
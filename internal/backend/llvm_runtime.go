@@ -0,0 +1,144 @@
+package backend
+
+import (
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+	"llvm.org/llvm/bindings/go/llvm"
+)
+
+// Panic statements, failed assertions and failed runtime checks call the panic entry point with the
+// position and a message. The entry point calls the panic handler of the program if it has one,
+// otherwise the position and message are printed to stderr. The program is aborted if the handler returns.
+
+const panicFuncName = ".dg.panic"
+
+var tpanicString = ir.NewSliceType(ir.TBuiltinByte, true, true)
+
+func findRuntimeHandlers(matrix ir.DeclMatrix) map[string]*ir.Symbol {
+	handlers := make(map[string]*ir.Symbol)
+	for _, list := range matrix {
+		for _, decl := range list.Decls {
+			sym := decl.Symbol()
+			if sym.Kind != ir.FuncSymbol {
+				continue
+			}
+			if handler := sym.RuntimeHandler(); handler != "" {
+				handlers[handler] = sym
+			}
+		}
+	}
+	return handlers
+}
+
+func (cb *llvmCodeBuilder) buildPanicStmt(stmt *ir.PanicStmt) {
+	msg := cb.buildExprVal(stmt.X)
+	cb.buildPanic(stmt.Pos(), msg)
+}
+
+// The message of an assertion is only evaluated if the assertion fails.
+func (cb *llvmCodeBuilder) buildAssertStmt(stmt *ir.AssertStmt) {
+	cond := cb.buildExprVal(stmt.Cond)
+	okBlock := cb.createCheckBranch(cond, stmt.Pos())
+	var msg llvm.Value
+	if stmt.Msg != nil {
+		msg = cb.buildExprVal(stmt.Msg)
+	} else {
+		msg = cb.createPanicString("assertion failed")
+	}
+	cb.buildPanic(stmt.Pos(), msg)
+	cb.endCheckBranch(okBlock)
+}
+
+// createRuntimeCheck panics with a constant message if the condition is false.
+func (cb *llvmCodeBuilder) createRuntimeCheck(cond llvm.Value, pos token.Position, msg string) {
+	okBlock := cb.createCheckBranch(cond, pos)
+	cb.buildPanic(pos, cb.createPanicString(msg))
+	cb.endCheckBranch(okBlock)
+}
+
+// createCheckBranch branches on the condition and continues in the block which is entered if the
+// condition is false. The block which is entered if the condition is true is returned.
+func (cb *llvmCodeBuilder) createCheckBranch(cond llvm.Value, pos token.Position) llvm.BasicBlock {
+	okBlock := llvm.AddBasicBlock(cb.fun, formatLabel("check.ok", pos))
+	failBlock := llvm.AddBasicBlock(cb.fun, formatLabel("check.fail", pos))
+	cb.b.CreateCondBr(cond, okBlock, failBlock)
+
+	failBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(failBlock)
+	return okBlock
+}
+
+func (cb *llvmCodeBuilder) endCheckBranch(okBlock llvm.BasicBlock) {
+	okBlock.MoveAfter(cb.b.GetInsertBlock())
+	cb.b.SetInsertPointAtEnd(okBlock)
+}
+
+func (cb *llvmCodeBuilder) createPanicString(s string) llvm.Value {
+	ptr := cb.b.CreateGlobalStringPtr(s, ".panic.str")
+	return cb.createSliceStruct(ptr, cb.createSliceSize(len(s)), tpanicString)
+}
+
+// buildPanic calls the panic entry point and terminates the current block.
+func (cb *llvmCodeBuilder) buildPanic(pos token.Position, msg llvm.Value) {
+	cb.b.CreateCall(cb.panicFunc(), []llvm.Value{cb.createPanicString(pos.String()), msg}, "")
+	cb.b.CreateUnreachable()
+}
+
+// panicFunc returns the panic entry point. Every module which can panic gets its own internal copy.
+func (cb *llvmCodeBuilder) panicFunc() llvm.Value {
+	if fun := cb.mod.NamedFunction(panicFuncName); !fun.IsNil() {
+		return fun
+	}
+
+	tstring := cb.llvmType(tpanicString)
+	tpanic := llvm.FunctionType(llvm.VoidType(), []llvm.Type{tstring, tstring}, false)
+	fun := llvm.AddFunction(cb.mod, panicFuncName, tpanic)
+	fun.SetLinkage(llvm.InternalLinkage)
+	fun.AddFunctionAttr(cb.llvmEnumAttribute("noreturn", 0))
+	fun.AddFunctionAttr(cb.llvmEnumAttribute("noinline", 0))
+	fun.AddFunctionAttr(cb.llvmEnumAttribute("cold", 0))
+
+	// cb.b is positioned in the function being built, so the entry point needs its own builder
+	b := llvm.NewBuilder()
+	defer b.Dispose()
+	b.SetInsertPointAtEnd(llvm.AddBasicBlock(fun, ".entry"))
+
+	pos := fun.Param(0)
+	msg := fun.Param(1)
+
+	if handler := cb.runtimeHandlerFunc(ir.AttrPanicHandler); !handler.IsNil() {
+		b.CreateCall(handler, []llvm.Value{pos, msg}, "")
+	} else {
+		tptr := llvm.PointerType(llvm.Int8Type(), 0)
+		tdprintf := llvm.FunctionType(llvm.Int32Type(), []llvm.Type{llvm.Int32Type(), tptr}, true)
+		format := b.CreateGlobalStringPtr("%.*s: panic: %.*s\n", ".panic.fmt")
+		stderr := llvm.ConstInt(llvm.Int32Type(), 2, false)
+		var args []llvm.Value
+		args = append(args, stderr, format)
+		for _, str := range []llvm.Value{pos, msg} {
+			size := b.CreateTrunc(b.CreateExtractValue(str, lenFieldIndex, ""), llvm.Int32Type(), "")
+			args = append(args, size, b.CreateExtractValue(str, ptrFieldIndex, ""))
+		}
+		b.CreateCall(cb.cFunc("dprintf", tdprintf), args, "")
+	}
+
+	tabort := llvm.FunctionType(llvm.VoidType(), nil, false)
+	b.CreateCall(cb.cFunc("abort", tabort), nil, "")
+	b.CreateUnreachable()
+
+	return fun
+}
+
+// runtimeHandlerFunc returns the runtime handler of the program, which may be defined in another module.
+// A nil value is returned if the program uses the default implementation.
+func (cb *llvmCodeBuilder) runtimeHandlerFunc(handler string) llvm.Value {
+	sym := cb.handlers[handler]
+	if sym == nil {
+		return llvm.Value{}
+	}
+	name := mangle(sym)
+	if fun := cb.mod.NamedFunction(name); !fun.IsNil() {
+		return fun
+	}
+	return llvm.AddFunction(cb.mod, name, cb.llvmType(sym.T).ElementType())
+}
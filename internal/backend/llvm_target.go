@@ -44,7 +44,7 @@ func createTargetMachine() llvm.TargetMachine {
 }
 
 func (target *llvmTarget) Sizeof(t ir.Type) int {
-	llvmType := llvmType(t, nil)
+	llvmType := target.llvmType(t, nil)
 	return int(target.data.TypeAllocSize(llvmType))
 }
 
@@ -87,74 +87,263 @@ func llvmSizeType() llvm.Type {
 	return llvmBasicType(ir.TUSize)
 }
 
-func llvmStructType(t *ir.StructType, ctx *llvmTypeMap) llvm.Type {
+func (target *llvmTarget) llvmStructType(t *ir.StructType, ctx *llvmTypeMap) llvm.Type {
 	if ctx != nil {
 		if res, ok := (*ctx)[t.Sym.Key]; ok {
 			return res
 		}
 		panic(fmt.Sprintf("Failed to find named type %s", t))
 	}
-	var fieldTypes []llvm.Type
+	return llvm.StructType(target.llvmStructLayout(t, ctx).body, t.Packed)
+}
+
+// llvmStructLayout is the body of a struct and the index of each field in the body.
+type llvmStructLayout struct {
+	body    []llvm.Type
+	indices []int
+}
+
+// An LLVM struct is aligned to its strictest member. If a field or the struct itself
+// requires a stricter alignment, the padding is inserted explicitly as byte arrays.
+func (target *llvmTarget) llvmStructLayout(t *ir.StructType, ctx *llvmTypeMap) llvmStructLayout {
+	if t.CUnion {
+		return llvmStructLayout{body: target.llvmCUnionBody(t, ctx), indices: make([]int, len(t.Fields))}
+	}
+	var layout llvmStructLayout
+	offset := 0
+	for _, field := range t.Fields {
+		tfield := target.llvmType(field.T, ctx)
+		fieldAlign := target.data.ABITypeAlignment(tfield)
+		if !t.Packed {
+			if align := target.alignof(field.T, ctx); align > fieldAlign {
+				fieldAlign = align
+				if pad := alignTo(offset, align) - offset; pad > 0 {
+					layout.body = append(layout.body, llvm.ArrayType(llvm.Int8Type(), pad))
+				}
+			}
+			offset = alignTo(offset, fieldAlign)
+		}
+		layout.indices = append(layout.indices, len(layout.body))
+		layout.body = append(layout.body, tfield)
+		offset += int(target.data.TypeAllocSize(tfield))
+	}
+	if pad := alignTo(offset, target.alignof(t, ctx)) - offset; pad > 0 && !t.Packed {
+		layout.body = append(layout.body, llvm.ArrayType(llvm.Int8Type(), pad))
+	}
+	return layout
+}
+
+// The fields of a cunion start at offset 0. The body is the field with the strictest alignment,
+// padded to the size of the largest field. A packed cunion is a byte array.
+func (target *llvmTarget) llvmCUnionBody(t *ir.StructType, ctx *llvmTypeMap) []llvm.Type {
+	size := 0
+	var taligned llvm.Type
+	alignedSize := 0
 	for _, field := range t.Fields {
-		fieldTypes = append(fieldTypes, llvmType(field.T, ctx))
+		tfield := target.llvmType(field.T, ctx)
+		fieldSize := int(target.data.TypeAllocSize(tfield))
+		if fieldSize > size {
+			size = fieldSize
+		}
+		if taligned.IsNil() || target.data.ABITypeAlignment(tfield) > target.data.ABITypeAlignment(taligned) {
+			taligned = tfield
+			alignedSize = fieldSize
+		}
+	}
+	if size == 0 {
+		return nil
+	}
+	if t.Packed {
+		return []llvm.Type{llvm.ArrayType(llvm.Int8Type(), size)}
+	}
+	body := []llvm.Type{taligned}
+	if pad := alignTo(size, target.alignof(t, ctx)) - alignedSize; pad > 0 {
+		body = append(body, llvm.ArrayType(llvm.Int8Type(), pad))
+	}
+	return body
+}
+
+// alignof returns the alignment of a type, including the alignment requested by attributes.
+func (target *llvmTarget) alignof(t ir.Type, ctx *llvmTypeMap) int {
+	switch t := ir.ToBaseType(t).(type) {
+	case *ir.StructType:
+		if t.Packed {
+			return 1
+		}
+		align := 1
+		if t.Align > 0 {
+			align = t.Align
+		}
+		for _, field := range t.Fields {
+			if fieldAlign := target.alignof(field.T, ctx); fieldAlign > align {
+				align = fieldAlign
+			}
+		}
+		return align
+	case *ir.ArrayType:
+		return target.alignof(t.Elem, ctx)
+	}
+	return target.data.ABITypeAlignment(target.llvmType(t, ctx))
+}
+
+func alignTo(offset int, align int) int {
+	return (offset + align - 1) / align * align
+}
+
+func (target *llvmTarget) llvmUnionType(t *ir.UnionType, ctx *llvmTypeMap) llvm.Type {
+	if ctx != nil {
+		if res, ok := (*ctx)[t.Sym.Key]; ok {
+			return res
+		}
+		panic(fmt.Sprintf("Failed to find named type %s", t))
+	}
+	return llvm.StructType(target.llvmUnionBody(t, ctx), false)
+}
+
+// The payload is an integer array which has the size of the largest variant
+// and the alignment of the strictest variant.
+func (target *llvmTarget) llvmUnionBody(t *ir.UnionType, ctx *llvmTypeMap) []llvm.Type {
+	size := 0
+	align := 1
+	for _, variant := range t.Variants {
+		tvariant := target.llvmVariantType(variant, ctx)
+		if variantSize := int(target.data.TypeAllocSize(tvariant)); variantSize > size {
+			size = variantSize
+		}
+		if variantAlign := target.data.ABITypeAlignment(tvariant); variantAlign > align {
+			align = variantAlign
+		}
+	}
+	body := []llvm.Type{llvmUnionTagType()}
+	if size > 0 {
+		telem := llvm.IntType(align * 8)
+		body = append(body, llvm.ArrayType(telem, (size+align-1)/align))
+	}
+	return body
+}
+
+func (target *llvmTarget) llvmVariantType(variant ir.Variant, ctx *llvmTypeMap) llvm.Type {
+	var fieldTypes []llvm.Type
+	for _, field := range variant.Fields {
+		fieldTypes = append(fieldTypes, target.llvmType(field.T, ctx))
 	}
 	return llvm.StructType(fieldTypes, false)
 }
 
-func llvmArrayType(t *ir.ArrayType, ctx *llvmTypeMap) llvm.Type {
-	telem := llvmType(t.Elem, ctx)
+func llvmUnionTagType() llvm.Type {
+	return llvm.Int32Type()
+}
+
+func (target *llvmTarget) llvmArrayType(t *ir.ArrayType, ctx *llvmTypeMap) llvm.Type {
+	telem := target.llvmType(t.Elem, ctx)
 	return llvm.ArrayType(telem, t.Size)
 }
 
-func llvmSliceType(t *ir.SliceType, ctx *llvmTypeMap) llvm.Type {
-	telem := llvmType(t.Elem, ctx)
+// Tuples are anonymous structs.
+func (target *llvmTarget) llvmTupleType(t *ir.TupleType, ctx *llvmTypeMap) llvm.Type {
+	var elemTypes []llvm.Type
+	for _, elem := range t.Elems {
+		elemTypes = append(elemTypes, target.llvmType(elem, ctx))
+	}
+	return llvm.StructType(elemTypes, false)
+}
+
+// A result is a flag which is true if the result is ok, followed by the value and the error.
+// A result without a value has an empty struct in place of the value.
+func (target *llvmTarget) llvmResultType(t *ir.ResultType, ctx *llvmTypeMap) llvm.Type {
+	tval := llvm.StructType(nil, false)
+	if t.T.Kind() != ir.TVoid {
+		tval = target.llvmType(t.T, ctx)
+	}
+	return llvm.StructType([]llvm.Type{llvm.Int1Type(), tval, target.llvmType(t.E, ctx)}, false)
+}
+
+func (target *llvmTarget) llvmSliceType(t *ir.SliceType, ctx *llvmTypeMap) llvm.Type {
+	telem := target.llvmType(t.Elem, ctx)
 	tptr := llvm.PointerType(telem, 0)
 	tsize := llvmSizeType()
 	return llvm.StructType([]llvm.Type{tptr, tsize}, false)
 }
 
-func llvmPointerType(t *ir.PointerType, ctx *llvmTypeMap) llvm.Type {
+func (target *llvmTarget) llvmPointerType(t *ir.PointerType, ctx *llvmTypeMap) llvm.Type {
 	var telem llvm.Type
-	if t.Elem.Kind() == ir.TVoid {
+	if t.Elem.Kind() == ir.TVoid || ctx == nil {
+		// The element type doesn't affect the size of the pointer
 		telem = llvm.Int8Type()
 	} else {
-		telem = llvmType(t.Elem, ctx)
+		telem = target.llvmType(t.Elem, ctx)
 	}
 	return llvm.PointerType(telem, 0)
 }
 
-func llvmFuncType(t *ir.FuncType, ctx *llvmTypeMap) llvm.Type {
+func (target *llvmTarget) llvmFuncType(t *ir.FuncType, ctx *llvmTypeMap) llvm.Type {
 	var params []llvm.Type
 	for _, param := range t.Params {
-		params = append(params, llvmType(param.T, ctx))
+		params = append(params, target.llvmType(param.T, ctx))
+	}
+	ret := target.llvmType(t.Return, ctx)
+	return llvm.PointerType(llvm.FunctionType(ret, params, t.Variadic), 0)
+}
+
+// A closure is a pair of an untyped function pointer and an environment pointer.
+// The environment is null if the function doesn't take an environment parameter.
+func llvmClosureType() llvm.Type {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	return llvm.StructType([]llvm.Type{tptr, tptr}, false)
+}
+
+// An interface is a pair of a pointer to the data and a pointer to the method table.
+// The method table is an array of untyped function pointers, in the order the methods are declared.
+func llvmInterfaceType() llvm.Type {
+	tptr := llvm.PointerType(llvm.Int8Type(), 0)
+	return llvm.StructType([]llvm.Type{tptr, llvm.PointerType(tptr, 0)}, false)
+}
+
+// llvmEnvFuncType is the type of a function which takes an environment as the first parameter.
+func (target *llvmTarget) llvmEnvFuncType(t *ir.FuncType, ctx *llvmTypeMap) llvm.Type {
+	params := []llvm.Type{llvm.PointerType(llvm.Int8Type(), 0)}
+	for _, param := range t.Params {
+		params = append(params, target.llvmType(param.T, ctx))
 	}
-	ret := llvmType(t.Return, ctx)
+	ret := target.llvmType(t.Return, ctx)
 	return llvm.PointerType(llvm.FunctionType(ret, params, false), 0)
 }
 
-func llvmType(t1 ir.Type, ctx *llvmTypeMap) llvm.Type {
+func (target *llvmTarget) llvmType(t1 ir.Type, ctx *llvmTypeMap) llvm.Type {
 	switch t2 := t1.(type) {
 	case *ir.AliasType:
-		return llvmType(t2.T, ctx)
+		return target.llvmType(t2.T, ctx)
 	case *ir.BasicType:
 		return llvmBasicType(t2.Kind())
 	case *ir.StructType:
-		return llvmStructType(t2, ctx)
+		return target.llvmStructType(t2, ctx)
+	case *ir.UnionType:
+		return target.llvmUnionType(t2, ctx)
+	case *ir.EnumType:
+		return target.llvmType(t2.Backing, ctx)
 	case *ir.ArrayType:
-		return llvmArrayType(t2, ctx)
+		return target.llvmArrayType(t2, ctx)
+	case *ir.TupleType:
+		return target.llvmTupleType(t2, ctx)
 	case *ir.SliceType:
-		return llvmSliceType(t2, ctx)
+		return target.llvmSliceType(t2, ctx)
 	case *ir.PointerType:
-		return llvmPointerType(t2, ctx)
+		return target.llvmPointerType(t2, ctx)
 	case *ir.FuncType:
-		return llvmFuncType(t2, ctx)
+		return target.llvmFuncType(t2, ctx)
+	case *ir.ClosureType:
+		return llvmClosureType()
+	case *ir.InterfaceType:
+		return llvmInterfaceType()
+	case *ir.ResultType:
+		return target.llvmResultType(t2, ctx)
 	default:
 		panic(fmt.Sprintf("Unhandled type %s", t2))
 	}
 }
 
 func isExternalLLVMLinkage(sym *ir.Symbol) bool {
-	if sym.Public || !sym.IsDefined() || sym.ABI != ir.DGABI {
+	if sym.Public || !sym.IsDefined() || sym.ABI != ir.DGABI || sym.RuntimeHandler() != "" {
 		return true
 	}
 	return false
@@ -168,18 +357,100 @@ func llvmLinkage(sym *ir.Symbol) llvm.Linkage {
 }
 
 func mangle(sym *ir.Symbol) string {
+	if attr := sym.Attribute(ir.AttrLinkName); attr != nil {
+		return attr.StringArg(0)
+	}
 	if sym.ABI == ir.CABI {
 		return sym.Name
 	}
 	var b bytes.Buffer
 	b.WriteString("_ZN")
 	b.WriteString(mangleFQN(sym.ModFQN))
-	b.WriteString(fmt.Sprintf("%d", len(sym.Name)))
-	b.WriteString(sym.Name)
+	b.WriteString(mangleName(sym))
 	b.WriteString("E")
 	return b.String()
 }
 
+// Instances of generic declarations are mangled with the name of the
+// generic declaration followed by the type arguments.
+func mangleName(sym *ir.Symbol) string {
+	name := sym.Name
+	if sym.Generic != nil {
+		name = sym.Generic.Name
+	}
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("%d", len(name)))
+	b.WriteString(name)
+	if len(sym.TypeArgs) > 0 {
+		b.WriteString("I")
+		for _, targ := range sym.TypeArgs {
+			b.WriteString(mangleType(targ))
+		}
+		b.WriteString("E")
+	}
+	return b.String()
+}
+
+func mangleType(t ir.Type) string {
+	switch t := ir.ToBaseType(t).(type) {
+	case *ir.StructType:
+		return mangleNamedType(t.Sym)
+	case *ir.UnionType:
+		return mangleNamedType(t.Sym)
+	case *ir.EnumType:
+		return mangleNamedType(t.Sym)
+	case *ir.PointerType:
+		if t.Raw {
+			return mangleQualifier("R", t.ReadOnly) + mangleType(t.Elem)
+		} else if t.Nullable {
+			return mangleQualifier("Q", t.ReadOnly) + mangleType(t.Elem)
+		}
+		return mangleQualifier("P", t.ReadOnly) + mangleType(t.Elem)
+	case *ir.SliceType:
+		return mangleQualifier("S", t.ReadOnly) + mangleType(t.Elem)
+	case *ir.ArrayType:
+		return fmt.Sprintf("A%d_%s", t.Size, mangleType(t.Elem))
+	case *ir.TupleType:
+		var b bytes.Buffer
+		b.WriteString("T")
+		for _, elem := range t.Elems {
+			b.WriteString(mangleType(elem))
+		}
+		b.WriteString("E")
+		return b.String()
+	case *ir.FuncType:
+		var b bytes.Buffer
+		b.WriteString("F")
+		b.WriteString(mangleType(t.Return))
+		for _, param := range t.Params {
+			b.WriteString(mangleType(param.T))
+		}
+		if t.Variadic {
+			b.WriteString("V")
+		}
+		b.WriteString("E")
+		return b.String()
+	case *ir.ClosureType:
+		return "C" + mangleType(t.F)
+	case *ir.ResultType:
+		return "X" + mangleType(t.T) + mangleType(t.E)
+	default:
+		name := t.String()
+		return fmt.Sprintf("%d%s", len(name), name)
+	}
+}
+
+func mangleNamedType(sym *ir.Symbol) string {
+	return "N" + mangleFQN(sym.ModFQN) + mangleName(sym) + "E"
+}
+
+func mangleQualifier(prefix string, readOnly bool) string {
+	if readOnly {
+		return prefix + "K"
+	}
+	return prefix
+}
+
 func mangleFQN(fqn string) string {
 	split := strings.Split(fqn, token.ScopeSep.String())
 	var b bytes.Buffer
@@ -18,13 +18,46 @@ type BuildContext struct {
 	Verbose         bool
 	LLVMIR          bool
 	Exe             string
+	BoundsCheck     bool         // Check index and slice expressions at runtime
+	Overflow        OverflowMode // Behavior of integer arithmetic which overflows at runtime
+}
+
+// OverflowMode determines what happens when integer addition, subtraction or multiplication overflows at runtime.
+type OverflowMode int
+
+// List of overflow modes.
+const (
+	OverflowWrap  OverflowMode = iota // Wrap around (two's complement)
+	OverflowTrap                      // Execute a trap instruction
+	OverflowPanic                     // Panic with a message
+)
+
+var overflowModes = [...]string{
+	OverflowWrap:  "wrap",
+	OverflowTrap:  "trap",
+	OverflowPanic: "panic",
+}
+
+func (m OverflowMode) String() string {
+	return overflowModes[m]
+}
+
+// ParseOverflowMode returns the overflow mode with the given name.
+func ParseOverflowMode(name string) (OverflowMode, bool) {
+	for i, mode := range overflowModes {
+		if mode == name {
+			return OverflowMode(i), true
+		}
+	}
+	return OverflowWrap, false
 }
 
 func NewBuildContext(cwd string) *BuildContext {
 	return &BuildContext{
-		Cwd:     cwd,
-		FileMap: make(map[string]*token.File),
-		Errors:  &ErrorList{},
+		Cwd:         cwd,
+		FileMap:     make(map[string]*token.File),
+		Errors:      &ErrorList{},
+		BoundsCheck: true,
 	}
 }
 
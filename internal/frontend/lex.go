@@ -71,6 +71,13 @@ func (l *lexer) lex() (token.Token, token.Position, string) {
 		l.next()
 		if isDigit(l.ch, 10) {
 			tok = l.lexNumber(true)
+		} else if l.isDotDot() {
+			l.next()
+			l.next()
+			tok = token.Ellipsis
+		} else if l.ch == '.' {
+			l.next()
+			tok = token.DotDot
 		} else {
 			tok = token.Dot
 		}
@@ -139,13 +146,33 @@ func (l *lexer) lex() (token.Token, token.Position, string) {
 		case '=':
 			tok = l.lexAltEqual(token.Eq, token.Assign)
 		case '&':
-			tok = token.Reference
+			tok = l.lexAltEqual(token.BitAndAssign, token.Reference)
+		case '|':
+			tok = l.lexAltEqual(token.BitOrAssign, token.BitOr)
+		case '^':
+			tok = l.lexAltEqual(token.BitXorAssign, token.BitXor)
+		case '~':
+			tok = token.BitNot
+		case '?':
+			tok = token.Nullable
+		case '#':
+			tok = token.Hash
 		case '!':
-			tok = l.lexAltEqual(token.Neq, token.Invalid)
+			tok = l.lexAltEqual(token.Neq, token.Fallible)
 		case '>':
-			tok = l.lexAltEqual(token.GtEq, token.Gt)
+			if l.ch == '>' {
+				l.next()
+				tok = l.lexAltEqual(token.ShrAssign, token.Shr)
+			} else {
+				tok = l.lexAltEqual(token.GtEq, token.Gt)
+			}
 		case '<':
-			tok = l.lexAltEqual(token.LtEq, token.Lt)
+			if l.ch == '<' {
+				l.next()
+				tok = l.lexAltEqual(token.ShlAssign, token.Shl)
+			} else {
+				tok = l.lexAltEqual(token.LtEq, token.Lt)
+			}
 		default:
 			tok = token.Invalid
 		}
@@ -299,6 +326,11 @@ func (l *lexer) lexDigits(base int) int {
 	return count
 }
 
+// isDotDot returns true if the current and next character are '..'.
+func (l *lexer) isDotDot() bool {
+	return l.ch == '.' && l.readOffset < len(l.src) && l.src[l.readOffset] == '.'
+}
+
 func isFractionOrExponent(ch rune) bool {
 	return ch == '.' || ch == 'e' || ch == 'E'
 }
@@ -322,12 +354,12 @@ func (l *lexer) lexNumber(float bool) token.Token {
 					l.error(l.newPos(), "hexadecimal float literal is not supported")
 				}
 				return id
-			} else if l.ch != '.' {
+			} else if l.ch != '.' || l.isDotDot() {
 				// Octal
 				l.lexDigits(8)
 				if isDigit(l.ch, 10) {
 					l.error(l.newPos(), "invalid octal literal")
-				} else if isFractionOrExponent(l.ch) {
+				} else if isFractionOrExponent(l.ch) && !l.isDotDot() {
 					l.error(l.newPos(), "octal float literal is not supported")
 				}
 				return id
@@ -336,7 +368,8 @@ func (l *lexer) lexNumber(float bool) token.Token {
 
 		l.lexDigits(10)
 
-		if l.ch == '.' {
+		// The integer can be the start of a range
+		if l.ch == '.' && !l.isDotDot() {
 			id = token.Float
 			l.next()
 
@@ -20,7 +20,6 @@ func parseFile(filename string, src []byte) (*ir.File, error) {
 	p.file.Modules = append(p.file.Modules, mod)
 
 	p.parseModuleBody(mod, 0, false)
-	p.file.Modules[0].Decls = append(p.file.Modules[0].Decls, p.anonDecls...)
 
 	if p.errors.IsError() {
 		return p.file, p.errors
@@ -43,7 +42,7 @@ type parser struct {
 
 	blockCount int
 	funcName   string
-	anonDecls  []*ir.TopDecl
+	pattern    bool // True while parsing a match pattern, where placeholders are allowed
 }
 
 func newParser(filename string, src []byte) *parser {
@@ -92,7 +91,7 @@ func (p *parser) syncTopDecl() {
 		switch p.token {
 		case token.Public, token.Private,
 			token.Include, token.Module, token.Import, token.Use,
-			token.Var, token.Val, token.Func, token.Struct, token.Typealias:
+			token.Var, token.Val, token.Func, token.Struct, token.Union, token.Enum, token.Interface, token.Typealias, token.StaticAssert:
 			if semi && lbrace == 0 {
 				return
 			}
@@ -273,6 +272,12 @@ func (p *parser) parseTopDecl(visibility token.Token) (topDecl *ir.TopDecl) {
 		}
 	}()
 
+	attributes := p.parseAttributes()
+	if len(attributes) > 0 && p.token.OneOf(token.Public, token.Private) {
+		visibility = p.token
+		p.next()
+	}
+
 	var abi *ir.Ident
 	var decl ir.Decl
 
@@ -291,23 +296,71 @@ func (p *parser) parseTopDecl(visibility token.Token) (topDecl *ir.TopDecl) {
 	} else if p.token.Is(token.Func) {
 		decl = p.parseFuncDecl()
 		p.expectSemi()
-	} else if p.token.Is(token.Struct) {
+	} else if p.token.OneOf(token.Struct, token.CUnion) {
 		decl = p.parseStructDecl()
 		p.expectSemi()
+	} else if p.token.Is(token.Union) {
+		decl = p.parseUnionDecl()
+		p.expectSemi()
+	} else if p.token.Is(token.Enum) {
+		decl = p.parseEnumDecl()
+		p.expectSemi()
+	} else if p.token.Is(token.Interface) {
+		decl = p.parseInterfaceDecl()
+		p.expectSemi()
 	} else if p.token.Is(token.Import) {
 		decl = p.parseImportDecl()
+	} else if p.token.Is(token.StaticAssert) {
+		decl = p.parseStaticAssertDecl()
+		p.expectSemi()
 	} else {
 		decl = p.parseDecl()
 		p.expectSemi()
 	}
 
 	if decl != nil {
-		return ir.NewTopDecl(abi, visibility, decl)
+		return ir.NewTopDecl(abi, visibility, attributes, decl)
 	}
 
 	return nil
 }
 
+// Attributes are written as '#[name]' or '#[name(args)]' before a declaration.
+// Several attributes can be listed in one pair of brackets.
+func (p *parser) parseAttributes() []*ir.Attribute {
+	var attributes []*ir.Attribute
+	for p.token.Is(token.Hash) {
+		p.next()
+		p.expect(token.Lbrack)
+		for !p.token.OneOf(token.EOF, token.Rbrack) {
+			attr := &ir.Attribute{}
+			attr.Name = p.parseIdent()
+			attr.SetRange(attr.Name.Pos(), attr.Name.EndPos())
+			if p.token.Is(token.Lparen) {
+				p.next()
+				for !p.token.OneOf(token.EOF, token.Rparen) {
+					attr.Args = append(attr.Args, p.parseExpr())
+					if !p.token.Is(token.Rparen) {
+						p.expect(token.Comma, token.Rparen)
+					}
+				}
+				attr.SetEndPos(p.endPos())
+				p.expect(token.Rparen)
+			}
+			attributes = append(attributes, attr)
+			if !p.token.Is(token.Rbrack) {
+				p.expect(token.Comma, token.Rbrack)
+			}
+		}
+		p.expect(token.Rbrack)
+		if p.token.Is(token.Semicolon) && p.literal == "\n" {
+			// The declaration is on the next line
+			p.next()
+		}
+	}
+	return attributes
+}
+
 func (p *parser) parseExtern() *ir.Ident {
 	var abi *ir.Ident
 	if p.token.Is(token.Extern) {
@@ -328,9 +381,11 @@ func (p *parser) parseExtern() *ir.Ident {
 func (p *parser) parseStructDecl() *ir.StructDecl {
 	decl := &ir.StructDecl{}
 	decl.SetPos(p.pos)
+	decl.CUnion = p.token.Is(token.CUnion)
 	p.next()
 	decl.Name = p.parseIdent()
 	decl.SetEndPos(decl.Name.EndPos())
+	decl.TypeParams = p.parseTypeParams()
 	if p.isSemi() {
 		decl.Opaque = true
 	} else {
@@ -338,6 +393,7 @@ func (p *parser) parseStructDecl() *ir.StructDecl {
 		p.expect(token.Lbrace)
 		p.blockCount++
 		for !p.token.OneOf(token.EOF, token.Rbrace) {
+			attributes := p.parseAttributes()
 			flags := 0
 			if p.token.OneOf(token.Public, token.Private) {
 				if p.token.Is(token.Public) {
@@ -348,10 +404,12 @@ func (p *parser) parseStructDecl() *ir.StructDecl {
 			if p.token.Is(token.Func) {
 				fun := p.parseFuncDecl()
 				fun.Flags = flags
+				fun.Attributes = attributes
 				decl.Methods = append(decl.Methods, fun)
 			} else {
 				field := p.parseValDecl()
 				field.Flags |= flags | ir.AstFlagNoInit | ir.AstFlagField
+				field.Attributes = attributes
 				decl.Fields = append(decl.Fields, field)
 			}
 			p.expectSemi()
@@ -362,12 +420,103 @@ func (p *parser) parseStructDecl() *ir.StructDecl {
 	return decl
 }
 
+func (p *parser) parseUnionDecl() *ir.UnionDecl {
+	decl := &ir.UnionDecl{}
+	decl.SetPos(p.pos)
+	p.next()
+	decl.Name = p.parseIdent()
+	p.expect(token.Lbrace)
+	p.blockCount++
+	for !p.token.OneOf(token.EOF, token.Rbrace) {
+		variant := &ir.UnionVariant{}
+		variant.Name = p.parseIdent()
+		variant.SetRange(variant.Name.Pos(), variant.Name.EndPos())
+		if p.token.Is(token.Lparen) {
+			p.next()
+			for !p.token.OneOf(token.EOF, token.Rparen) {
+				field := p.parseFuncParam()
+				field.Flags |= ir.AstFlagNoInit | ir.AstFlagField
+				variant.Fields = append(variant.Fields, field)
+				if !p.token.Is(token.Rparen) {
+					p.expect(token.Comma, token.Rparen)
+				}
+			}
+			variant.SetEndPos(p.endPos())
+			p.expect(token.Rparen)
+		}
+		decl.Variants = append(decl.Variants, variant)
+		if p.token.OneOf(token.Comma, token.Semicolon) {
+			p.next()
+		} else if !p.token.Is(token.Rbrace) {
+			p.expect(token.Rbrace, token.Comma, token.Semicolon)
+		}
+	}
+	decl.SetEndPos(p.pos)
+	p.expect(token.Rbrace)
+	p.blockCount--
+	return decl
+}
+
+func (p *parser) parseEnumDecl() *ir.EnumDecl {
+	decl := &ir.EnumDecl{}
+	decl.SetPos(p.pos)
+	p.next()
+	decl.Name = p.parseIdent()
+	if p.token.Is(token.Colon) {
+		p.next()
+		decl.Backing = p.parseType()
+	}
+	p.expect(token.Lbrace)
+	p.blockCount++
+	for !p.token.OneOf(token.EOF, token.Rbrace) {
+		member := &ir.EnumMember{}
+		member.Name = p.parseIdent()
+		member.SetRange(member.Name.Pos(), member.Name.EndPos())
+		if p.token.Is(token.Assign) {
+			p.next()
+			member.Value = p.parseExpr()
+			member.SetEndPos(member.Value.EndPos())
+		}
+		decl.Members = append(decl.Members, member)
+		if p.token.OneOf(token.Comma, token.Semicolon) {
+			p.next()
+		} else if !p.token.Is(token.Rbrace) {
+			p.expect(token.Rbrace, token.Comma, token.Semicolon)
+		}
+	}
+	decl.SetEndPos(p.pos)
+	p.expect(token.Rbrace)
+	p.blockCount--
+	return decl
+}
+
+func (p *parser) parseInterfaceDecl() *ir.InterfaceDecl {
+	decl := &ir.InterfaceDecl{}
+	decl.SetPos(p.pos)
+	p.next()
+	decl.Name = p.parseIdent()
+	p.expect(token.Lbrace)
+	p.blockCount++
+	for !p.token.OneOf(token.EOF, token.Rbrace) {
+		if !p.token.Is(token.Func) {
+			p.expect(token.Func)
+		}
+		decl.Methods = append(decl.Methods, p.parseFuncDecl())
+		p.expectSemi()
+	}
+	decl.SetEndPos(p.pos)
+	p.expect(token.Rbrace)
+	p.blockCount--
+	return decl
+}
+
 func (p *parser) parseFuncDecl() *ir.FuncDecl {
 	decl := &ir.FuncDecl{}
 	decl.SetPos(p.pos)
 	p.next()
 	decl.Name = p.parseIdent()
-	decl.Params, decl.Return = p.parseFuncSignature()
+	decl.TypeParams = p.parseTypeParams()
+	decl.Params, decl.Variadic, decl.Return = p.parseFuncSignature()
 	decl.SetEndPos(decl.Return.EndPos())
 	if p.isSemi() {
 		return decl
@@ -376,6 +525,24 @@ func (p *parser) parseFuncDecl() *ir.FuncDecl {
 	return decl
 }
 
+func (p *parser) parseTypeParams() []*ir.Ident {
+	var params []*ir.Ident
+	if p.token.Is(token.Lbrack) {
+		p.next()
+		for !p.token.OneOf(token.EOF, token.Rbrack) {
+			params = append(params, p.parseIdent())
+			if !p.token.Is(token.Rbrack) {
+				p.expect(token.Comma, token.Rbrack)
+			}
+		}
+		p.expect(token.Rbrack)
+		if len(params) == 0 {
+			p.error(p.pos, "expected at least one type parameter")
+		}
+	}
+	return params
+}
+
 func (p *parser) parseDecl() ir.Decl {
 	var decl ir.Decl
 	if p.token.Is(token.Use) {
@@ -391,6 +558,25 @@ func (p *parser) parseDecl() ir.Decl {
 	return decl
 }
 
+func (p *parser) parseStaticAssertDecl() *ir.StaticAssertDecl {
+	decl := &ir.StaticAssertDecl{}
+	decl.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	decl.Cond = p.parseExpr()
+	if p.token.Is(token.Comma) {
+		p.next()
+		if p.token.Is(token.String) {
+			decl.Msg = p.parseBasicLit(nil).(*ir.BasicLit)
+		} else {
+			p.expect(token.String)
+		}
+	}
+	decl.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return decl
+}
+
 func (p *parser) parseImportDecl() *ir.ImportDecl {
 	decl := &ir.ImportDecl{}
 	decl.SetRange(p.pos, p.pos)
@@ -495,6 +681,67 @@ func (p *parser) parseValDecl() *ir.ValDecl {
 	decl.SetPos(p.pos)
 	p.next()
 	decl.Name = p.parseIdent()
+	p.parseValDeclTail(decl)
+	return decl
+}
+
+// parseValDeclStmt parses a local variable declaration,
+// which can also destructure a tuple: val (a, b) = f().
+func (p *parser) parseValDeclStmt() ir.Stmt {
+	decl := &ir.ValDecl{}
+	decl.Decl = p.token
+	decl.SetPos(p.pos)
+	p.next()
+
+	if !p.token.Is(token.Lparen) {
+		decl.Name = p.parseIdent()
+		p.parseValDeclTail(decl)
+		stmt := &ir.DeclStmt{D: decl}
+		stmt.SetRange(decl.Pos(), decl.EndPos())
+		return stmt
+	}
+
+	stmt := &ir.DestructureStmt{Tuple: decl}
+	p.next()
+	for {
+		elem := &ir.ValDecl{Decl: decl.Decl}
+		if p.token.Is(token.Placeholder) {
+			elem.Name = ir.NewIdent2(p.token, p.literal)
+			elem.Name.SetRange(p.pos, p.endPos())
+			p.next()
+		} else {
+			elem.Name = p.parseIdent()
+		}
+		elem.SetRange(elem.Name.Pos(), elem.Name.EndPos())
+		tuple := ir.NewIdent2(token.Placeholder, token.Placeholder.String())
+		tuple.SetRange(elem.Name.Pos(), elem.Name.EndPos())
+		init := &ir.TupleElemExpr{X: tuple, Index: len(stmt.Elems)}
+		init.SetRange(elem.Name.Pos(), elem.Name.EndPos())
+		elem.Initializer = init
+		stmt.Elems = append(stmt.Elems, elem)
+		if !p.token.Is(token.Comma) {
+			break
+		}
+		p.next()
+	}
+	p.expect(token.Rparen)
+
+	// The tuple itself is always immutable and cannot be referenced by name
+	decl.Decl = token.Val
+	decl.Name = ir.NewIdent2(token.Placeholder, token.Placeholder.String())
+	decl.Name.SetRange(decl.Pos(), decl.Pos())
+	if p.token.Is(token.Colon) {
+		p.next()
+		decl.Type = p.parseType()
+	}
+	p.expect(token.Assign)
+	decl.Initializer = p.parseExpr()
+	decl.SetEndPos(decl.Initializer.EndPos())
+	stmt.SetRange(decl.Pos(), decl.EndPos())
+	return stmt
+}
+
+func (p *parser) parseValDeclTail(decl *ir.ValDecl) {
 	if p.token.Is(token.Colon) {
 		p.next()
 		decl.Type = p.parseType()
@@ -509,11 +756,11 @@ func (p *parser) parseValDecl() *ir.ValDecl {
 		p.error(p.pos, "expected type or assignment")
 		panic(parseError(0))
 	}
-	return decl
 }
 
 func (p *parser) parseFuncParam() *ir.ValDecl {
 	decl := &ir.ValDecl{}
+	decl.Attributes = p.parseAttributes()
 	decl.SetPos(p.pos)
 	decl.Flags = ir.AstFlagNoInit
 	decl.Decl = token.Val
@@ -549,19 +796,39 @@ func (p *parser) parseFuncParam() *ir.ValDecl {
 
 	decl.SetEndPos(decl.Type.EndPos())
 
+	if p.token.Is(token.Assign) {
+		p.next()
+		decl.Initializer = p.parseExpr()
+		decl.SetEndPos(decl.Initializer.EndPos())
+	}
+
 	return decl
 }
 
-func (p *parser) parseFuncSignature() (params []*ir.ValDecl, ret *ir.ValDecl) {
+// The variadic marker must be the last parameter.
+func (p *parser) parseFuncParamOrEllipsis(params *[]*ir.ValDecl) bool {
+	if p.token.Is(token.Ellipsis) {
+		p.next()
+		if !p.token.Is(token.Rparen) {
+			p.error(p.pos, "'%s' must be the last parameter", token.Ellipsis)
+			panic(parseError(0))
+		}
+		return true
+	}
+	*params = append(*params, p.parseFuncParam())
+	return false
+}
+
+func (p *parser) parseFuncSignature() (params []*ir.ValDecl, variadic bool, ret *ir.ValDecl) {
 	p.expect(token.Lparen)
 	if !p.token.Is(token.Rparen) {
-		params = append(params, p.parseFuncParam())
-		for !p.token.OneOf(token.EOF, token.Rparen) {
+		variadic = p.parseFuncParamOrEllipsis(&params)
+		for !variadic && !p.token.OneOf(token.EOF, token.Rparen) {
 			p.expect(token.Comma)
 			if p.token.Is(token.Rparen) {
 				break
 			}
-			params = append(params, p.parseFuncParam())
+			variadic = p.parseFuncParamOrEllipsis(&params)
 		}
 	}
 	endPos := p.pos
@@ -595,24 +862,41 @@ func (p *parser) parseStmt() (stmt ir.Stmt, sync bool) {
 		stmt = nil
 	} else if p.token.Is(token.Lbrace) {
 		stmt = p.parseBlockStmt()
-	} else if p.token.OneOf(token.Use, token.Typealias, token.Var, token.Val) {
+	} else if p.token.OneOf(token.Var, token.Val) {
+		stmt = p.parseValDeclStmt()
+	} else if p.token.OneOf(token.Use, token.Typealias) {
 		d := p.parseDecl()
 		stmt = &ir.DeclStmt{D: d}
 		stmt.SetRange(d.Pos(), d.EndPos())
+	} else if p.token.Is(token.StaticAssert) {
+		d := p.parseStaticAssertDecl()
+		stmt = &ir.StaticAssertStmt{D: d}
+		stmt.SetRange(d.Pos(), d.EndPos())
 	} else if p.token.Is(token.If) {
 		stmt = p.parseIfStmt()
+	} else if p.token.Is(token.Match) {
+		stmt = p.parseMatchStmt()
 	} else if p.token.Is(token.While) {
 		stmt = p.parseWhileStmt()
 	} else if p.token.Is(token.For) {
 		stmt = p.parseForStmt()
 	} else if p.token.Is(token.Return) {
 		stmt = p.parseReturnStmt()
-	} else if p.token.Is(token.Defer) {
+	} else if p.token.Is(token.Panic) {
+		stmt = p.parsePanicStmt()
+	} else if p.token.Is(token.Assert) {
+		stmt = p.parseAssertStmt()
+	} else if p.token.OneOf(token.Defer, token.Errdefer) {
 		stmt = p.parseDeferStmt()
 	} else if p.token.OneOf(token.Break, token.Continue) {
-		stmt = &ir.BranchStmt{Tok: p.token}
-		stmt.SetPos(p.pos)
+		branch := &ir.BranchStmt{Tok: p.token}
+		branch.SetRange(p.pos, p.endPos())
 		p.next()
+		if p.token.Is(token.Ident) {
+			branch.Label = p.parseIdent()
+			branch.SetEndPos(branch.Label.EndPos())
+		}
+		stmt = branch
 	} else {
 		stmt = p.parseExprStmt()
 	}
@@ -687,6 +971,48 @@ func (p *parser) parseIfStmt() *ir.IfStmt {
 	return s
 }
 
+func (p *parser) parseMatchStmt() *ir.MatchStmt {
+	s := &ir.MatchStmt{}
+	s.SetPos(p.pos)
+	p.next()
+	s.X = p.parseExpr()
+	p.expect(token.Lbrace)
+	p.blockCount++
+	for !p.token.OneOf(token.Rbrace, token.EOF) {
+		if p.isSemi() {
+			p.next()
+			continue
+		}
+		if p.token.Is(token.Else) {
+			if s.Else != nil {
+				p.error(p.pos, "duplicate else arm in match")
+			}
+			p.next()
+			s.Else = p.parseBlockStmt()
+			continue
+		}
+		if s.Else != nil {
+			p.error(p.pos, "else must be the last arm in match")
+		}
+		arm := &ir.MatchArm{}
+		arm.SetPos(p.pos)
+		p.pattern = true
+		arm.Patterns = append(arm.Patterns, p.parseExpr())
+		for p.token.Is(token.Comma) {
+			p.next()
+			arm.Patterns = append(arm.Patterns, p.parseExpr())
+		}
+		p.pattern = false
+		arm.Body = p.parseBlockStmt()
+		arm.SetEndPos(arm.Body.EndPos())
+		s.Arms = append(s.Arms, arm)
+	}
+	s.SetEndPos(p.pos)
+	p.expect(token.Rbrace)
+	p.blockCount--
+	return s
+}
+
 func (p *parser) parseWhileStmt() *ir.ForStmt {
 	s := &ir.ForStmt{}
 	s.Tok = p.token
@@ -703,11 +1029,19 @@ func (p *parser) parseForStmt() *ir.ForStmt {
 	s.Tok = p.token
 	s.SetPos(p.pos)
 	p.next()
-	if p.token != token.Semicolon {
+	if p.token.Is(token.Reference) {
+		s.Range = p.parseRangeClause(nil)
+	} else if p.token != token.Semicolon {
 		decl := &ir.ValDecl{}
 		s.SetPos(p.pos)
 		decl.Decl = token.Var
 		decl.Name = p.parseIdent()
+		if p.token.OneOf(token.Comma, token.In) {
+			s.Range = p.parseRangeClause(decl.Name)
+			s.Body = p.parseBlockStmt()
+			s.SetEndPos(s.Body.EndPos())
+			return s
+		}
 		if p.token.Is(token.Colon) {
 			p.next()
 			decl.Type = p.parseType()
@@ -717,6 +1051,11 @@ func (p *parser) parseForStmt() *ir.ForStmt {
 		s.Init = &ir.DeclStmt{D: decl}
 		s.Init.SetPos(decl.Pos())
 	}
+	if s.Range != nil {
+		s.Body = p.parseBlockStmt()
+		s.SetEndPos(s.Body.EndPos())
+		return s
+	}
 	p.expectSemi()
 	if p.token != token.Semicolon {
 		s.Cond = p.parseExpr()
@@ -730,6 +1069,42 @@ func (p *parser) parseForStmt() *ir.ForStmt {
 	return s
 }
 
+// The first identifier is already parsed if the element isn't a reference.
+func (p *parser) parseRangeClause(name *ir.Ident) *ir.RangeClause {
+	r := &ir.RangeClause{}
+	if name != nil && p.token.Is(token.Comma) {
+		r.Index = newRangeVar(name)
+		p.next()
+		name = nil
+	}
+	if name == nil {
+		if p.token.Is(token.Reference) {
+			r.Ref = true
+			r.Immutable = true
+			p.next()
+			if p.token.OneOf(token.Val, token.Var) {
+				r.Immutable = p.token.Is(token.Val)
+				p.next()
+			}
+		}
+		name = p.parseIdent()
+	}
+	r.Elem = newRangeVar(name)
+	p.expect(token.In)
+	r.X = p.parseExpr()
+	if p.token.Is(token.DotDot) {
+		p.next()
+		r.End = p.parseExpr()
+	}
+	return r
+}
+
+func newRangeVar(name *ir.Ident) *ir.ValDecl {
+	decl := &ir.ValDecl{Decl: token.Val, Name: name}
+	decl.SetRange(name.Pos(), name.EndPos())
+	return decl
+}
+
 func (p *parser) parseReturnStmt() *ir.ReturnStmt {
 	s := &ir.ReturnStmt{}
 	s.SetRange(p.pos, p.pos)
@@ -740,8 +1115,34 @@ func (p *parser) parseReturnStmt() *ir.ReturnStmt {
 	return s
 }
 
+func (p *parser) parsePanicStmt() *ir.PanicStmt {
+	s := &ir.PanicStmt{}
+	s.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	s.X = p.parseExpr()
+	s.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return s
+}
+
+func (p *parser) parseAssertStmt() *ir.AssertStmt {
+	s := &ir.AssertStmt{}
+	s.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	s.Cond = p.parseExpr()
+	if p.token.Is(token.Comma) {
+		p.next()
+		s.Msg = p.parseExpr()
+	}
+	s.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return s
+}
+
 func (p *parser) parseDeferStmt() *ir.DeferStmt {
-	s := &ir.DeferStmt{}
+	s := &ir.DeferStmt{Tok: p.token}
 	s.SetRange(p.pos, p.pos)
 	p.next()
 	s.S = p.parseExprStmt()
@@ -751,6 +1152,9 @@ func (p *parser) parseDeferStmt() *ir.DeferStmt {
 func (p *parser) parseExprStmt() ir.Stmt {
 	var stmt ir.Stmt
 	expr := p.parseExpr()
+	if label, ok := expr.(*ir.Ident); ok && p.token.Is(token.Colon) {
+		return p.parseLabeledStmt(label)
+	}
 	if p.token.IsAssignOp() || p.token.OneOf(token.Inc, token.Dec) {
 		assign := p.token
 		p.next()
@@ -772,15 +1176,58 @@ func (p *parser) parseExprStmt() ir.Stmt {
 	}
 	return stmt
 }
+
+// Only loops can be labeled.
+func (p *parser) parseLabeledStmt(label *ir.Ident) ir.Stmt {
+	p.next()
+	var s *ir.ForStmt
+	if p.token.Is(token.While) {
+		s = p.parseWhileStmt()
+	} else if p.token.Is(token.For) {
+		s = p.parseForStmt()
+	} else {
+		p.expect(token.For, token.While)
+		return nil
+	}
+	s.Label = label
+	s.SetPos(label.Pos())
+	return s
+}
+
 func (p *parser) parseType() ir.Expr {
 	return p.tryParseType(true)
 }
 
+// A result type binds looser than the other type constructors, e.g. '&T!E' is '(&T)!E'.
 func (p *parser) tryParseType(required bool) ir.Expr {
+	t := p.tryParseTypeOperand(required)
+	if t != nil && p.token.Is(token.Fallible) {
+		p.next()
+		e := p.parseTypeOperand()
+		res := &ir.ResultTypeExpr{Value: t, Error: e}
+		res.SetRange(t.Pos(), e.EndPos())
+		return res
+	}
+	return t
+}
+
+func (p *parser) parseTypeOperand() ir.Expr {
+	return p.tryParseTypeOperand(true)
+}
+
+func (p *parser) tryParseTypeOperand(required bool) ir.Expr {
 	if p.token.Is(token.Lparen) {
 		pos := p.pos
 		p.next()
 		t := p.parseType()
+		if p.token.Is(token.Comma) {
+			tuple := &ir.TupleTypeExpr{Elems: []ir.Expr{t}}
+			for p.token.Is(token.Comma) {
+				p.next()
+				tuple.Elems = append(tuple.Elems, p.parseType())
+			}
+			t = tuple
+		}
 		if t != nil {
 			p.expect(token.Rparen)
 			t.SetRange(pos, p.pos)
@@ -788,14 +1235,18 @@ func (p *parser) tryParseType(required bool) ir.Expr {
 		return t
 	} else if p.token.Is(token.Typeof) {
 		return p.parseTypeof()
-	} else if p.token.OneOf(token.Reference) {
+	} else if p.token.OneOf(token.Reference, token.Mul, token.Nullable) {
 		return p.parsePointerType()
 	} else if p.token.Is(token.Lbrack) {
 		return p.parseSliceOrArrayType()
-	} else if p.token.OneOf(token.Extern, token.Func) {
+	} else if p.token.OneOf(token.Extern, token.Func, token.Closure) {
 		return p.parseFuncType()
 	} else if p.token.OneOf(token.Ident, token.ScopeSep) {
-		return p.parseIdentExpr(nil)
+		expr := p.parseIdentExpr(nil)
+		if p.token.Is(token.Lbrack) {
+			return p.parseInstanceExpr(expr)
+		}
+		return expr
 	} else if required {
 		p.error(p.pos, "expected type")
 		panic(parseError(0))
@@ -803,6 +1254,21 @@ func (p *parser) tryParseType(required bool) ir.Expr {
 	return nil
 }
 
+func (p *parser) parseInstanceExpr(expr ir.Expr) *ir.InstanceExpr {
+	instance := &ir.InstanceExpr{X: expr}
+	instance.SetPos(expr.Pos())
+	p.expect(token.Lbrack)
+	for !p.token.OneOf(token.EOF, token.Rbrack) {
+		instance.Args = append(instance.Args, p.parseType())
+		if !p.token.Is(token.Rbrack) {
+			p.expect(token.Comma, token.Rbrack)
+		}
+	}
+	instance.SetEndPos(p.endPos())
+	p.expect(token.Rbrack)
+	return instance
+}
+
 func (p *parser) parseTypeof() ir.Expr {
 	typeof := &ir.Typeof{}
 	pos := p.pos
@@ -817,13 +1283,20 @@ func (p *parser) parseTypeof() ir.Expr {
 func (p *parser) parsePointerType() ir.Expr {
 	pointer := &ir.PointerTypeExpr{}
 	pos := p.pos
-	p.next()
+	if p.token.Is(token.Nullable) {
+		pointer.Nullable = true
+		p.next()
+		p.expect(token.Reference)
+	} else {
+		pointer.Raw = p.token.Is(token.Mul)
+		p.next()
+	}
 	pointer.Decl = token.Val
 	if p.token.OneOf(token.Var, token.Val) {
 		pointer.Decl = p.token
 		p.next()
 	}
-	pointer.X = p.parseType()
+	pointer.X = p.parseTypeOperand()
 	pointer.SetRange(pos, p.pos)
 	return pointer
 }
@@ -849,13 +1322,18 @@ func (p *parser) parseFuncType() ir.Expr {
 	fun := &ir.FuncTypeExpr{}
 	fun.ABI = p.parseExtern()
 	fun.SetPos(p.pos)
-	p.expect(token.Func)
-	if p.token.Is(token.Lbrack) {
+	if p.token.Is(token.Closure) {
+		fun.Closure = true
+		p.next()
+	} else {
+		p.expect(token.Func)
+	}
+	if !fun.Closure && p.token.Is(token.Lbrack) {
 		p.next()
 		fun.ABI = p.parseIdent()
 		p.expect(token.Rbrack)
 	}
-	fun.Params, fun.Return = p.parseFuncSignature()
+	fun.Params, fun.Variadic, fun.Return = p.parseFuncSignature()
 	fun.SetPos(fun.Return.EndPos())
 	return fun
 }
@@ -868,13 +1346,19 @@ func (p *parser) parseBinaryExpr(prec int) ir.Expr {
 	var expr ir.Expr
 	pos := p.pos
 
-	if p.token.OneOf(token.Sub, token.Lnot) {
+	if p.token.OneOf(token.Sub, token.Lnot, token.BitNot) {
 		op := p.token
 		p.next()
 		expr = p.parseOperand()
 		endPos := expr.EndPos()
 		expr = &ir.UnaryExpr{Op: op, X: expr}
 		expr.SetRange(pos, endPos)
+	} else if p.token.Is(token.Try) {
+		p.next()
+		expr = p.parseOperand()
+		endPos := expr.EndPos()
+		expr = &ir.TryExpr{X: expr}
+		expr.SetRange(pos, endPos)
 	} else if p.token.Is(token.Reference) {
 		p.next()
 		immutable := true
@@ -886,14 +1370,24 @@ func (p *parser) parseBinaryExpr(prec int) ir.Expr {
 		endPos := expr.EndPos()
 		expr = &ir.AddrExpr{X: expr, Immutable: immutable}
 		expr.SetRange(pos, endPos)
+	} else if p.token.OneOf(token.Mul, token.Nullable) {
+		// Raw or nullable pointer type used as an expression, for example a type argument
+		expr = p.parsePointerType()
 	} else {
 		expr = p.parseOperand()
 	}
 
 	expr = p.parseAsExpr(expr)
 
-	for p.token.IsBinaryOp() {
+	for {
 		op := p.token
+		if op.Is(token.Reference) {
+			// In binary position & is the bitwise and operator
+			op = token.BitAnd
+		}
+		if !op.IsBinaryOp() {
+			break
+		}
 		opPrec := ir.BinaryPrec(op)
 		if prec < opPrec {
 			break
@@ -926,12 +1420,28 @@ func (p *parser) parseOperand() ir.Expr {
 		pos := p.pos
 		p.next()
 		expr = p.parseExpr()
+		if p.token.Is(token.Comma) {
+			tuple := &ir.TupleLit{Elems: []ir.Expr{expr}}
+			for p.token.Is(token.Comma) {
+				p.next()
+				tuple.Elems = append(tuple.Elems, p.parseExpr())
+			}
+			expr = tuple
+		}
 		expr.SetRange(pos, p.endPos())
 		p.expect(token.Rparen)
 	} else if p.token.Is(token.Lenof) {
 		expr = p.parseLenExpr()
 	} else if p.token.Is(token.Sizeof) {
 		expr = p.parseSizeofExpr()
+	} else if p.token.OneOf(token.WrappingAdd, token.CheckedAdd, token.SaturatingAdd) {
+		expr = p.parseOverflowExpr()
+	} else if p.token.Is(token.New) {
+		expr = p.parseNewExpr()
+	} else if p.token.Is(token.Make) {
+		expr = p.parseMakeExpr()
+	} else if p.token.Is(token.Delete) {
+		expr = p.parseDeleteExpr()
 	} else if p.token.Is(token.Ident) {
 		ident := p.parseIdent()
 		if p.token.Is(token.String) {
@@ -941,16 +1451,60 @@ func (p *parser) parseOperand() ir.Expr {
 		}
 	} else if p.token.Is(token.ScopeSep) {
 		expr = p.parseIdentExpr(nil)
+	} else if p.token.Is(token.Placeholder) {
+		if !p.pattern {
+			p.error(p.pos, "'%s' can only be used in a match pattern or a destructuring declaration", p.token)
+		}
+		expr = ir.NewIdent2(p.token, p.literal)
+		expr.SetRange(p.pos, p.endPos())
+		p.next()
 	} else if p.token.Is(token.Lbrack) {
 		expr = p.parseArrayLit()
 	} else if p.token.OneOf(token.Func, token.Extern) {
 		expr = p.parseFuncLit()
+	} else if p.token.Is(token.If) {
+		return p.parseIfExpr()
+	} else if p.token.Is(token.Lbrace) {
+		return p.parseBlockExpr()
 	} else {
 		expr = p.parseBasicLit(nil)
 	}
 	return p.parsePrimary(expr)
 }
 
+func (p *parser) parseIfExpr() *ir.IfExpr {
+	expr := &ir.IfExpr{}
+	expr.Tok = p.token
+	expr.SetPos(p.pos)
+	p.next()
+	expr.Cond = p.parseExpr()
+	expr.Then = p.parseBlockExpr()
+	if p.token.Is(token.Elif) {
+		expr.Else = p.parseIfExpr()
+	} else {
+		p.expect(token.Else, token.Elif)
+		expr.Else = p.parseBlockExpr()
+	}
+	expr.SetEndPos(expr.Else.EndPos())
+	return expr
+}
+
+// The value of a block expression is the last statement in the block, which must be an expression.
+func (p *parser) parseBlockExpr() *ir.BlockExpr {
+	block := p.parseBlock()
+	expr := &ir.BlockExpr{Block: block}
+	expr.SetRange(block.Pos(), block.EndPos())
+	if n := len(block.Stmts); n > 0 {
+		if last, ok := block.Stmts[n-1].(*ir.ExprStmt); ok {
+			expr.X = last.X
+			block.Stmts = block.Stmts[:n-1]
+			return expr
+		}
+	}
+	p.error(block.EndPos(), "block expression must end with an expression")
+	panic(parseError(0))
+}
+
 func (p *parser) parseLenExpr() *ir.LenExpr {
 	lenof := &ir.LenExpr{}
 	lenof.SetPos(p.pos)
@@ -973,6 +1527,58 @@ func (p *parser) parseSizeofExpr() *ir.SizeofExpr {
 	return sizeof
 }
 
+func (p *parser) parseOverflowExpr() *ir.OverflowExpr {
+	expr := &ir.OverflowExpr{Op: p.token}
+	expr.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	expr.Left = p.parseExpr()
+	p.expect(token.Comma)
+	expr.Right = p.parseExpr()
+	expr.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return expr
+}
+
+func (p *parser) parseNewExpr() *ir.NewExpr {
+	expr := &ir.NewExpr{}
+	expr.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	expr.X = p.parseType()
+	if p.token.Is(token.Comma) {
+		p.next()
+		expr.Init = p.parseExpr()
+	}
+	expr.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return expr
+}
+
+func (p *parser) parseMakeExpr() *ir.MakeExpr {
+	expr := &ir.MakeExpr{}
+	expr.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	expr.X = p.parseType()
+	p.expect(token.Comma)
+	expr.Size = p.parseExpr()
+	expr.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return expr
+}
+
+func (p *parser) parseDeleteExpr() *ir.DeleteExpr {
+	expr := &ir.DeleteExpr{}
+	expr.SetPos(p.pos)
+	p.next()
+	p.expect(token.Lparen)
+	expr.X = p.parseExpr()
+	expr.SetEndPos(p.endPos())
+	p.expect(token.Rparen)
+	return expr
+}
+
 func (p *parser) parseArgExpr(stop token.Token) *ir.ArgExpr {
 	arg := &ir.ArgExpr{}
 	arg.SetPos(p.pos)
@@ -1039,6 +1645,19 @@ func (p *parser) parseBracketsExpr(expr ir.Expr) ir.Expr {
 		index1 = p.parseExpr()
 	}
 
+	if p.token.Is(token.Comma) {
+		// Multiple type arguments
+		instance := &ir.InstanceExpr{X: expr}
+		instance.Args = append(instance.Args, index1)
+		for p.token.Is(token.Comma) {
+			p.next()
+			instance.Args = append(instance.Args, p.parseType())
+		}
+		instance.SetRange(expr.Pos(), p.endPos())
+		p.expect(token.Rbrack)
+		return p.parsePrimary(instance)
+	}
+
 	if p.token.Is(token.Colon) {
 		colon = p.token
 		p.next()
@@ -1138,10 +1757,12 @@ func (p *parser) parseArrayLit() ir.Expr {
 }
 
 func (p *parser) parseFuncLit() ir.Expr {
+	lit := &ir.FuncLit{}
 	decl := &ir.FuncDecl{}
 	decl.Flags = ir.AstFlagAnon
 
-	abi := p.parseExtern()
+	lit.SetPos(p.pos)
+	lit.ABI = p.parseExtern()
 	name := fmt.Sprintf("$anon%d_lineno_%d", anonID, p.pos.Line)
 	decl.Name = ir.NewIdent2(token.Ident, name)
 	anonID++
@@ -1150,11 +1771,11 @@ func (p *parser) parseFuncLit() ir.Expr {
 	decl.Name.SetRange(p.pos, p.pos)
 	p.expect(token.Func)
 
-	decl.Params, decl.Return = p.parseFuncSignature()
+	decl.Params, decl.Variadic, decl.Return = p.parseFuncSignature()
 	decl.SetEndPos(decl.Return.EndPos())
 	decl.Body = p.parseBlockStmt()
 
-	p.anonDecls = append(p.anonDecls, ir.NewTopDecl(abi, token.Private, decl))
-
-	return decl.Name
+	lit.Decl = decl
+	lit.SetEndPos(decl.Body.EndPos())
+	return lit
 }
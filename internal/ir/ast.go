@@ -120,6 +120,7 @@ type ValDecl struct {
 	Type        Expr
 	Initializer Expr
 	Flags       int
+	Attributes  []*Attribute // Attributes of a field or parameter
 }
 
 func (d *ValDecl) DefaultInit() bool {
@@ -129,26 +130,117 @@ func (d *ValDecl) DefaultInit() bool {
 // FuncDecl represents a function (with body) or a function signature.
 type FuncDecl struct {
 	baseDecl
-	Name   *Ident
-	Params []*ValDecl
-	Return *ValDecl
-	Body   *BlockStmt
-	Scope  *Scope
-	Flags  int
+	Name       *Ident
+	TypeParams []*Ident
+	Params     []*ValDecl
+	Return     *ValDecl
+	Body       *BlockStmt
+	Scope      *Scope
+	Flags      int
+	Variadic   bool         // Accepts a variable number of arguments after Params
+	Captures   []*Capture   // Variables captured by a function literal
+	Attributes []*Attribute // Attributes of a method
 }
 
 func (d *FuncDecl) SignatureOnly() bool { return d.Body == nil }
 
-// StructDecl represents a struct declaration.
+// Capture is a variable from an enclosing function which is copied into
+// the environment of a closure. Inner is the symbol used in the closure body.
+type Capture struct {
+	Outer *Symbol
+	Inner *Symbol
+}
+
+// Attribute names.
+const (
+	AttrPacked       = "packed"
+	AttrAlign        = "align"
+	AttrInline       = "inline"
+	AttrNoInline     = "noinline"
+	AttrNoReturn     = "noreturn"
+	AttrCold         = "cold"
+	AttrDeprecated   = "deprecated"
+	AttrLinkName     = "link_name"
+	AttrPanicHandler = "panic_handler"
+	AttrAllocHandler = "alloc_handler"
+	AttrFreeHandler  = "free_handler"
+)
+
+// RuntimeHandlers are the attributes of functions which replace a function in the runtime.
+var RuntimeHandlers = []string{AttrPanicHandler, AttrAllocHandler, AttrFreeHandler}
+
+// Attribute represents an attribute such as '#[align(16)]' which is attached to a declaration.
+type Attribute struct {
+	baseNode
+	Name *Ident
+	Args []Expr
+}
+
+// StringArg returns the value of a string argument which has been checked.
+func (a *Attribute) StringArg(index int) string {
+	return a.Args[index].(*BasicLit).AsString()
+}
+
+// StructDecl represents a struct or cunion declaration.
 type StructDecl struct {
+	baseDecl
+	Name       *Ident
+	TypeParams []*Ident
+	Opaque     bool
+	CUnion     bool
+	Fields     []*ValDecl
+	Methods    []*FuncDecl
+	Scope      *Scope
+}
+
+// UnionDecl represents a tagged union declaration.
+type UnionDecl struct {
+	baseDecl
+	Name     *Ident
+	Variants []*UnionVariant
+	Scope    *Scope
+}
+
+// UnionVariant represents a variant and its payload fields in a tagged union.
+type UnionVariant struct {
+	baseNode
+	Name   *Ident
+	Fields []*ValDecl
+	Sym    *Symbol
+}
+
+// EnumDecl represents an enum declaration.
+type EnumDecl struct {
 	baseDecl
 	Name    *Ident
-	Opaque  bool
-	Fields  []*ValDecl
-	Methods []*FuncDecl
+	Backing Expr // Optional
+	Members []*EnumMember
 	Scope   *Scope
 }
 
+// EnumMember represents a named constant in an enum.
+type EnumMember struct {
+	baseNode
+	Name  *Ident
+	Value Expr // Optional
+	Sym   *Symbol
+}
+
+// InterfaceDecl represents an interface declaration.
+// The methods only have signatures and the first parameter of each method is '&Self' or '&var Self'.
+type InterfaceDecl struct {
+	baseDecl
+	Name    *Ident
+	Methods []*FuncDecl
+}
+
+// StaticAssertDecl is a condition that must be true at compile-time.
+type StaticAssertDecl struct {
+	baseDecl
+	Cond Expr
+	Msg  *BasicLit // Optional
+}
+
 // Statement nodes.
 
 type baseStmt struct {
@@ -168,6 +260,15 @@ type DeclStmt struct {
 	D Decl
 }
 
+// DestructureStmt declares one variable for each element of a tuple.
+// Tuple is an unnamed declaration holding the tuple value, and the initializer
+// of each element declaration is a TupleElemExpr which refers to it.
+type DestructureStmt struct {
+	baseStmt
+	Tuple *ValDecl
+	Elems []*ValDecl
+}
+
 // IfStmt represents a chain of if/elif/else statements.
 type IfStmt struct {
 	baseStmt
@@ -177,13 +278,50 @@ type IfStmt struct {
 	Else Stmt // Optional
 }
 
+// MatchStmt represents a match statement over an integer, bool, enum or union value.
+type MatchStmt struct {
+	baseStmt
+	X    Expr
+	Arms []*MatchArm
+	Else *BlockStmt // Optional
+}
+
+// MatchArm represents one arm of a match statement.
+// If the arm matches a union variant with a payload, Bindings
+// holds the payload fields that are bound in the arm body.
+type MatchArm struct {
+	baseNode
+	Patterns []Expr
+	Bindings []*MatchBinding
+	Body     *BlockStmt
+}
+
+// MatchBinding binds a payload field of a union variant to a name.
+type MatchBinding struct {
+	Name  *Ident
+	Field int
+}
+
 type ForStmt struct {
 	baseStmt
-	Tok  token.Token
-	Init Stmt
-	Inc  Stmt
-	Cond Expr
-	Body *BlockStmt
+	Tok   token.Token
+	Label *Ident // Nil if the loop has no label
+	Init  Stmt
+	Inc   Stmt
+	Cond  Expr
+	Body  *BlockStmt
+	Range *RangeClause // Nil if the loop is not a range-based loop
+}
+
+// RangeClause iterates over the elements of an array or slice, or over the integers from X up to but not including End.
+// The element is stored in a new variable for each iteration, or a reference to it if Ref is true.
+type RangeClause struct {
+	Index     *ValDecl // Nil if there is no index variable
+	Elem      *ValDecl
+	Ref       bool
+	Immutable bool
+	X         Expr
+	End       Expr // Nil if the range is not an integer range
 }
 
 type ReturnStmt struct {
@@ -193,12 +331,14 @@ type ReturnStmt struct {
 
 type DeferStmt struct {
 	baseStmt
-	S Stmt
+	Tok token.Token // Defer or Errdefer
+	S   Stmt
 }
 
 type BranchStmt struct {
 	baseStmt
-	Tok token.Token
+	Tok   token.Token
+	Label *Ident // Nil if the branch targets the innermost loop
 }
 
 type AssignStmt struct {
@@ -213,6 +353,25 @@ type ExprStmt struct {
 	X Expr
 }
 
+// StaticAssertStmt is a static assertion in a function body.
+type StaticAssertStmt struct {
+	baseStmt
+	D *StaticAssertDecl
+}
+
+// PanicStmt prints the message and the position of the statement, and aborts the program.
+type PanicStmt struct {
+	baseStmt
+	X Expr
+}
+
+// AssertStmt panics if the condition is false.
+type AssertStmt struct {
+	baseStmt
+	Cond Expr
+	Msg  Expr // Optional
+}
+
 // Expression nodes.
 
 type baseExpr struct {
@@ -248,8 +407,10 @@ type Typeof struct {
 
 type PointerTypeExpr struct {
 	baseExpr
-	Decl token.Token
-	X    Expr
+	Decl     token.Token
+	X        Expr
+	Raw      bool
+	Nullable bool
 }
 
 type SliceTypeExpr struct {
@@ -263,11 +424,25 @@ type ArrayTypeExpr struct {
 	Size Expr
 }
 
+type TupleTypeExpr struct {
+	baseExpr
+	Elems []Expr
+}
+
+// ResultTypeExpr is a value type and an error type written as 'T!E'.
+type ResultTypeExpr struct {
+	baseExpr
+	Value Expr
+	Error Expr
+}
+
 type FuncTypeExpr struct {
 	baseExpr
-	ABI    *Ident
-	Params []*ValDecl
-	Return *ValDecl
+	ABI      *Ident
+	Params   []*ValDecl
+	Return   *ValDecl
+	Variadic bool
+	Closure  bool
 }
 
 type Ident struct {
@@ -422,6 +597,25 @@ type ArrayLit struct {
 	Initializers []Expr
 }
 
+type TupleLit struct {
+	baseExpr
+	Elems []Expr
+}
+
+// TupleElemExpr is created by the parser when a tuple is destructured.
+type TupleElemExpr struct {
+	baseExpr
+	X     Expr
+	Index int
+}
+
+// UnionLit is created by the checker when a union variant is constructed.
+type UnionLit struct {
+	baseExpr
+	Tag  int
+	Args []*ArgExpr
+}
+
 type BinaryExpr struct {
 	baseExpr
 	Left  Expr
@@ -435,6 +629,29 @@ type UnaryExpr struct {
 	X  Expr
 }
 
+// TryExpr evaluates to the value of a result, or returns early with the error.
+type TryExpr struct {
+	baseExpr
+	X Expr
+}
+
+// IfExpr evaluates to the value of the branch selected by the condition. Else is a BlockExpr,
+// or an IfExpr for an elif branch.
+type IfExpr struct {
+	baseExpr
+	Tok  token.Token
+	Cond Expr
+	Then *BlockExpr
+	Else Expr
+}
+
+// BlockExpr executes the statements in Block and evaluates to X.
+type BlockExpr struct {
+	baseExpr
+	Block *BlockStmt
+	X     Expr
+}
+
 type AddrExpr struct {
 	baseExpr
 	X         Expr
@@ -446,7 +663,13 @@ type DerefExpr struct {
 	X Expr
 }
 
+// A dereferenced pointer is an lvalue even if the pointer isn't, e.g. a reference returned by a function.
 func (x *DerefExpr) Lvalue() bool {
+	if t := x.X.Type(); t != nil {
+		if _, ok := ToBaseType(t).(*PointerType); ok {
+			return true
+		}
+	}
 	return x.X.Lvalue()
 }
 
@@ -480,14 +703,20 @@ type IndexExpr struct {
 }
 
 func (x *IndexExpr) Lvalue() bool {
+	if t := x.X.Type(); t != nil && IsRawPointerType(t) {
+		return true
+	}
 	return x.X.Lvalue()
 }
 
 func (x *IndexExpr) ReadOnly() bool {
 	t := x.X.Type()
 	if t != nil {
-		if tslice, ok := t.(*SliceType); ok {
-			return tslice.ReadOnly
+		switch t := ToBaseType(t).(type) {
+		case *SliceType:
+			return t.ReadOnly
+		case *PointerType:
+			return t.ReadOnly
 		}
 	}
 	return x.X.ReadOnly()
@@ -511,6 +740,22 @@ func (x *SliceExpr) ReadOnly() bool {
 	return false
 }
 
+// FuncLit represents a function literal. A literal that captures
+// variables from an enclosing function is a closure.
+type FuncLit struct {
+	baseExpr
+	ABI   *Ident
+	Decl  *FuncDecl
+	Stack bool // The closure environment can be allocated on the stack
+}
+
+// InstanceExpr represents a generic function or struct with explicit type arguments.
+type InstanceExpr struct {
+	baseExpr
+	X    Expr
+	Args []Expr
+}
+
 type ArgExpr struct {
 	baseNode
 	Name  *Ident
@@ -540,6 +785,34 @@ type SizeofExpr struct {
 	X Expr
 }
 
+// OverflowExpr is an integer addition with explicit overflow behavior (wrapping_add, checked_add or saturating_add).
+type OverflowExpr struct {
+	baseExpr
+	Op    token.Token
+	Left  Expr
+	Right Expr
+}
+
+// NewExpr allocates a value on the heap and returns a reference to it.
+type NewExpr struct {
+	baseExpr
+	X    Expr // Type
+	Init Expr // Optional
+}
+
+// MakeExpr allocates an array with Size elements on the heap and returns a slice of it.
+type MakeExpr struct {
+	baseExpr
+	X    Expr // Slice type
+	Size Expr
+}
+
+// DeleteExpr frees memory allocated by new or make.
+type DeleteExpr struct {
+	baseExpr
+	X Expr
+}
+
 type ConstExpr struct {
 	baseExpr
 	X Expr
@@ -579,14 +852,22 @@ func BinaryPrec(op token.Token) int {
 		return 5
 	case token.Add, token.Sub:
 		return 6
-	case token.Lt, token.LtEq, token.Gt, token.GtEq:
+	case token.Shl, token.Shr:
+		return 7
+	case token.BitAnd:
+		return 8
+	case token.BitXor:
 		return 9
-	case token.Eq, token.Neq:
+	case token.BitOr:
 		return 10
+	case token.Lt, token.LtEq, token.Gt, token.GtEq:
+		return 11
+	case token.Eq, token.Neq:
+		return 12
 	case token.Land:
-		return 14
+		return 13
 	case token.Lor:
-		return 15
+		return 14
 	default:
 		panic(fmt.Sprintf("Unhandled binary op %s", op))
 	}
@@ -595,7 +876,7 @@ func BinaryPrec(op token.Token) int {
 // UnaryPrec returns the precedence for a unary operation.
 func UnaryPrec(op token.Token) int {
 	switch op {
-	case token.Lnot, token.Sub, token.Reference:
+	case token.Lnot, token.Sub, token.BitNot, token.Reference, token.Try:
 		return 3
 	default:
 		panic(fmt.Sprintf("Unhandled unary op %s", op))
@@ -611,6 +892,8 @@ func ExprPrec(expr Expr) int {
 		return UnaryPrec(t.Op)
 	case *AddrExpr:
 		return UnaryPrec(token.Reference)
+	case *TryExpr:
+		return UnaryPrec(token.Try)
 	case *DerefExpr, *IndexExpr, *SliceExpr, *DotExpr, *CastExpr, *AppExpr:
 		return 1
 	case *BasicLit, *Ident:
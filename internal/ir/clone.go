@@ -0,0 +1,430 @@
+package ir
+
+import "fmt"
+
+// The clone functions create deep copies of unchecked syntax trees.
+// They are used to instantiate generic declarations.
+
+// CloneDecl creates a deep copy of decl.
+func CloneDecl(decl Decl) Decl {
+	switch decl := decl.(type) {
+	case *ImportDecl:
+		d := *decl
+		d.Alias = cloneIdent(decl.Alias)
+		d.Name = cloneScopeLookup(decl.Name)
+		return &d
+	case *UseDecl:
+		d := *decl
+		d.Alias = cloneIdent(decl.Alias)
+		d.Name = cloneScopeLookup(decl.Name)
+		return &d
+	case *TypeDecl:
+		d := *decl
+		d.Name = cloneIdent(decl.Name)
+		d.Type = CloneExpr(decl.Type)
+		return &d
+	case *ValDecl:
+		return cloneValDecl(decl)
+	case *FuncDecl:
+		return cloneFuncDecl(decl)
+	case *StructDecl:
+		d := *decl
+		d.Name = cloneIdent(decl.Name)
+		d.TypeParams = cloneIdentList(decl.TypeParams)
+		d.Fields = cloneValDeclList(decl.Fields)
+		d.Methods = nil
+		for _, method := range decl.Methods {
+			d.Methods = append(d.Methods, cloneFuncDecl(method))
+		}
+		return &d
+	case *UnionDecl:
+		d := *decl
+		d.Name = cloneIdent(decl.Name)
+		d.Variants = nil
+		for _, variant := range decl.Variants {
+			v := *variant
+			v.Name = cloneIdent(variant.Name)
+			v.Fields = cloneValDeclList(variant.Fields)
+			d.Variants = append(d.Variants, &v)
+		}
+		return &d
+	case *EnumDecl:
+		d := *decl
+		d.Name = cloneIdent(decl.Name)
+		d.Backing = CloneExpr(decl.Backing)
+		d.Members = nil
+		for _, member := range decl.Members {
+			m := *member
+			m.Name = cloneIdent(member.Name)
+			m.Value = CloneExpr(member.Value)
+			d.Members = append(d.Members, &m)
+		}
+		return &d
+	case *InterfaceDecl:
+		d := *decl
+		d.Name = cloneIdent(decl.Name)
+		d.Methods = nil
+		for _, method := range decl.Methods {
+			d.Methods = append(d.Methods, cloneFuncDecl(method))
+		}
+		return &d
+	case *StaticAssertDecl:
+		d := *decl
+		d.Cond = CloneExpr(decl.Cond)
+		if decl.Msg != nil {
+			d.Msg = CloneExpr(decl.Msg).(*BasicLit)
+		}
+		return &d
+	default:
+		panic(fmt.Sprintf("Unhandled decl %T", decl))
+	}
+}
+
+func cloneValDecl(decl *ValDecl) *ValDecl {
+	if decl == nil {
+		return nil
+	}
+	d := *decl
+	d.Name = cloneIdent(decl.Name)
+	d.Type = CloneExpr(decl.Type)
+	d.Initializer = CloneExpr(decl.Initializer)
+	return &d
+}
+
+func cloneValDeclList(decls []*ValDecl) []*ValDecl {
+	var res []*ValDecl
+	for _, decl := range decls {
+		res = append(res, cloneValDecl(decl))
+	}
+	return res
+}
+
+func cloneFuncDecl(decl *FuncDecl) *FuncDecl {
+	d := *decl
+	d.Name = cloneIdent(decl.Name)
+	d.TypeParams = cloneIdentList(decl.TypeParams)
+	d.Params = cloneValDeclList(decl.Params)
+	d.Return = cloneValDecl(decl.Return)
+	d.Body = cloneBlockStmt(decl.Body)
+	return &d
+}
+
+// CloneStmt creates a deep copy of stmt.
+func CloneStmt(stmt Stmt) Stmt {
+	switch stmt := stmt.(type) {
+	case nil:
+		return nil
+	case *BlockStmt:
+		return cloneBlockStmt(stmt)
+	case *DeclStmt:
+		s := *stmt
+		s.D = CloneDecl(stmt.D)
+		return &s
+	case *DestructureStmt:
+		s := *stmt
+		s.Tuple = cloneValDecl(stmt.Tuple)
+		s.Elems = cloneValDeclList(stmt.Elems)
+		return &s
+	case *IfStmt:
+		s := *stmt
+		s.Cond = CloneExpr(stmt.Cond)
+		s.Body = cloneBlockStmt(stmt.Body)
+		s.Else = CloneStmt(stmt.Else)
+		return &s
+	case *MatchStmt:
+		s := *stmt
+		s.X = CloneExpr(stmt.X)
+		s.Arms = nil
+		for _, arm := range stmt.Arms {
+			a := *arm
+			a.Patterns = cloneExprList(arm.Patterns)
+			a.Bindings = nil
+			a.Body = cloneBlockStmt(arm.Body)
+			s.Arms = append(s.Arms, &a)
+		}
+		s.Else = cloneBlockStmt(stmt.Else)
+		return &s
+	case *ForStmt:
+		s := *stmt
+		s.Label = cloneIdent(stmt.Label)
+		s.Init = CloneStmt(stmt.Init)
+		s.Inc = CloneStmt(stmt.Inc)
+		s.Cond = CloneExpr(stmt.Cond)
+		s.Body = cloneBlockStmt(stmt.Body)
+		if stmt.Range != nil {
+			r := *stmt.Range
+			r.Index = cloneValDecl(stmt.Range.Index)
+			r.Elem = cloneValDecl(stmt.Range.Elem)
+			r.X = CloneExpr(stmt.Range.X)
+			r.End = CloneExpr(stmt.Range.End)
+			s.Range = &r
+		}
+		return &s
+	case *ReturnStmt:
+		s := *stmt
+		s.X = CloneExpr(stmt.X)
+		return &s
+	case *DeferStmt:
+		s := *stmt
+		s.S = CloneStmt(stmt.S)
+		return &s
+	case *BranchStmt:
+		s := *stmt
+		s.Label = cloneIdent(stmt.Label)
+		return &s
+	case *AssignStmt:
+		s := *stmt
+		s.Left = CloneExpr(stmt.Left)
+		s.Right = CloneExpr(stmt.Right)
+		return &s
+	case *ExprStmt:
+		s := *stmt
+		s.X = CloneExpr(stmt.X)
+		return &s
+	case *StaticAssertStmt:
+		s := *stmt
+		s.D = CloneDecl(stmt.D).(*StaticAssertDecl)
+		return &s
+	case *PanicStmt:
+		s := *stmt
+		s.X = CloneExpr(stmt.X)
+		return &s
+	case *AssertStmt:
+		s := *stmt
+		s.Cond = CloneExpr(stmt.Cond)
+		s.Msg = CloneExpr(stmt.Msg)
+		return &s
+	default:
+		panic(fmt.Sprintf("Unhandled stmt %T", stmt))
+	}
+}
+
+func cloneBlockStmt(stmt *BlockStmt) *BlockStmt {
+	if stmt == nil {
+		return nil
+	}
+	s := *stmt
+	s.Stmts = nil
+	for _, child := range stmt.Stmts {
+		s.Stmts = append(s.Stmts, CloneStmt(child))
+	}
+	return &s
+}
+
+// CloneExpr creates a deep copy of expr.
+func CloneExpr(expr Expr) Expr {
+	switch expr := expr.(type) {
+	case nil:
+		return nil
+	case *Typeof:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *PointerTypeExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *SliceTypeExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *ArrayTypeExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Size = CloneExpr(expr.Size)
+		return &x
+	case *TupleTypeExpr:
+		x := *expr
+		x.Elems = cloneExprList(expr.Elems)
+		return &x
+	case *ResultTypeExpr:
+		x := *expr
+		x.Value = CloneExpr(expr.Value)
+		x.Error = CloneExpr(expr.Error)
+		return &x
+	case *FuncTypeExpr:
+		x := *expr
+		x.ABI = cloneIdent(expr.ABI)
+		x.Params = cloneValDeclList(expr.Params)
+		x.Return = cloneValDecl(expr.Return)
+		return &x
+	case *Ident:
+		return cloneIdent(expr)
+	case *ScopeLookup:
+		return cloneScopeLookup(expr)
+	case *BasicLit:
+		x := *expr
+		x.Prefix = cloneIdent(expr.Prefix)
+		x.Suffix = cloneIdent(expr.Suffix)
+		return &x
+	case *ArrayLit:
+		x := *expr
+		x.Elem = CloneExpr(expr.Elem)
+		x.Size = CloneExpr(expr.Size)
+		x.Initializers = cloneExprList(expr.Initializers)
+		return &x
+	case *TupleLit:
+		x := *expr
+		x.Elems = cloneExprList(expr.Elems)
+		return &x
+	case *TupleElemExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *UnionLit:
+		x := *expr
+		x.Args = cloneArgList(expr.Args)
+		return &x
+	case *BinaryExpr:
+		x := *expr
+		x.Left = CloneExpr(expr.Left)
+		x.Right = CloneExpr(expr.Right)
+		return &x
+	case *UnaryExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *TryExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *IfExpr:
+		x := *expr
+		x.Cond = CloneExpr(expr.Cond)
+		x.Then = cloneBlockExpr(expr.Then)
+		x.Else = CloneExpr(expr.Else)
+		return &x
+	case *BlockExpr:
+		return cloneBlockExpr(expr)
+	case *AddrExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *DerefExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *DotExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Name = cloneIdent(expr.Name)
+		return &x
+	case *IndexExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Index = CloneExpr(expr.Index)
+		return &x
+	case *SliceExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Start = CloneExpr(expr.Start)
+		x.End = CloneExpr(expr.End)
+		return &x
+	case *FuncLit:
+		x := *expr
+		x.Decl = cloneFuncDecl(expr.Decl)
+		return &x
+	case *InstanceExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Args = cloneExprList(expr.Args)
+		return &x
+	case *AppExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Args = cloneArgList(expr.Args)
+		return &x
+	case *CastExpr:
+		x := *expr
+		x.ToType = CloneExpr(expr.ToType)
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *LenExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *SizeofExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *OverflowExpr:
+		x := *expr
+		x.Left = CloneExpr(expr.Left)
+		x.Right = CloneExpr(expr.Right)
+		return &x
+	case *NewExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Init = CloneExpr(expr.Init)
+		return &x
+	case *MakeExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		x.Size = CloneExpr(expr.Size)
+		return &x
+	case *DeleteExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *ConstExpr:
+		x := *expr
+		x.X = CloneExpr(expr.X)
+		return &x
+	case *DefaultInit:
+		x := *expr
+		return &x
+	default:
+		panic(fmt.Sprintf("Unhandled expr %T", expr))
+	}
+}
+
+func cloneBlockExpr(expr *BlockExpr) *BlockExpr {
+	x := *expr
+	x.Block = cloneBlockStmt(expr.Block)
+	x.X = CloneExpr(expr.X)
+	return &x
+}
+
+func cloneExprList(exprs []Expr) []Expr {
+	var res []Expr
+	for _, expr := range exprs {
+		res = append(res, CloneExpr(expr))
+	}
+	return res
+}
+
+func cloneArgList(args []*ArgExpr) []*ArgExpr {
+	var res []*ArgExpr
+	for _, arg := range args {
+		a := *arg
+		a.Name = cloneIdent(arg.Name)
+		a.Value = CloneExpr(arg.Value)
+		res = append(res, &a)
+	}
+	return res
+}
+
+func cloneIdent(expr *Ident) *Ident {
+	if expr == nil {
+		return nil
+	}
+	x := *expr
+	return &x
+}
+
+func cloneIdentList(idents []*Ident) []*Ident {
+	var res []*Ident
+	for _, ident := range idents {
+		res = append(res, cloneIdent(ident))
+	}
+	return res
+}
+
+func cloneScopeLookup(expr *ScopeLookup) *ScopeLookup {
+	if expr == nil {
+		return nil
+	}
+	x := *expr
+	x.Parts = cloneIdentList(expr.Parts)
+	return &x
+}
@@ -57,6 +57,12 @@ type Symbol struct {
 	Pos    token.Position
 	T      Type
 	Flags  int
+	// Generic is the generic declaration that an instance was created from,
+	// and TypeArgs the type arguments it was instantiated with.
+	Generic  *Symbol
+	TypeArgs []Type
+	// Attributes which have been validated by the checker.
+	Attributes []*Attribute
 }
 
 // NewSymbol creates a new symbol.
@@ -97,7 +103,7 @@ func (s SymbolKind) String() string {
 	case UnknownSymbol:
 		return "unknown"
 	default:
-		return "symbol " + string(s)
+		return fmt.Sprintf("symbol %d", s)
 	}
 }
 
@@ -125,6 +131,26 @@ func (s *Symbol) IsBuiltin() bool {
 	return (s.Flags & SymFlagBuiltin) != 0
 }
 
+// Attribute returns the attribute with the name, or nil if the symbol doesn't have it.
+func (s *Symbol) Attribute(name string) *Attribute {
+	for _, attr := range s.Attributes {
+		if attr.Name.Literal == name {
+			return attr
+		}
+	}
+	return nil
+}
+
+// RuntimeHandler returns the handler attribute of the symbol, or an empty string if it isn't a runtime handler.
+func (s *Symbol) RuntimeHandler() string {
+	for _, name := range RuntimeHandlers {
+		if s.Attribute(name) != nil {
+			return name
+		}
+	}
+	return ""
+}
+
 func (s *Symbol) IsMethod() bool {
 	return (s.Flags & SymFlagMethod) != 0
 }
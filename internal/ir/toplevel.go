@@ -35,6 +35,7 @@ type TopDecl struct {
 	D          Decl
 	ABI        *Ident
 	Visibility token.Token
+	Attributes []*Attribute
 }
 
 func (d *TopDecl) declNode() {}
@@ -43,10 +44,11 @@ func (d *TopDecl) Symbol() *Symbol {
 	return d.D.Symbol()
 }
 
-func NewTopDecl(abi *Ident, visibility token.Token, decl Decl) *TopDecl {
+func NewTopDecl(abi *Ident, visibility token.Token, attributes []*Attribute, decl Decl) *TopDecl {
 	return &TopDecl{
 		ABI:        abi,
 		Visibility: visibility,
+		Attributes: attributes,
 		D:          decl,
 	}
 }
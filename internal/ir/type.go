@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strings"
 
 	"github.com/cjo5/dingo/internal/token"
 )
@@ -38,10 +39,17 @@ const (
 
 	TModule
 	TStruct
+	TUnion
+	TEnum
 	TArray
 	TSlice
 	TPointer
+	TTuple
 	TFunc
+	TClosure
+	TInterface
+	TResult
+	TGeneric
 )
 
 var types = [...]string{
@@ -65,10 +73,17 @@ var types = [...]string{
 	TFloat64:    "f64",
 	TModule:     "module",
 	TStruct:     "struct",
+	TUnion:      "union",
+	TEnum:       "enum",
 	TArray:      "array",
 	TSlice:      "slice",
 	TPointer:    "pointer",
+	TTuple:      "tuple",
 	TFunc:       "fun",
+	TClosure:    "closure",
+	TInterface:  "interface",
+	TResult:     "result",
+	TGeneric:    "generic",
 }
 
 func (id TypeKind) String() string {
@@ -201,6 +216,10 @@ func (t *BasicType) CastableTo(other Type) bool {
 	case IsNumericType(t):
 		if IsNumericType(other) {
 			return true
+		} else if IsIntegerType(t) && other.Kind() == TEnum {
+			return true
+		} else if t.Kind() == TUSize && IsRawPointerType(other) {
+			return true
 		}
 	}
 	return false
@@ -229,8 +248,9 @@ func (t *ModuleType) Scope() *Scope {
 }
 
 type Field struct {
-	Name string
-	T    Type
+	Name    string
+	T       Type
+	Default Expr // Value used if no argument is given, or nil if the argument is required
 }
 
 type StructType struct {
@@ -238,6 +258,9 @@ type StructType struct {
 	TypedBody bool
 	Sym       *Symbol
 	Fields    []Field
+	CUnion    bool // The fields share storage
+	Packed    bool // No padding between fields
+	Align     int  // Minimum alignment in bytes, or 0 for natural alignment
 	scope     *Scope
 }
 
@@ -275,6 +298,90 @@ func (t *StructType) Scope() *Scope {
 	return t.scope
 }
 
+type Variant struct {
+	Name   string
+	Tag    int
+	Sym    *Symbol
+	Fields []Field
+}
+
+type UnionType struct {
+	baseType
+	TypedBody bool
+	Sym       *Symbol
+	Variants  []Variant
+	scope     *Scope
+}
+
+func (t *UnionType) String() string {
+	return t.Sym.FQN()
+}
+
+func (t *UnionType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*UnionType); ok {
+		return t.Sym.FQN() == t2.Sym.FQN()
+	}
+	return false
+}
+
+func (t *UnionType) CastableTo(other Type) bool {
+	other = ToBaseType(other)
+	return t.Equals(other)
+}
+
+// VariantOf returns the variant which is defined by sym, or nil if sym is not a variant of the union.
+func (t *UnionType) VariantOf(sym *Symbol) *Variant {
+	for i, variant := range t.Variants {
+		if variant.Sym != nil && variant.Sym.Key == sym.Key {
+			return &t.Variants[i]
+		}
+	}
+	return nil
+}
+
+func (t *UnionType) Scope() *Scope {
+	return t.scope
+}
+
+type EnumValue struct {
+	Name  string
+	Value *big.Int
+}
+
+type EnumType struct {
+	baseType
+	Sym     *Symbol
+	Backing Type // Nil until the enum body has been checked
+	Members []EnumValue
+	scope   *Scope
+}
+
+func (t *EnumType) String() string {
+	return t.Sym.FQN()
+}
+
+func (t *EnumType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*EnumType); ok {
+		return t.Sym.FQN() == t2.Sym.FQN()
+	}
+	return false
+}
+
+func (t *EnumType) CastableTo(other Type) bool {
+	other = ToBaseType(other)
+	return t.Equals(other) || IsIntegerType(other)
+}
+
+func (t *EnumType) Scope() *Scope {
+	return t.scope
+}
+
+func (t *EnumType) TypedBody() bool {
+	return t.Backing != nil
+}
+
 type ArrayType struct {
 	baseType
 	Elem Type
@@ -339,6 +446,8 @@ type PointerType struct {
 	baseType
 	Elem     Type
 	ReadOnly bool // Applies to the Elem type
+	Raw      bool // Raw pointers support pointer arithmetic and can be null
+	Nullable bool // References can only be null if they are nullable
 }
 
 func (t *PointerType) String() string {
@@ -346,13 +455,19 @@ func (t *PointerType) String() string {
 	if !t.ReadOnly {
 		extra = token.Var.String() + " "
 	}
-	return fmt.Sprintf("%s%s%s", token.Reference.String(), extra, t.Elem)
+	prefix := token.Reference.String()
+	if t.Raw {
+		prefix = token.Mul.String()
+	} else if t.Nullable {
+		prefix = token.Nullable.String() + prefix
+	}
+	return fmt.Sprintf("%s%s%s", prefix, extra, t.Elem)
 }
 
 func (t *PointerType) Equals(other Type) bool {
 	other = ToBaseType(other)
 	if t2, ok := other.(*PointerType); ok {
-		return t.ReadOnly == t2.ReadOnly && t.Elem.Equals(t2.Elem)
+		return t.ReadOnly == t2.ReadOnly && t.Raw == t2.Raw && t.Nullable == t2.Nullable && t.Elem.Equals(t2.Elem)
 	}
 	return false
 }
@@ -361,20 +476,25 @@ func (t *PointerType) CastableTo(other Type) bool {
 	other = ToBaseType(other)
 	if t2, ok := other.(*PointerType); ok {
 		switch {
+		case t.Raw && t2.Raw:
+			return true
 		case t.Elem.Kind() == TVoid || t2.Elem.Kind() == TVoid:
 			return true
 		case t.Elem.Equals(t2.Elem):
 			return true
 		}
+	} else if t.Raw && other.Kind() == TUSize {
+		return true
 	}
 	return false
 }
 
 type FuncType struct {
 	baseType
-	C      bool
-	Params []Field
-	Return Type
+	C        bool
+	Params   []Field
+	Variadic bool
+	Return   Type
 }
 
 func (t *FuncType) String() string {
@@ -390,6 +510,12 @@ func (t *FuncType) String() string {
 			buf.WriteString(", ")
 		}
 	}
+	if t.Variadic {
+		if len(t.Params) > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("...")
+	}
 	buf.WriteString(")")
 	if t.Return.Kind() != TVoid {
 		buf.WriteString(" ")
@@ -401,7 +527,7 @@ func (t *FuncType) String() string {
 func (t *FuncType) Equals(other Type) bool {
 	other = ToBaseType(other)
 	if t2, ok := other.(*FuncType); ok {
-		if t.C != t2.C {
+		if t.C != t2.C || t.Variadic != t2.Variadic {
 			return false
 		}
 		if len(t.Params) != len(t2.Params) {
@@ -425,6 +551,173 @@ func (t *FuncType) CastableTo(other Type) bool {
 	return t.Equals(other)
 }
 
+// TupleType is an anonymous sequence of values with possibly different types. Tuples are stored like a
+// struct and can be destructured into variables.
+type TupleType struct {
+	baseType
+	Elems []Type
+}
+
+func (t *TupleType) String() string {
+	var buf strings.Builder
+	buf.WriteString("(")
+	for i, elem := range t.Elems {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(elem.String())
+	}
+	buf.WriteString(")")
+	return buf.String()
+}
+
+func (t *TupleType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*TupleType); ok {
+		if len(t.Elems) != len(t2.Elems) {
+			return false
+		}
+		for i, elem := range t.Elems {
+			if !elem.Equals(t2.Elems[i]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (t *TupleType) CastableTo(other Type) bool {
+	return t.Equals(other)
+}
+
+// ClosureType is the type of a function together with an environment of captured variables.
+// Functions that are not closures can be used where a closure is expected.
+type ClosureType struct {
+	baseType
+	F *FuncType
+}
+
+func (t *ClosureType) String() string {
+	return "closure" + strings.TrimPrefix(t.F.String(), "fun")
+}
+
+func (t *ClosureType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*ClosureType); ok {
+		return t.F.Equals(t2.F)
+	}
+	return false
+}
+
+func (t *ClosureType) CastableTo(other Type) bool {
+	return t.Equals(other)
+}
+
+// InterfaceMethod is a method of an interface. T is the type of the method without the self parameter.
+type InterfaceMethod struct {
+	Name     string
+	ReadOnly bool // True if self is '&Self' and false if it's '&var Self'
+	T        *FuncType
+}
+
+// InterfaceType is the type of a reference to any struct which has the methods of the interface.
+type InterfaceType struct {
+	baseType
+	TypedBody bool
+	Sym       *Symbol
+	Methods   []InterfaceMethod
+}
+
+func (t *InterfaceType) String() string {
+	return t.Sym.FQN()
+}
+
+func (t *InterfaceType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*InterfaceType); ok {
+		return t.Sym.FQN() == t2.Sym.FQN()
+	}
+	return false
+}
+
+func (t *InterfaceType) CastableTo(other Type) bool {
+	return t.Equals(other)
+}
+
+// MethodIndex returns the index of the method in the interface, or -1 if there is no such method.
+func (t *InterfaceType) MethodIndex(name string) int {
+	for i, method := range t.Methods {
+		if method.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Fields of a result. The value is only valid if ok is true and the error only if ok is false.
+const (
+	ResultOk    = "ok"
+	ResultValue = "value"
+	ResultError = "error"
+)
+
+// ResultType is the type of a value or an error.
+type ResultType struct {
+	baseType
+	T Type
+	E Type
+}
+
+func (t *ResultType) String() string {
+	return fmt.Sprintf("%s!%s", t.T, t.E)
+}
+
+func (t *ResultType) Equals(other Type) bool {
+	other = ToBaseType(other)
+	if t2, ok := other.(*ResultType); ok {
+		return t.T.Equals(t2.T) && t.E.Equals(t2.E)
+	}
+	return false
+}
+
+func (t *ResultType) CastableTo(other Type) bool {
+	return t.Equals(other)
+}
+
+// GenericType is the type of a generic function or struct which
+// has not been instantiated with type arguments.
+type GenericType struct {
+	baseType
+	Sym        *Symbol
+	TypeParams []string
+}
+
+func (t *GenericType) String() string {
+	var buf bytes.Buffer
+	buf.WriteString(t.Sym.FQN())
+	buf.WriteString("[")
+	for i, param := range t.TypeParams {
+		buf.WriteString(param)
+		if (i + 1) < len(t.TypeParams) {
+			buf.WriteString(", ")
+		}
+	}
+	buf.WriteString("]")
+	return buf.String()
+}
+
+func (t *GenericType) Equals(other Type) bool {
+	if t2, ok := ToBaseType(other).(*GenericType); ok {
+		return t.Sym == t2.Sym
+	}
+	return false
+}
+
+func (t *GenericType) CastableTo(other Type) bool {
+	return false
+}
+
 func NewAliasType(name string, base Type) *AliasType {
 	t := &AliasType{}
 	t.Name = name
@@ -455,6 +748,28 @@ func (t *StructType) SetBody(fields []Field, typedBody bool) {
 	t.TypedBody = typedBody
 }
 
+func NewUnionType(sym *Symbol, scope *Scope) *UnionType {
+	t := &UnionType{Sym: sym, scope: scope}
+	t.kind = TUnion
+	return t
+}
+
+func (t *UnionType) SetBody(variants []Variant, typedBody bool) {
+	t.Variants = variants
+	t.TypedBody = typedBody
+}
+
+func NewEnumType(sym *Symbol, scope *Scope) *EnumType {
+	t := &EnumType{Sym: sym, scope: scope}
+	t.kind = TEnum
+	return t
+}
+
+func (t *EnumType) SetBody(backing Type, members []EnumValue) {
+	t.Backing = backing
+	t.Members = members
+}
+
 func NewArrayType(elem Type, size int) *ArrayType {
 	t := &ArrayType{Elem: elem, Size: size}
 	t.kind = TArray
@@ -473,12 +788,59 @@ func NewPointerType(elem Type, readOnly bool) *PointerType {
 	return t
 }
 
-func NewFuncType(params []Field, ret Type, c bool) *FuncType {
-	t := &FuncType{Params: params, Return: ret, C: c}
+func NewRawPointerType(elem Type, readOnly bool) *PointerType {
+	t := NewPointerType(elem, readOnly)
+	t.Raw = true
+	return t
+}
+
+func NewNullablePointerType(elem Type, readOnly bool) *PointerType {
+	t := NewPointerType(elem, readOnly)
+	t.Nullable = true
+	return t
+}
+
+func NewTupleType(elems []Type) *TupleType {
+	t := &TupleType{Elems: elems}
+	t.kind = TTuple
+	return t
+}
+
+func NewFuncType(params []Field, variadic bool, ret Type, c bool) *FuncType {
+	t := &FuncType{Params: params, Variadic: variadic, Return: ret, C: c}
 	t.kind = TFunc
 	return t
 }
 
+func NewClosureType(f *FuncType) *ClosureType {
+	t := &ClosureType{F: f}
+	t.kind = TClosure
+	return t
+}
+
+func NewInterfaceType(sym *Symbol) *InterfaceType {
+	t := &InterfaceType{Sym: sym}
+	t.kind = TInterface
+	return t
+}
+
+func (t *InterfaceType) SetBody(methods []InterfaceMethod, typedBody bool) {
+	t.Methods = methods
+	t.TypedBody = typedBody
+}
+
+func NewResultType(t Type, e Type) *ResultType {
+	tres := &ResultType{T: t, E: e}
+	tres.kind = TResult
+	return tres
+}
+
+func NewGenericType(sym *Symbol, typeParams []string) *GenericType {
+	t := &GenericType{Sym: sym, TypeParams: typeParams}
+	t.kind = TGeneric
+	return t
+}
+
 func ToBaseType(t Type) Type {
 	base := t
 	for {
@@ -507,6 +869,21 @@ func IsUnsignedType(t Type) bool {
 	return false
 }
 
+func IsRawPointerType(t Type) bool {
+	if tptr, ok := ToBaseType(t).(*PointerType); ok {
+		return tptr.Raw
+	}
+	return false
+}
+
+// IsNullablePointerType returns true if t is a nullable reference.
+func IsNullablePointerType(t Type) bool {
+	if tptr, ok := ToBaseType(t).(*PointerType); ok {
+		return tptr.Nullable
+	}
+	return false
+}
+
 func IsIntegerType(t Type) bool {
 	switch t.Kind() {
 	case TConstInt, TUSize, TUInt64, TInt64, TUInt32, TInt32, TUInt16, TInt16, TUInt8, TInt8:
@@ -0,0 +1,245 @@
+package ir
+
+import "fmt"
+
+// Walk traverses a syntax tree in depth-first order. It calls visit for each declaration,
+// statement and expression, and visits the children of the node if visit returns true.
+func Walk(node Node, visit func(Node) bool) {
+	switch node := node.(type) {
+	case nil:
+		return
+	case Decl:
+		walkDecl(node, visit)
+	case Stmt:
+		walkStmt(node, visit)
+	case Expr:
+		walkExpr(node, visit)
+	default:
+		panic(fmt.Sprintf("Unhandled node %T", node))
+	}
+}
+
+func walkDecl(decl Decl, visit func(Node) bool) {
+	if decl == nil || !visit(decl) {
+		return
+	}
+	switch decl := decl.(type) {
+	case *ImportDecl, *UseDecl:
+	case *TypeDecl:
+		walkExpr(decl.Type, visit)
+	case *ValDecl:
+		walkValDecl(decl, visit)
+	case *FuncDecl:
+		walkFuncDecl(decl, visit)
+	case *StructDecl:
+		walkValDeclList(decl.Fields, visit)
+		for _, method := range decl.Methods {
+			walkDecl(method, visit)
+		}
+	case *UnionDecl:
+		for _, variant := range decl.Variants {
+			walkValDeclList(variant.Fields, visit)
+		}
+	case *EnumDecl:
+		walkExpr(decl.Backing, visit)
+		for _, member := range decl.Members {
+			walkExpr(member.Value, visit)
+		}
+	case *InterfaceDecl:
+		for _, method := range decl.Methods {
+			walkDecl(method, visit)
+		}
+	case *StaticAssertDecl:
+		walkExpr(decl.Cond, visit)
+	default:
+		panic(fmt.Sprintf("Unhandled decl %T", decl))
+	}
+}
+
+func walkValDecl(decl *ValDecl, visit func(Node) bool) {
+	walkExpr(decl.Type, visit)
+	walkExpr(decl.Initializer, visit)
+}
+
+func walkValDeclList(decls []*ValDecl, visit func(Node) bool) {
+	for _, decl := range decls {
+		walkDecl(decl, visit)
+	}
+}
+
+func walkFuncDecl(decl *FuncDecl, visit func(Node) bool) {
+	walkValDeclList(decl.Params, visit)
+	if decl.Return != nil {
+		walkDecl(decl.Return, visit)
+	}
+	if decl.Body != nil {
+		walkStmt(decl.Body, visit)
+	}
+}
+
+func walkStmt(stmt Stmt, visit func(Node) bool) {
+	if stmt == nil || !visit(stmt) {
+		return
+	}
+	switch stmt := stmt.(type) {
+	case *BlockStmt:
+		for _, child := range stmt.Stmts {
+			walkStmt(child, visit)
+		}
+	case *DeclStmt:
+		walkDecl(stmt.D, visit)
+	case *DestructureStmt:
+		if stmt.Tuple != nil {
+			walkDecl(stmt.Tuple, visit)
+		}
+		walkValDeclList(stmt.Elems, visit)
+	case *IfStmt:
+		walkExpr(stmt.Cond, visit)
+		walkStmt(stmt.Body, visit)
+		walkStmt(stmt.Else, visit)
+	case *MatchStmt:
+		walkExpr(stmt.X, visit)
+		for _, arm := range stmt.Arms {
+			walkExprList(arm.Patterns, visit)
+			walkStmt(arm.Body, visit)
+		}
+		if stmt.Else != nil {
+			walkStmt(stmt.Else, visit)
+		}
+	case *ForStmt:
+		walkStmt(stmt.Init, visit)
+		walkStmt(stmt.Inc, visit)
+		walkExpr(stmt.Cond, visit)
+		if stmt.Range != nil {
+			walkExpr(stmt.Range.X, visit)
+			walkExpr(stmt.Range.End, visit)
+		}
+		walkStmt(stmt.Body, visit)
+	case *ReturnStmt:
+		walkExpr(stmt.X, visit)
+	case *DeferStmt:
+		walkStmt(stmt.S, visit)
+	case *BranchStmt:
+	case *AssignStmt:
+		walkExpr(stmt.Left, visit)
+		walkExpr(stmt.Right, visit)
+	case *ExprStmt:
+		walkExpr(stmt.X, visit)
+	case *StaticAssertStmt:
+		walkDecl(stmt.D, visit)
+	case *PanicStmt:
+		walkExpr(stmt.X, visit)
+	case *AssertStmt:
+		walkExpr(stmt.Cond, visit)
+		walkExpr(stmt.Msg, visit)
+	default:
+		panic(fmt.Sprintf("Unhandled stmt %T", stmt))
+	}
+}
+
+func walkExpr(expr Expr, visit func(Node) bool) {
+	if expr == nil || !visit(expr) {
+		return
+	}
+	switch expr := expr.(type) {
+	case *Ident, *ScopeLookup, *BasicLit, *DefaultInit:
+	case *Typeof:
+		walkExpr(expr.X, visit)
+	case *PointerTypeExpr:
+		walkExpr(expr.X, visit)
+	case *SliceTypeExpr:
+		walkExpr(expr.X, visit)
+	case *ArrayTypeExpr:
+		walkExpr(expr.X, visit)
+		walkExpr(expr.Size, visit)
+	case *TupleTypeExpr:
+		walkExprList(expr.Elems, visit)
+	case *ResultTypeExpr:
+		walkExpr(expr.Value, visit)
+		walkExpr(expr.Error, visit)
+	case *FuncTypeExpr:
+		walkValDeclList(expr.Params, visit)
+		if expr.Return != nil {
+			walkDecl(expr.Return, visit)
+		}
+	case *ArrayLit:
+		walkExpr(expr.Elem, visit)
+		walkExpr(expr.Size, visit)
+		walkExprList(expr.Initializers, visit)
+	case *TupleLit:
+		walkExprList(expr.Elems, visit)
+	case *TupleElemExpr:
+		walkExpr(expr.X, visit)
+	case *UnionLit:
+		walkArgList(expr.Args, visit)
+	case *BinaryExpr:
+		walkExpr(expr.Left, visit)
+		walkExpr(expr.Right, visit)
+	case *UnaryExpr:
+		walkExpr(expr.X, visit)
+	case *TryExpr:
+		walkExpr(expr.X, visit)
+	case *IfExpr:
+		walkExpr(expr.Cond, visit)
+		walkExpr(expr.Then, visit)
+		walkExpr(expr.Else, visit)
+	case *BlockExpr:
+		walkStmt(expr.Block, visit)
+		walkExpr(expr.X, visit)
+	case *AddrExpr:
+		walkExpr(expr.X, visit)
+	case *DerefExpr:
+		walkExpr(expr.X, visit)
+	case *DotExpr:
+		walkExpr(expr.X, visit)
+	case *IndexExpr:
+		walkExpr(expr.X, visit)
+		walkExpr(expr.Index, visit)
+	case *SliceExpr:
+		walkExpr(expr.X, visit)
+		walkExpr(expr.Start, visit)
+		walkExpr(expr.End, visit)
+	case *FuncLit:
+		walkDecl(expr.Decl, visit)
+	case *InstanceExpr:
+		walkExpr(expr.X, visit)
+		walkExprList(expr.Args, visit)
+	case *AppExpr:
+		walkExpr(expr.X, visit)
+		walkArgList(expr.Args, visit)
+	case *CastExpr:
+		walkExpr(expr.ToType, visit)
+		walkExpr(expr.X, visit)
+	case *LenExpr:
+		walkExpr(expr.X, visit)
+	case *SizeofExpr:
+		walkExpr(expr.X, visit)
+	case *OverflowExpr:
+		walkExpr(expr.Left, visit)
+		walkExpr(expr.Right, visit)
+	case *NewExpr:
+		walkExpr(expr.X, visit)
+		walkExpr(expr.Init, visit)
+	case *MakeExpr:
+		walkExpr(expr.X, visit)
+		walkExpr(expr.Size, visit)
+	case *DeleteExpr:
+		walkExpr(expr.X, visit)
+	case *ConstExpr:
+		walkExpr(expr.X, visit)
+	default:
+		panic(fmt.Sprintf("Unhandled expr %T", expr))
+	}
+}
+
+func walkExprList(exprs []Expr, visit func(Node) bool) {
+	for _, expr := range exprs {
+		walkExpr(expr, visit)
+	}
+}
+
+func walkArgList(args []*ArgExpr, visit func(Node) bool) {
+	for _, arg := range args {
+		walkExpr(arg.Value, visit)
+	}
+}
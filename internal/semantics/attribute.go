@@ -0,0 +1,297 @@
+package semantics
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// Attributes are written as '#[name]' or '#[name(args)]' before a declaration, field or parameter.
+// Every attribute is listed in the registry together with the declarations it can be applied to and
+// the arguments it takes. Valid attributes are stored on the symbol of the declaration.
+
+type attributeTarget int
+
+const (
+	attrTargetStruct attributeTarget = 1 << iota
+	attrTargetType
+	attrTargetFunc
+	attrTargetGenericFunc
+	attrTargetVar
+	attrTargetField
+	attrTargetParam
+)
+
+type attributeSpec struct {
+	targets   attributeTarget
+	args      []token.Token // Integer or String
+	conflicts []string
+	check     func(c *checker, attr *ir.Attribute) bool
+}
+
+const attrTargetAnyFunc = attrTargetFunc | attrTargetGenericFunc
+
+var attributeRegistry = map[string]attributeSpec{
+	ir.AttrPacked:       {targets: attrTargetStruct, conflicts: []string{ir.AttrAlign}},
+	ir.AttrAlign:        {targets: attrTargetStruct, args: []token.Token{token.Integer}, check: checkAlignAttribute},
+	ir.AttrInline:       {targets: attrTargetAnyFunc, conflicts: []string{ir.AttrNoInline, ir.AttrCold}},
+	ir.AttrNoInline:     {targets: attrTargetAnyFunc},
+	ir.AttrNoReturn:     {targets: attrTargetAnyFunc},
+	ir.AttrCold:         {targets: attrTargetAnyFunc},
+	ir.AttrDeprecated:   {targets: attrTargetType | attrTargetAnyFunc | attrTargetVar | attrTargetField, args: []token.Token{token.String}},
+	ir.AttrLinkName:     {targets: attrTargetFunc | attrTargetVar, args: []token.Token{token.String}, check: checkLinkNameAttribute},
+	ir.AttrPanicHandler: {targets: attrTargetFunc, conflicts: []string{ir.AttrAllocHandler, ir.AttrFreeHandler}},
+	ir.AttrAllocHandler: {targets: attrTargetFunc, conflicts: []string{ir.AttrFreeHandler}},
+	ir.AttrFreeHandler:  {targets: attrTargetFunc},
+}
+
+// checkAttributes reports unknown or misplaced attributes, and returns the valid attributes.
+func (c *checker) checkAttributes(attributes []*ir.Attribute, target attributeTarget, desc string) []*ir.Attribute {
+	var valid []*ir.Attribute
+	names := make(map[string]bool)
+	for _, attr := range attributes {
+		name := attr.Name.Literal
+		spec, ok := attributeRegistry[name]
+		if !ok {
+			c.nodeError(attr.Name, "unknown attribute '%s'", name)
+			continue
+		}
+		if names[name] {
+			c.nodeError(attr.Name, "duplicate attribute '%s'", name)
+			continue
+		}
+		names[name] = true
+		if (spec.targets & target) == 0 {
+			c.nodeError(attr.Name, "attribute '%s' cannot be applied to %s", name, desc)
+			continue
+		}
+		if !c.checkAttributeArgs(attr, spec) {
+			continue
+		}
+		if spec.check != nil && !spec.check(c, attr) {
+			continue
+		}
+		valid = append(valid, attr)
+	}
+	validNames := make(map[string]bool)
+	for _, attr := range valid {
+		validNames[attr.Name.Literal] = true
+	}
+	for _, attr := range valid {
+		for _, conflict := range attributeRegistry[attr.Name.Literal].conflicts {
+			if validNames[conflict] {
+				c.nodeError(attr.Name, "attributes '%s' and '%s' cannot be combined", attr.Name.Literal, conflict)
+				return nil
+			}
+		}
+	}
+	return valid
+}
+
+func (c *checker) checkAttributeArgs(attr *ir.Attribute, spec attributeSpec) bool {
+	name := attr.Name.Literal
+	if len(attr.Args) != len(spec.args) {
+		if len(spec.args) == 0 {
+			c.nodeError(attr, "attribute '%s' does not take arguments", name)
+		} else {
+			c.nodeError(attr, "wrong number of arguments for attribute '%s' (expected %d, got %d)", name, len(spec.args), len(attr.Args))
+		}
+		return false
+	}
+	valid := true
+	for i, arg := range attr.Args {
+		lit, ok := arg.(*ir.BasicLit)
+		if !ok || lit.Tok != spec.args[i] || lit.Prefix != nil || lit.Suffix != nil {
+			kind := "an integer"
+			if spec.args[i] == token.String {
+				kind = "a string"
+			}
+			c.nodeError(arg, "argument %d of attribute '%s' must be %s literal", i+1, name, kind)
+			valid = false
+			continue
+		}
+		if isInvalidType(c.checkBasicLit(lit).Type()) {
+			valid = false
+		}
+	}
+	return valid
+}
+
+// attributeInt returns the value of an integer argument which has been validated.
+func attributeInt(attr *ir.Attribute, index int) int {
+	raw := constRaw(attr.Args[index].(*ir.BasicLit)).(*big.Int)
+	return int(raw.Int64())
+}
+
+func checkAlignAttribute(c *checker, attr *ir.Attribute) bool {
+	raw := constRaw(attr.Args[0].(*ir.BasicLit)).(*big.Int)
+	if !raw.IsInt64() || raw.Int64() < 1 || raw.Int64() > maxAlignment || (raw.Int64()&(raw.Int64()-1)) != 0 {
+		c.nodeError(attr.Args[0], "alignment must be a power of two between 1 and %d (got %s)", maxAlignment, raw)
+		return false
+	}
+	return true
+}
+
+func checkLinkNameAttribute(c *checker, attr *ir.Attribute) bool {
+	name := attr.StringArg(0)
+	if len(name) == 0 {
+		c.nodeError(attr.Args[0], "link name cannot be empty")
+		return false
+	}
+	if prev, ok := c.linkNames[name]; ok {
+		c.nodeError(attr.Args[0], "duplicate link name '%s' (previous use is at %s)", name, prev.Pos())
+		return false
+	}
+	c.linkNames[name] = attr
+	return true
+}
+
+// maxAlignment is the strictest alignment which can be requested for a struct.
+const maxAlignment = 4096
+
+func declAttributeTarget(decl ir.Decl) attributeTarget {
+	switch decl := decl.(type) {
+	case *ir.StructDecl:
+		if decl.Opaque {
+			return attrTargetType
+		}
+		return attrTargetStruct | attrTargetType
+	case *ir.UnionDecl, *ir.EnumDecl, *ir.InterfaceDecl, *ir.TypeDecl:
+		return attrTargetType
+	case *ir.FuncDecl:
+		if len(decl.TypeParams) > 0 {
+			return attrTargetGenericFunc
+		}
+		return attrTargetFunc
+	case *ir.ValDecl:
+		return attrTargetVar
+	}
+	return 0
+}
+
+func declDescription(decl ir.Decl) string {
+	switch decl := decl.(type) {
+	case *ir.StructDecl:
+		kind := token.Struct
+		if decl.CUnion {
+			kind = token.CUnion
+		}
+		if decl.Opaque {
+			return "an opaque " + kind.String()
+		}
+		return "a " + kind.String()
+	case *ir.FuncDecl:
+		if len(decl.TypeParams) > 0 {
+			return "a generic function"
+		}
+		return "a function"
+	case *ir.ValDecl:
+		return "a variable"
+	case *ir.UnionDecl:
+		return "a union"
+	case *ir.EnumDecl:
+		return "an enum"
+	case *ir.InterfaceDecl:
+		return "an interface"
+	case *ir.TypeDecl:
+		return "a type alias"
+	case *ir.ImportDecl:
+		return "an import"
+	case *ir.UseDecl:
+		return "a use declaration"
+	case *ir.StaticAssertDecl:
+		return "a static assertion"
+	}
+	return "this declaration"
+}
+
+// setStructLayout sets the layout of a struct from its valid attributes.
+func setStructLayout(tstruct *ir.StructType, attributes []*ir.Attribute) {
+	for _, attr := range attributes {
+		switch attr.Name.Literal {
+		case ir.AttrPacked:
+			tstruct.Packed = true
+		case ir.AttrAlign:
+			tstruct.Align = attributeInt(attr, 0)
+		}
+	}
+}
+
+// checkGenericAttributes validates the attributes inside a generic declaration. Instances are cloned
+// from the declaration with only the valid attributes left, so errors are reported once instead of
+// once per instance.
+func (c *checker) checkGenericAttributes(decl ir.Decl) {
+	switch decl := decl.(type) {
+	case *ir.FuncDecl:
+		c.checkParamAttributes(decl.Params)
+	case *ir.StructDecl:
+		for _, field := range decl.Fields {
+			field.Attributes = c.checkAttributes(field.Attributes, attrTargetField, "a field")
+		}
+		for _, method := range decl.Methods {
+			method.Attributes = c.checkAttributes(method.Attributes, attrTargetGenericFunc, "a method of a generic struct")
+			c.checkParamAttributes(method.Params)
+		}
+	}
+}
+
+func (c *checker) checkParamAttributes(params []*ir.ValDecl) {
+	for _, param := range params {
+		param.Attributes = c.checkAttributes(param.Attributes, attrTargetParam, "a parameter")
+	}
+}
+
+// setLocalAttributes validates the attributes of a field or parameter and stores them on its symbol.
+func (c *checker) setLocalAttributes(decl *ir.ValDecl, target attributeTarget, desc string) {
+	if len(decl.Attributes) == 0 {
+		return
+	}
+	decl.Attributes = c.checkAttributes(decl.Attributes, target, desc)
+	if decl.Sym != nil {
+		decl.Sym.Attributes = decl.Attributes
+	}
+}
+
+// checkDeprecated warns if a deprecated declaration is referenced. Identifiers without a position
+// are inserted by the compiler (e.g. the Self type of a struct) and are ignored.
+func (c *checker) checkDeprecated(expr *ir.Ident) {
+	if !expr.Pos().IsValid() {
+		return
+	}
+	if attr := expr.Sym.Attribute(ir.AttrDeprecated); attr != nil {
+		c.warning(expr.Pos(), "'%s' is deprecated: %s", expr.Literal, attr.StringArg(0))
+	}
+}
+
+// checkRuntimeHandler checks a function which replaces a function in the runtime, and there can only
+// be one handler of each kind in a program. The panic handler is called with the position and the
+// message of a panic. The alloc and free handlers replace malloc and free for new, make and delete.
+func (c *checker) checkRuntimeHandler(decl *ir.FuncDecl, tfun *ir.FuncType, handler string) {
+	desc := strings.Replace(handler, "_", " ", -1)
+	texpected := runtimeHandlerType(handler, tfun.C)
+	if !texpected.Equals(tfun) {
+		c.error(decl.Name.Pos(), "%s '%s' must have type %s (got %s)", desc, decl.Name.Literal, texpected, tfun)
+	} else if decl.SignatureOnly() {
+		c.error(decl.Name.Pos(), "%s '%s' must have a body", desc, decl.Name.Literal)
+	} else if prev := c.handlers[handler]; prev != nil && prev != decl.Sym {
+		c.error(decl.Name.Pos(), "duplicate %s '%s' (previous handler is at %s)", desc, decl.Name.Literal, prev.Pos)
+	} else {
+		c.handlers[handler] = decl.Sym
+	}
+}
+
+func runtimeHandlerType(handler string, cabi bool) *ir.FuncType {
+	switch handler {
+	case ir.AttrAllocHandler:
+		params := []ir.Field{{Name: "size", T: ir.TBuiltinUSize}, {Name: "align", T: ir.TBuiltinUSize}}
+		return ir.NewFuncType(params, false, ir.NewRawPointerType(ir.TBuiltinByte, false), cabi)
+	case ir.AttrFreeHandler:
+		params := []ir.Field{{Name: "ptr", T: ir.NewRawPointerType(ir.TBuiltinByte, false)}}
+		return ir.NewFuncType(params, false, ir.TBuiltinVoid, cabi)
+	}
+	tstring := ir.NewSliceType(ir.TBuiltinByte, true, true)
+	params := []ir.Field{{Name: "pos", T: tstring}, {Name: "msg", T: tstring}}
+	return ir.NewFuncType(params, false, ir.TBuiltinVoid, cabi)
+}
@@ -16,6 +16,7 @@ func Check(ctx *common.BuildContext, target ir.Target, fileMatrix ir.FileMatrix)
 	modMatrix := c.createModuleMatrix(fileMatrix)
 	c.initObjectMatrix(modMatrix)
 	c.checkTypes()
+	c.checkInterfaceConversions()
 	declMatrix := c.createDeclMatrix()
 	return declMatrix, !ctx.IsErrorSinceCheckpoint()
 }
@@ -38,8 +39,22 @@ type checker struct {
 
 	importMap  map[string]*ir.Symbol
 	constMap   map[ir.SymbolKey]ir.Expr
+	constVals  map[ir.SymbolKey]*ir.BasicLit
 	objectMap  map[ir.SymbolKey]*object
 	incomplete map[ir.SymbolKey]*object
+	generics   map[ir.SymbolKey]*genericDecl
+	captured   map[ir.SymbolKey]*ir.Symbol
+	noEscape   map[ir.SymbolKey]bool
+	narrowed   map[string]*ir.Symbol
+	addrTaken  map[ir.SymbolKey]map[string]bool // Names of variables whose mutable address is taken, by function
+
+	conversions []*interfaceConversion
+	converted   map[string]bool
+
+	handlers  map[string]*ir.Symbol    // Runtime handlers by attribute
+	linkNames map[string]*ir.Attribute // Link name attributes by name
+
+	instanceDepth int
 
 	// Ast traversal state
 	objectList *objectList
@@ -47,7 +62,11 @@ type checker struct {
 	scope      *ir.Scope
 	mode       int
 	step       int
-	loop       int
+	loops      []*ir.ForStmt
+	deferred   bool
+	blockExpr  bool
+	addressed  map[string]bool
+	operand    ir.Expr
 }
 
 func stmtList(stmts []ir.Stmt, visit func(ir.Stmt)) {
@@ -63,8 +82,16 @@ func newChecker(ctx *common.BuildContext, target ir.Target) *checker {
 		currentSymKey: 1,
 		importMap:     make(map[string]*ir.Symbol),
 		constMap:      make(map[ir.SymbolKey]ir.Expr),
+		constVals:     make(map[ir.SymbolKey]*ir.BasicLit),
 		objectMap:     make(map[ir.SymbolKey]*object),
 		incomplete:    make(map[ir.SymbolKey]*object),
+		generics:      make(map[ir.SymbolKey]*genericDecl),
+		captured:      make(map[ir.SymbolKey]*ir.Symbol),
+		noEscape:      make(map[ir.SymbolKey]bool),
+		addrTaken:     make(map[ir.SymbolKey]map[string]bool),
+		converted:     make(map[string]bool),
+		handlers:      make(map[string]*ir.Symbol),
+		linkNames:     make(map[string]*ir.Attribute),
 	}
 }
 
@@ -0,0 +1,193 @@
+package semantics
+
+import (
+	"github.com/cjo5/dingo/internal/ir"
+)
+
+// funcLit is the capture state of a function literal.
+type funcLit struct {
+	expr     *ir.FuncLit
+	parent   *funcLit  // Enclosing function literal
+	root     *ir.Scope // Scope of the enclosing top-level function, or nil if there is none
+	captures map[ir.SymbolKey]*ir.Capture
+}
+
+// Function literals are checked as separate objects, but since their type depends
+// on whether they capture variables, the literal expression isn't typed until the
+// signature of the literal is known.
+func (c *checker) checkFuncLit(expr *ir.FuncLit) ir.Expr {
+	decl := expr.Decl
+	if decl.Sym == nil {
+		obj := c.insertFuncLit(expr)
+		if obj == nil {
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
+		objects := []*object{obj}
+		c.checkNestedObjects(objects, 0)
+		if c.step > 0 {
+			c.checkNestedObjects(objects, c.step)
+		}
+	} else if c.step > 0 {
+		obj := c.objectMap[decl.Sym.UniqKey]
+		if obj.incomplete && obj.color == blackColor {
+			// Captured variables may have been typed after the literal was checked
+			obj.color = whiteColor
+		}
+		c.checkNestedObjects([]*object{obj}, c.step)
+	}
+
+	c.trySetDep(decl.Sym, true)
+	tfun := decl.Sym.T
+	if isUntyped(tfun) {
+		expr.T = tfun
+		return expr
+	}
+	for _, capture := range decl.Captures {
+		if isUntyped(capture.Inner.T) {
+			expr.T = capture.Inner.T
+			return expr
+		}
+	}
+
+	if len(decl.Captures) > 0 {
+		expr.T = ir.NewClosureType(ir.ToBaseType(tfun).(*ir.FuncType))
+	} else {
+		expr.T = tfun
+	}
+	return expr
+}
+
+func (c *checker) insertFuncLit(expr *ir.FuncLit) *object {
+	decl := expr.Decl
+	abi := ir.DGABI
+	if expr.ABI != nil {
+		if ir.IsValidABI(expr.ABI.Literal) {
+			abi = expr.ABI.Literal
+		} else {
+			c.error(expr.ABI.Pos(), "unknown abi '%s'", expr.ABI.Literal)
+			return nil
+		}
+	}
+
+	lit := &funcLit{
+		expr:     expr,
+		captures: make(map[ir.SymbolKey]*ir.Capture),
+	}
+	if fun, ok := c.object.d.(*ir.FuncDecl); ok {
+		if c.object.funcLit != nil {
+			lit.parent = c.object.funcLit
+			lit.root = lit.parent.root
+		} else {
+			lit.root = fun.Scope
+		}
+	}
+
+	outer := c.object.sym()
+	sym := c.newTopDeclSymbol(ir.FuncSymbol, outer.CUID, outer.ModFQN, abi, false, decl.Name.Literal, decl.Name.Pos(), true)
+	// Literals in generic instances are unique per instance
+	sym.TypeArgs = outer.TypeArgs
+	decl.Sym = sym
+	decl.Name.Sym = sym
+	decl.Scope = ir.NewScope("fun", c.scope, sym.CUID)
+	decl.Body.Scope = decl.Scope
+
+	obj := newObject(decl, c.scope, true)
+	obj.funcLit = lit
+	objList := c.objectMatrix[sym.CUID]
+	objList.objects = append(objList.objects, obj)
+	c.objectMap[sym.UniqKey] = obj
+
+	return obj
+}
+
+// A variable is captured if it's defined in an enclosing function and used by a function literal.
+// Nested literals capture the variable from the closest enclosing literal, which in turn captures it
+// from its enclosing function.
+func (c *checker) tryCapture(lit *funcLit, ident *ir.Ident, sym *ir.Symbol) *ir.Symbol {
+	if lit == nil || lit.root == nil || sym.Kind != ir.ValSymbol || sym.IsTopDecl() || sym.IsBuiltin() {
+		return sym
+	}
+	decl := lit.expr.Decl
+	if !isDefinedBetween(decl.Scope.Parent, lit.root, sym) {
+		return sym
+	}
+	if capture, ok := lit.captures[sym.UniqKey]; ok {
+		return capture.Inner
+	}
+	if decl.Sym.ABI == ir.CABI {
+		c.nodeError(ident, "function literal with abi '%s' cannot capture '%s'", decl.Sym.ABI, ident.Literal)
+		return nil
+	}
+	outer := c.tryCapture(lit.parent, ident, sym)
+	if outer == nil {
+		return nil
+	}
+	if c.noEscape[outer.UniqKey] && !lit.expr.Stack {
+		c.nodeError(ident, "closure parameter '%s' cannot be captured by an escaping function literal", ident.Literal)
+		return nil
+	}
+
+	key := c.nextSymKey()
+	inner := ir.NewSymbol(ir.ValSymbol, key, decl.Sym.CUID, decl.Sym.ModFQN, sym.Name, sym.Pos)
+	inner.Flags = ir.SymFlagDefined | ir.SymFlagReadOnly
+	inner.T = outer.T
+
+	capture := &ir.Capture{Outer: outer, Inner: inner}
+	decl.Captures = append(decl.Captures, capture)
+	lit.captures[sym.UniqKey] = capture
+	c.captured[inner.UniqKey] = outer
+	if c.noEscape[outer.UniqKey] {
+		c.noEscape[inner.UniqKey] = true
+	}
+	return inner
+}
+
+// The type of a captured variable is unknown if the variable depends on an incomplete object.
+// The literal is checked again once the variable has been typed.
+func (c *checker) updateCaptureType(sym *ir.Symbol) {
+	if outer, ok := c.captured[sym.UniqKey]; ok && isUnknownType(sym.T) {
+		c.updateCaptureType(outer)
+		sym.T = outer.T
+		if isUnknownType(sym.T) {
+			c.setIncompleteObject()
+		}
+	}
+}
+
+func isDefinedBetween(from *ir.Scope, to *ir.Scope, sym *ir.Symbol) bool {
+	for scope := from; scope != nil; scope = scope.Parent {
+		if scope.Symbols[sym.Name] == sym {
+			return true
+		}
+		if scope == to {
+			break
+		}
+	}
+	return false
+}
+
+// checkOperand checks the callee or an argument of a function call. Function literals
+// and closure parameters used as operands don't escape.
+func (c *checker) checkOperand(expr ir.Expr, mode int) ir.Expr {
+	if lit, ok := expr.(*ir.FuncLit); ok {
+		lit.Stack = true
+	}
+	prevOperand := c.operand
+	c.operand = expr
+	expr = c.checkExpr2(expr, mode)
+	c.operand = prevOperand
+	return expr
+}
+
+func (c *checker) checkNoEscape(expr *ir.Ident) bool {
+	if c.noEscape[expr.Sym.UniqKey] && c.operand != expr {
+		c.nodeError(expr, "closure parameter '%s' cannot escape", expr.Literal)
+		return false
+	}
+	return true
+}
+
+func isClosureParam(param *ir.ValDecl) bool {
+	return param.Sym != nil && param.Sym.T.Kind() == ir.TClosure
+}
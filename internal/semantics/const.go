@@ -0,0 +1,514 @@
+package semantics
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// Evaluation of compile-time constant expressions.
+//
+// Integers are evaluated as big ints and floats as big floats. A typed result must fit
+// its type, which means that overflow is detected when a value is narrowed to its target
+// type, while intermediate results of untyped constants can be arbitrarily large.
+// Explicit integer casts truncate the value in the same way as at runtime.
+
+// Shifting a constant further than this is reported as an error.
+const maxConstShift = 512
+
+// foldConstExpr returns a literal with the value of expr if it is a constant expression.
+// Otherwise expr is returned unchanged. If the evaluation fails, an error is reported
+// and the type of expr is set to invalid.
+func (c *checker) foldConstExpr(expr ir.Expr) ir.Expr {
+	if isUntypedExpr(expr) {
+		return expr
+	}
+	lit, ok := c.evalConstExpr(expr)
+	if !ok {
+		expr.SetType(ir.TBuiltinInvalid)
+		return expr
+	}
+	if lit == nil {
+		return c.foldConstElems(expr)
+	}
+	lit.SetRange(expr.Pos(), expr.EndPos())
+	return lit
+}
+
+// Elements of composite literals and operands of non-constant expressions are folded individually.
+func (c *checker) foldConstElems(expr ir.Expr) ir.Expr {
+	invalid := false
+	switch expr := expr.(type) {
+	case *ir.BinaryExpr:
+		expr.Left = c.foldConstExpr(expr.Left)
+		expr.Right = c.foldConstExpr(expr.Right)
+		invalid = isInvalidType(expr.Left.Type()) || isInvalidType(expr.Right.Type())
+	case *ir.UnaryExpr:
+		expr.X = c.foldConstExpr(expr.X)
+		invalid = isInvalidType(expr.X.Type())
+	case *ir.CastExpr:
+		expr.X = c.foldConstExpr(expr.X)
+		invalid = isInvalidType(expr.X.Type())
+	case *ir.ArrayLit:
+		for i, init := range expr.Initializers {
+			expr.Initializers[i] = c.foldConstExpr(init)
+			invalid = invalid || isInvalidType(expr.Initializers[i].Type())
+		}
+	case *ir.TupleLit:
+		for i, elem := range expr.Elems {
+			expr.Elems[i] = c.foldConstExpr(elem)
+			invalid = invalid || isInvalidType(expr.Elems[i].Type())
+		}
+	case *ir.AppExpr:
+		if expr.IsStruct {
+			for _, arg := range expr.Args {
+				arg.Value = c.foldConstExpr(arg.Value)
+				invalid = invalid || isInvalidType(arg.Value.Type())
+			}
+		}
+	}
+	if invalid {
+		expr.SetType(ir.TBuiltinInvalid)
+	}
+	return expr
+}
+
+// evalConstExpr returns the value of expr as a literal, or nil if expr is not a constant expression.
+// False is returned if an error was reported.
+func (c *checker) evalConstExpr(expr ir.Expr) (*ir.BasicLit, bool) {
+	if !isConstValueType(expr.Type()) {
+		return nil, true
+	}
+	switch expr := expr.(type) {
+	case *ir.BasicLit:
+		if raw, ok := constRaw(expr).(*big.Int); ok && ir.IsIntegerType(expr.T) {
+			return c.typedConstInt(expr, raw, expr.T)
+		} else if raw := constRaw(expr); raw != nil {
+			return newConstLit(raw, expr.T), true
+		}
+	case *ir.ConstExpr:
+		if lit, ok := expr.X.(*ir.BasicLit); ok {
+			return newConstLit(constRaw(lit), expr.T), true
+		}
+	case *ir.Ident:
+		return c.constValue(expr.Sym, expr.T), true
+	case *ir.ScopeLookup:
+		return c.constValue(expr.Last().Sym, expr.T), true
+	case *ir.BinaryExpr:
+		return c.evalConstBinaryExpr(expr)
+	case *ir.UnaryExpr:
+		return c.evalConstUnaryExpr(expr)
+	case *ir.CastExpr:
+		return c.evalConstCastExpr(expr)
+	case *ir.OverflowExpr:
+		return c.evalConstOverflowExpr(expr)
+	case *ir.IfExpr:
+		return c.evalConstIfExpr(expr)
+	case *ir.BlockExpr:
+		if len(expr.Block.Stmts) == 0 {
+			return c.evalConstExpr(expr.X)
+		}
+	case *ir.LenExpr:
+		if tarray, ok := ir.ToBaseType(expr.X.Type()).(*ir.ArrayType); ok && isSideEffectFree(expr.X) {
+			return newConstLit(big.NewInt(int64(tarray.Size)), expr.T), true
+		}
+	}
+	return nil, true
+}
+
+func (c *checker) constValue(sym *ir.Symbol, t ir.Type) *ir.BasicLit {
+	if sym != nil {
+		if lit, ok := c.constVals[sym.Key]; ok {
+			return newConstLit(constRaw(lit), t)
+		}
+	}
+	return nil
+}
+
+// Returns true if values of type t can be the result of a constant expression.
+func isConstValueType(t ir.Type) bool {
+	return ir.IsNumericType(t) || t.Kind() == ir.TBool
+}
+
+func isSideEffectFree(expr ir.Expr) bool {
+	switch expr := expr.(type) {
+	case *ir.Ident, *ir.ScopeLookup:
+		return true
+	case *ir.DotExpr:
+		return isSideEffectFree(expr.X)
+	case *ir.DerefExpr:
+		return isSideEffectFree(expr.X)
+	}
+	return false
+}
+
+// constRaw returns the big int, big float, or bool value of a literal, or nil if it has none.
+func constRaw(lit *ir.BasicLit) interface{} {
+	if lit.Tok.OneOf(token.True, token.False) {
+		return lit.Tok == token.True
+	}
+	switch lit.Raw.(type) {
+	case *big.Int, *big.Float:
+		return lit.Raw
+	}
+	return nil
+}
+
+// newConstLit creates a literal from a big int, big float, or bool value.
+// Integer values of float type are converted to floats.
+func newConstLit(raw interface{}, t ir.Type) *ir.BasicLit {
+	lit := &ir.BasicLit{}
+	if val, ok := raw.(*big.Int); ok && ir.IsFloatType(t) {
+		raw = big.NewFloat(0).SetInt(val)
+	}
+	switch raw := raw.(type) {
+	case *big.Int:
+		lit.Tok = token.Integer
+		lit.Value = raw.String()
+		lit.Raw = big.NewInt(0).Set(raw)
+	case *big.Float:
+		lit.Tok = token.Float
+		lit.Value = raw.String()
+		lit.Raw = big.NewFloat(0).Set(raw)
+	case bool:
+		lit.Tok = token.False
+		if raw {
+			lit.Tok = token.True
+		}
+		lit.Value = lit.Tok.String()
+	}
+	lit.T = t
+	return lit
+}
+
+func constBool(lit *ir.BasicLit) bool {
+	return lit.Tok == token.True
+}
+
+func (c *checker) evalConstBinaryExpr(expr *ir.BinaryExpr) (*ir.BasicLit, bool) {
+	left, ok := c.evalConstExpr(expr.Left)
+	if left == nil {
+		return nil, ok
+	}
+	right, ok := c.evalConstExpr(expr.Right)
+	if right == nil {
+		return nil, ok
+	}
+
+	if expr.Op.OneOf(token.Land, token.Lor) {
+		if expr.Op == token.Land {
+			return newConstLit(constBool(left) && constBool(right), expr.T), true
+		}
+		return newConstLit(constBool(left) || constBool(right), expr.T), true
+	}
+
+	if expr.Op.OneOf(token.Eq, token.Neq, token.Gt, token.GtEq, token.Lt, token.LtEq) {
+		cmp := 0
+		switch x := left.Raw.(type) {
+		case *big.Int:
+			cmp = x.Cmp(right.Raw.(*big.Int))
+		case *big.Float:
+			cmp = x.Cmp(right.Raw.(*big.Float))
+		default:
+			if constBool(left) != constBool(right) {
+				cmp = 1
+			}
+		}
+		res := false
+		switch expr.Op {
+		case token.Eq:
+			res = cmp == 0
+		case token.Neq:
+			res = cmp != 0
+		case token.Gt:
+			res = cmp > 0
+		case token.GtEq:
+			res = cmp >= 0
+		case token.Lt:
+			res = cmp < 0
+		case token.LtEq:
+			res = cmp <= 0
+		}
+		return newConstLit(res, expr.T), true
+	}
+
+	switch x := left.Raw.(type) {
+	case *big.Int:
+		y := right.Raw.(*big.Int)
+		res := big.NewInt(0)
+		switch expr.Op {
+		case token.Add:
+			res.Add(x, y)
+		case token.Sub:
+			res.Sub(x, y)
+		case token.Mul:
+			res.Mul(x, y)
+		case token.Div, token.Mod:
+			if y.Sign() == 0 {
+				c.nodeError(expr, "division by zero")
+				return nil, false
+			}
+			if expr.Op == token.Div {
+				res.Quo(x, y)
+			} else {
+				res.Rem(x, y)
+			}
+		case token.BitAnd:
+			res.And(x, y)
+		case token.BitOr:
+			res.Or(x, y)
+		case token.BitXor:
+			res.Xor(x, y)
+		case token.Shl, token.Shr:
+			if y.Sign() < 0 {
+				c.nodeError(expr.Right, "negative shift count %s", y)
+				return nil, false
+			}
+			if expr.Op == token.Shr {
+				if y.IsUint64() {
+					res.Rsh(x, uint(y.Uint64()))
+				} else if x.Sign() < 0 {
+					res.SetInt64(-1)
+				}
+			} else if y.Cmp(big.NewInt(maxConstShift)) > 0 {
+				c.nodeError(expr.Right, "shift count %s is too large", y)
+				return nil, false
+			} else {
+				res.Lsh(x, uint(y.Uint64()))
+			}
+		default:
+			return nil, true
+		}
+		return c.typedConstInt(expr, res, expr.T)
+	case *big.Float:
+		y := right.Raw.(*big.Float)
+		res := big.NewFloat(0)
+		switch expr.Op {
+		case token.Add:
+			res.Add(x, y)
+		case token.Sub:
+			res.Sub(x, y)
+		case token.Mul:
+			res.Mul(x, y)
+		case token.Div:
+			if y.Sign() == 0 {
+				c.nodeError(expr, "division by zero")
+				return nil, false
+			}
+			res.Quo(x, y)
+		default:
+			return nil, true
+		}
+		return c.typedConstFloat(expr, res, expr.T)
+	}
+
+	return nil, true
+}
+
+// Only the branch selected by a constant condition has to be a constant expression.
+func (c *checker) evalConstIfExpr(expr *ir.IfExpr) (*ir.BasicLit, bool) {
+	cond, ok := c.evalConstExpr(expr.Cond)
+	if cond == nil {
+		return nil, ok
+	}
+	if constBool(cond) {
+		return c.evalConstExpr(expr.Then)
+	}
+	return c.evalConstExpr(expr.Else)
+}
+
+// The sum of wrapping_add and saturating_add is a constant if both operands are constants.
+// The tuple returned by checked_add is never a constant.
+func (c *checker) evalConstOverflowExpr(expr *ir.OverflowExpr) (*ir.BasicLit, bool) {
+	if expr.Op == token.CheckedAdd {
+		// The result is a tuple
+		return nil, true
+	}
+	left, ok := c.evalConstExpr(expr.Left)
+	if left == nil {
+		return nil, ok
+	}
+	right, ok := c.evalConstExpr(expr.Right)
+	if right == nil {
+		return nil, ok
+	}
+	res := big.NewInt(0).Add(left.Raw.(*big.Int), right.Raw.(*big.Int))
+	if expr.T.Kind() == ir.TConstInt {
+		// An untyped result is exact until it gets a type from the context
+		return newConstLit(res, expr.T), true
+	}
+	if expr.Op == token.SaturatingAdd {
+		if max := maxIntValue(expr.T); res.Cmp(max) > 0 {
+			res = max
+		} else if min := minIntValue(expr.T); res.Cmp(min) < 0 {
+			res = min
+		}
+	} else {
+		res = truncateConstInt(res, expr.T)
+	}
+	return newConstLit(res, expr.T), true
+}
+
+func (c *checker) evalConstUnaryExpr(expr *ir.UnaryExpr) (*ir.BasicLit, bool) {
+	x, ok := c.evalConstExpr(expr.X)
+	if x == nil {
+		return nil, ok
+	}
+	switch raw := x.Raw.(type) {
+	case *big.Int:
+		res := big.NewInt(0)
+		switch expr.Op {
+		case token.Sub:
+			res.Neg(raw)
+		case token.BitNot:
+			if ir.IsUnsignedType(expr.T) {
+				res.Xor(raw, maxIntValue(expr.T))
+			} else {
+				res.Not(raw)
+			}
+		default:
+			return nil, true
+		}
+		return c.typedConstInt(expr, res, expr.T)
+	case *big.Float:
+		if expr.Op == token.Sub {
+			return c.typedConstFloat(expr, big.NewFloat(0).Neg(raw), expr.T)
+		}
+	default:
+		if expr.Op == token.Lnot {
+			return newConstLit(!constBool(x), expr.T), true
+		}
+	}
+	return nil, true
+}
+
+func (c *checker) evalConstCastExpr(expr *ir.CastExpr) (*ir.BasicLit, bool) {
+	var x *ir.BasicLit
+	if constExpr, ok := expr.X.(*ir.ConstExpr); ok && constExpr.T.Kind() == ir.TEnum {
+		// Enum members are cast through their backing type
+		x, _ = constExpr.X.(*ir.BasicLit)
+	} else {
+		var ok bool
+		if x, ok = c.evalConstExpr(expr.X); x == nil {
+			return nil, ok
+		}
+	}
+	if x == nil || !ir.IsNumericType(expr.T) {
+		return nil, true
+	}
+
+	implicit := expr.ToType == nil
+
+	switch raw := x.Raw.(type) {
+	case *big.Int:
+		if ir.IsFloatType(expr.T) {
+			return c.typedConstFloat(expr, big.NewFloat(0).SetInt(raw), expr.T)
+		} else if implicit {
+			return c.typedConstInt(expr, raw, expr.T)
+		}
+		return newConstLit(truncateConstInt(raw, expr.T), expr.T), true
+	case *big.Float:
+		if ir.IsFloatType(expr.T) {
+			return c.typedConstFloat(expr, raw, expr.T)
+		}
+		res, _ := raw.Int(nil)
+		return c.typedConstInt(expr, res, expr.T)
+	}
+
+	return nil, true
+}
+
+func (c *checker) typedConstInt(expr ir.Expr, val *big.Int, t ir.Type) (*ir.BasicLit, bool) {
+	if !integerFitsType(val, t) {
+		c.nodeError(expr, "constant %s overflows type '%s'", val, t)
+		return nil, false
+	}
+	return newConstLit(val, t), true
+}
+
+// Typed float constants are rounded to the precision of their type.
+func (c *checker) typedConstFloat(expr ir.Expr, val *big.Float, t ir.Type) (*ir.BasicLit, bool) {
+	switch ir.ToBaseType(t).Kind() {
+	case ir.TFloat32:
+		f, _ := val.Float32()
+		if math.IsInf(float64(f), 0) {
+			c.nodeError(expr, "constant %s overflows type '%s'", val.Text('g', 10), t)
+			return nil, false
+		}
+		val = big.NewFloat(float64(f))
+	case ir.TFloat64:
+		f, _ := val.Float64()
+		if math.IsInf(f, 0) {
+			c.nodeError(expr, "constant %s overflows type '%s'", val.Text('g', 10), t)
+			return nil, false
+		}
+		val = big.NewFloat(f)
+	}
+	return newConstLit(val, t), true
+}
+
+// truncateConstInt wraps val to the size of the integer type t.
+func truncateConstInt(val *big.Int, t ir.Type) *big.Int {
+	max := maxIntValue(t)
+	if max == nil {
+		return val
+	}
+	res := big.NewInt(0).And(val, maxUnsignedValue(t))
+	if ir.IsSignedType(t) && res.Cmp(max) > 0 {
+		res.Sub(res, big.NewInt(0).Add(maxUnsignedValue(t), big.NewInt(1)))
+	}
+	return res
+}
+
+func maxIntValue(t ir.Type) *big.Int {
+	switch ir.ToBaseType(t).Kind() {
+	case ir.TUInt64, ir.TUSize:
+		return ir.MaxU64
+	case ir.TUInt32:
+		return ir.MaxU32
+	case ir.TUInt16:
+		return ir.MaxU16
+	case ir.TUInt8:
+		return ir.MaxU8
+	case ir.TInt64:
+		return ir.MaxI64
+	case ir.TInt32:
+		return ir.MaxI32
+	case ir.TInt16:
+		return ir.MaxI16
+	case ir.TInt8:
+		return ir.MaxI8
+	}
+	return nil
+}
+
+func minIntValue(t ir.Type) *big.Int {
+	switch ir.ToBaseType(t).Kind() {
+	case ir.TInt64:
+		return ir.MinI64
+	case ir.TInt32:
+		return ir.MinI32
+	case ir.TInt16:
+		return ir.MinI16
+	case ir.TInt8:
+		return ir.MinI8
+	}
+	return ir.BigIntZero
+}
+
+// Returns the all-ones bit pattern with the same size as the integer type t.
+func maxUnsignedValue(t ir.Type) *big.Int {
+	switch ir.ToBaseType(t).Kind() {
+	case ir.TInt64:
+		return ir.MaxU64
+	case ir.TInt32:
+		return ir.MaxU32
+	case ir.TInt16:
+		return ir.MaxU16
+	case ir.TInt8:
+		return ir.MaxU8
+	}
+	return maxIntValue(t)
+}
@@ -0,0 +1,453 @@
+package semantics
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// Limits how deeply generic instantiations can trigger other instantiations,
+// which prevents infinite recursion for instantiations such as f[T] -> f[&T].
+const maxInstanceDepth = 64
+
+// A generic declaration is not checked directly. Instead, a copy of the
+// unchecked declaration is created and checked for each unique list of type arguments.
+type genericDecl struct {
+	sym        *ir.Symbol
+	decl       *ir.TopDecl
+	scope      *ir.Scope
+	typeParams []*ir.Ident
+	instances  map[string]*genericInstance
+}
+
+type genericInstance struct {
+	sym     *ir.Symbol
+	objects []*object
+}
+
+func genericTypeParams(decl ir.Decl) []*ir.Ident {
+	switch decl := decl.(type) {
+	case *ir.FuncDecl:
+		return decl.TypeParams
+	case *ir.StructDecl:
+		return decl.TypeParams
+	}
+	return nil
+}
+
+// Parameters of a generic function or fields of a generic struct.
+func (g *genericDecl) params() []*ir.ValDecl {
+	switch decl := g.decl.D.(type) {
+	case *ir.FuncDecl:
+		return decl.Params
+	case *ir.StructDecl:
+		return decl.Fields
+	}
+	return nil
+}
+
+func (g *genericDecl) typeParamIndex(name string) int {
+	for i, param := range g.typeParams {
+		if param.Literal == name {
+			return i
+		}
+	}
+	return -1
+}
+
+func typeArgsString(targs []ir.Type) string {
+	var buf bytes.Buffer
+	for i, targ := range targs {
+		buf.WriteString(targ.String())
+		if (i + 1) < len(targs) {
+			buf.WriteString(", ")
+		}
+	}
+	return buf.String()
+}
+
+// Type arguments in expressions are parsed as expressions.
+func toTypeArgExpr(expr ir.Expr) ir.Expr {
+	if addr, ok := expr.(*ir.AddrExpr); ok {
+		ptr := &ir.PointerTypeExpr{X: toTypeArgExpr(addr.X)}
+		ptr.Decl = token.Var
+		if addr.Immutable {
+			ptr.Decl = token.Val
+		}
+		ptr.SetRange(addr.Pos(), addr.EndPos())
+		return ptr
+	}
+	return expr
+}
+
+func (c *checker) checkInstanceExpr(expr *ir.InstanceExpr) ir.Expr {
+	if isUnknownExprType(expr.X) {
+		expr.X = c.checkExpr2(expr.X, modeBoth)
+		if isUntypedExpr(expr.X) {
+			expr.T = expr.X.Type()
+			return expr
+		}
+	}
+
+	tgeneric, ok := expr.X.Type().(*ir.GenericType)
+	if !ok {
+		c.nodeError(expr.X, "type arguments cannot be used on type '%s'", expr.X.Type())
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	} else if len(expr.Args) != len(tgeneric.TypeParams) {
+		if len(expr.Args) > len(tgeneric.TypeParams) {
+			c.nodeError(expr, "too many type arguments (expected %d, got %d)", len(tgeneric.TypeParams), len(expr.Args))
+		} else {
+			c.nodeError(expr, "too few type arguments (expected %d, got %d)", len(tgeneric.TypeParams), len(expr.Args))
+		}
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	var tuntyped ir.Type
+	for i, arg := range expr.Args {
+		expr.Args[i] = c.checkExpr2(toTypeArgExpr(arg), modeIndirectType)
+		tuntyped = checkUntyped(expr.Args[i].Type(), tuntyped)
+	}
+	if tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	var targs []ir.Type
+	for _, arg := range expr.Args {
+		targs = append(targs, arg.Type())
+	}
+
+	gen := c.generics[tgeneric.Sym.Key]
+	if inst := c.instantiate(gen, targs, expr); inst != nil {
+		return c.instanceIdent(inst, expr)
+	}
+	expr.T = ir.TBuiltinInvalid
+	return expr
+}
+
+// Infer the type arguments of a generic function call or struct literal
+// from the argument types.
+func (c *checker) inferTypeArgs(expr *ir.AppExpr, tgeneric *ir.GenericType) *ir.Ident {
+	var tuntyped ir.Type
+	for i, arg := range expr.Args {
+		expr.Args[i].Value = c.checkOperand(arg.Value, c.mode)
+		tuntyped = checkUntyped(expr.Args[i].Value.Type(), tuntyped)
+	}
+	if tuntyped != nil {
+		expr.T = tuntyped
+		return nil
+	}
+
+	gen := c.generics[tgeneric.Sym.Key]
+	params := gen.params()
+	targs := make([]ir.Type, len(gen.typeParams))
+
+	// Untyped constants are only used if the type parameter can't be inferred from other arguments.
+	for _, constArgs := range []bool{false, true} {
+		for argIndex, arg := range expr.Args {
+			var param *ir.ValDecl
+			if arg.Name != nil {
+				for _, p := range params {
+					if p.Name.Literal == arg.Name.Literal {
+						param = p
+						break
+					}
+				}
+			} else if argIndex < len(params) {
+				param = params[argIndex]
+			}
+			if param == nil || param.Type == nil {
+				continue
+			}
+			targ := arg.Value.Type()
+			if isTypeOneOf(targ, ir.TConstInt, ir.TConstFloat, ir.TNull) {
+				if !constArgs {
+					continue
+				}
+				x := ir.CloneExpr(arg.Value)
+				x, _ = tryPromoteConstType(x, nil)
+				targ = x.Type()
+			}
+			unifyTypeArgs(gen, param.Type, targ, targs)
+		}
+	}
+
+	for i, targ := range targs {
+		if targ == nil {
+			c.nodeError(expr.X, "cannot infer type parameter '%s' of '%s'", gen.typeParams[i].Literal, gen.sym.Name)
+			expr.T = ir.TBuiltinInvalid
+			return nil
+		}
+	}
+
+	if inst := c.instantiate(gen, targs, expr.X); inst != nil {
+		return c.instanceIdent(inst, expr.X)
+	}
+	expr.T = ir.TBuiltinInvalid
+	return nil
+}
+
+// Match the (unchecked) type expression of a parameter against the type of an argument.
+func unifyTypeArgs(gen *genericDecl, texpr ir.Expr, t ir.Type, targs []ir.Type) {
+	t = ir.ToBaseType(t)
+	switch texpr := texpr.(type) {
+	case *ir.Ident:
+		if i := gen.typeParamIndex(texpr.Literal); i >= 0 && targs[i] == nil {
+			targs[i] = t
+		}
+	case *ir.PointerTypeExpr:
+		switch t := t.(type) {
+		case *ir.PointerType:
+			if t.Raw == texpr.Raw && (texpr.Nullable || !t.Nullable) {
+				unifyTypeArgs(gen, texpr.X, t.Elem, targs)
+			}
+		case *ir.SliceType:
+			if t.Ptr && !texpr.Raw && !texpr.Nullable {
+				unifyTypeArgs(gen, texpr.X, t, targs)
+			}
+		}
+	case *ir.SliceTypeExpr:
+		if tslice, ok := t.(*ir.SliceType); ok {
+			unifyTypeArgs(gen, texpr.X, tslice.Elem, targs)
+		}
+	case *ir.ArrayTypeExpr:
+		if tarray, ok := t.(*ir.ArrayType); ok {
+			unifyTypeArgs(gen, texpr.X, tarray.Elem, targs)
+		}
+	case *ir.TupleTypeExpr:
+		if ttuple, ok := t.(*ir.TupleType); ok && len(ttuple.Elems) == len(texpr.Elems) {
+			for i, elem := range texpr.Elems {
+				unifyTypeArgs(gen, elem, ttuple.Elems[i], targs)
+			}
+		}
+	case *ir.ResultTypeExpr:
+		if tres, ok := t.(*ir.ResultType); ok {
+			unifyTypeArgs(gen, texpr.Value, tres.T, targs)
+			unifyTypeArgs(gen, texpr.Error, tres.E, targs)
+		}
+	case *ir.FuncTypeExpr:
+		if tfun := toFuncType(t); tfun != nil && len(tfun.Params) == len(texpr.Params) {
+			for i, param := range texpr.Params {
+				unifyTypeArgs(gen, param.Type, tfun.Params[i].T, targs)
+			}
+			unifyTypeArgs(gen, texpr.Return.Type, tfun.Return, targs)
+		}
+	case *ir.InstanceExpr:
+		if tstruct, ok := t.(*ir.StructType); ok && len(tstruct.Sym.TypeArgs) == len(texpr.Args) {
+			for i, arg := range texpr.Args {
+				unifyTypeArgs(gen, arg, tstruct.Sym.TypeArgs[i], targs)
+			}
+		}
+	}
+}
+
+func (c *checker) instanceIdent(inst *ir.Symbol, node ir.Node) *ir.Ident {
+	ident := ir.NewIdent2(token.Ident, inst.Name)
+	ident.SetRange(node.Pos(), node.EndPos())
+	ident.Sym = inst
+	c.trySetDep(inst, true)
+	ident.T = inst.T
+	return ident
+}
+
+// Returns the instance of the generic declaration with the type arguments.
+// The instance is created and checked if it doesn't already exist.
+func (c *checker) instantiate(gen *genericDecl, targs []ir.Type, node ir.Node) *ir.Symbol {
+	for i, targ := range targs {
+		targs[i] = ir.ToBaseType(targ)
+		if isTypeOneOf(targs[i], ir.TVoid, ir.TModule, ir.TGeneric) {
+			c.nodeError(node, "type '%s' cannot be used as a type argument", targs[i])
+			return nil
+		}
+	}
+
+	key := typeArgsString(targs)
+	if inst, ok := gen.instances[key]; ok {
+		if c.step > 0 {
+			c.checkNestedObjects(inst.objects, c.step)
+		}
+		return inst.sym
+	}
+
+	if c.instanceDepth >= maxInstanceDepth {
+		c.nodeError(node, "instantiation of '%s' exceeds the maximum depth of %d", gen.sym.Name, maxInstanceDepth)
+		return nil
+	}
+
+	name := fmt.Sprintf("%s[%s]", gen.sym.Name, key)
+	decl := ir.CloneDecl(gen.decl.D)
+	switch decl := decl.(type) {
+	case *ir.FuncDecl:
+		decl.Name.Literal = name
+		decl.TypeParams = nil
+	case *ir.StructDecl:
+		decl.Name.Literal = name
+		decl.TypeParams = nil
+	}
+
+	// The type parameters are bound in a scope between the module scope and the instance
+	scope := ir.NewScope("type_args", gen.scope, gen.sym.CUID)
+	for i, param := range gen.typeParams {
+		c.insertSymbol(scope, param.Literal, c.typeArgSymbol(gen, param, targs[i]))
+	}
+
+	prevScope := c.setScope(scope)
+	objects := c.createObjects(ir.NewTopDecl(gen.decl.ABI, gen.decl.Visibility, gen.decl.Attributes, decl), gen.sym.CUID, gen.sym.ModFQN)
+	c.setScope(prevScope)
+
+	inst := decl.Symbol()
+	if inst == nil {
+		return nil
+	}
+
+	inst.Generic = gen.sym
+	inst.TypeArgs = targs
+	gen.instances[key] = &genericInstance{sym: inst, objects: objects}
+
+	objList := c.objectMatrix[gen.sym.CUID]
+	for _, obj := range objects {
+		if method, ok := obj.d.(*ir.FuncDecl); ok && method.Sym.IsMethod() {
+			method.Sym.Name = "dg." + gen.sym.Name + "." + method.Name.Literal
+			method.Sym.TypeArgs = targs
+		}
+		objList.objects = append(objList.objects, obj)
+		if obj.definition {
+			c.objectMap[obj.uniqKey()] = obj
+		} else if _, ok := c.objectMap[obj.uniqKey()]; !ok {
+			c.objectMap[obj.uniqKey()] = obj
+		}
+	}
+
+	// The objects of a new instance have never been checked, so they start
+	// from step 0 regardless of the current step
+	c.instanceDepth++
+	c.checkNestedObjects(objects, 0)
+	if c.step > 0 {
+		c.checkNestedObjects(objects, c.step)
+	}
+	c.instanceDepth--
+	return inst
+}
+
+// Named types are bound to a copy of their symbol so that the instance gets the
+// same dependencies as if the type was used by name. The copy is public since the
+// type argument may be private to the compilation unit of the instantiation.
+func (c *checker) typeArgSymbol(gen *genericDecl, param *ir.Ident, targ ir.Type) *ir.Symbol {
+	var named *ir.Symbol
+	switch t := targ.(type) {
+	case *ir.StructType:
+		named = t.Sym
+	case *ir.UnionType:
+		named = t.Sym
+	case *ir.EnumType:
+		named = t.Sym
+	}
+	if named != nil {
+		sym := *named
+		sym.Public = true
+		return &sym
+	}
+	key := c.nextSymKey()
+	sym := ir.NewSymbol(ir.TypeSymbol, key, gen.sym.CUID, gen.sym.ModFQN, param.Literal, param.Pos())
+	sym.Flags = ir.SymFlagDefined
+	sym.Public = true
+	sym.T = targ
+	return sym
+}
+
+// checkNestedObjects checks objects which are created while another object is being checked.
+func (c *checker) checkNestedObjects(objects []*object, step int) {
+	prevObjectList := c.objectList
+	prevObject := c.object
+	prevScope := c.scope
+	prevMode := c.mode
+	prevStep := c.step
+	prevLoops := c.loops
+	prevDeferred := c.deferred
+	prevBlockExpr := c.blockExpr
+	prevOperand := c.operand
+
+	c.mode = modeExpr
+	c.step = step
+	c.loops = nil
+	c.deferred = false
+	c.blockExpr = false
+	c.operand = nil
+
+	for _, obj := range objects {
+		if step == 0 {
+			c.objectList = c.objectMatrix[obj.CUID()]
+			c.checkObject(obj)
+		} else if obj.incomplete {
+			c.checkIncompleteObject(obj)
+		}
+	}
+
+	c.objectList = prevObjectList
+	c.object = prevObject
+	c.scope = prevScope
+	c.mode = prevMode
+	c.step = prevStep
+	c.loops = prevLoops
+	c.deferred = prevDeferred
+	c.blockExpr = prevBlockExpr
+	c.operand = prevOperand
+}
+
+func (c *checker) insertGenericSymbol(decl *ir.TopDecl, CUID int, modFQN string, abi string, public bool) {
+	var name *ir.Ident
+	var sym *ir.Symbol
+	typeParams := genericTypeParams(decl.D)
+
+	switch d := decl.D.(type) {
+	case *ir.FuncDecl:
+		name = d.Name
+		if d.SignatureOnly() {
+			c.nodeError(name, "generic function '%s' must have a body", name.Literal)
+			return
+		}
+		sym = c.newTopDeclSymbol(ir.FuncSymbol, CUID, modFQN, abi, public, name.Literal, name.Pos(), true)
+	case *ir.StructDecl:
+		name = d.Name
+		if d.Opaque {
+			c.nodeError(name, "generic struct '%s' cannot be opaque", name.Literal)
+			return
+		}
+		sym = c.newTopDeclSymbol(ir.TypeSymbol, CUID, modFQN, abi, public, name.Literal, name.Pos(), true)
+	}
+
+	if abi != ir.DGABI {
+		c.nodeError(name, "generic '%s' cannot have abi '%s'", name.Literal, abi)
+		return
+	}
+
+	var names []string
+	for i, param := range typeParams {
+		for _, prev := range typeParams[:i] {
+			if prev.Literal == param.Literal {
+				c.nodeError(param, "duplicate type parameter '%s'", param.Literal)
+				return
+			}
+		}
+		names = append(names, param.Literal)
+	}
+
+	sym.T = ir.NewGenericType(sym, names)
+	sym = c.insertSymbol(c.scope, sym.Name, sym)
+	if sym == nil {
+		return
+	}
+
+	name.Sym = sym
+	c.generics[sym.Key] = &genericDecl{
+		sym:        sym,
+		decl:       decl,
+		scope:      c.scope,
+		typeParams: typeParams,
+		instances:  make(map[string]*genericInstance),
+	}
+}
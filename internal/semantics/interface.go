@@ -0,0 +1,200 @@
+package semantics
+
+import (
+	"fmt"
+
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// An interface value is a reference to a struct together with a table of the methods
+// that implement the interface. Conformance is structural: a reference to any struct
+// which has methods with the same names and signatures can be used as the interface.
+//
+// The reference is converted when it's used where the interface is expected. Since the
+// methods of the struct may not have been checked yet, conformance is verified after
+// all objects have been checked.
+
+// interfaceConversion is a reference to a struct which is converted to an interface.
+type interfaceConversion struct {
+	obj    *object
+	expr   ir.Expr
+	tptr   *ir.PointerType
+	tiface *ir.InterfaceType
+}
+
+func (c *checker) checkInterfaceDecl(decl *ir.InterfaceDecl) {
+	tiface, ok := decl.Sym.T.(*ir.InterfaceType)
+	if !ok || tiface.TypedBody {
+		return
+	}
+	var tuntyped ir.Type
+	for _, method := range decl.Methods {
+		for i, param := range method.Params {
+			c.setLocalAttributes(param, attrTargetParam, "a parameter")
+			if i == 0 {
+				if _, ok := selfParam(param); ok {
+					continue
+				}
+			}
+			param.Type = c.checkRootTypeExpr(param.Type, true)
+			tuntyped = checkUntyped(param.Type.Type(), tuntyped)
+		}
+		method.Return.Type = c.checkRootTypeExpr(method.Return.Type, false)
+		tuntyped = checkUntyped(method.Return.Type.Type(), tuntyped)
+	}
+	if tuntyped != nil {
+		if isInvalidType(tuntyped) {
+			decl.Sym.T = tuntyped
+		}
+		return
+	}
+	invalid := false
+	names := make(map[string]bool)
+	var methods []ir.InterfaceMethod
+	for _, method := range decl.Methods {
+		name := method.Name.Literal
+		if names[name] {
+			c.nodeError(method.Name, "duplicate method '%s' in interface '%s'", name, decl.Name.Literal)
+			invalid = true
+			continue
+		}
+		names[name] = true
+		if len(method.TypeParams) > 0 {
+			c.nodeError(method.Name, "methods cannot have type parameters")
+			invalid = true
+		}
+		if method.Body != nil {
+			c.nodeError(method.Name, "interface method '%s' cannot have a body", name)
+			invalid = true
+		}
+		if method.Variadic {
+			c.nodeError(method.Name, "interface method '%s' cannot be variadic", name)
+			invalid = true
+		}
+		ro := false
+		if len(method.Params) > 0 {
+			ro, ok = selfParam(method.Params[0])
+		} else {
+			ok = false
+		}
+		if !ok {
+			c.nodeError(method.Name, "interface method '%s' must take '&%s' or '&var %s' as the first parameter", name, ir.SelfType, ir.SelfType)
+			invalid = true
+			continue
+		}
+		var params []ir.Field
+		for _, param := range method.Params[1:] {
+			if param.Initializer != nil {
+				c.nodeError(param.Initializer, "parameter of interface method '%s' cannot have a default value", name)
+				invalid = true
+			}
+			params = append(params, ir.Field{Name: param.Name.Literal, T: param.Type.Type()})
+		}
+		tfun := ir.NewFuncType(params, false, method.Return.Type.Type(), false)
+		methods = append(methods, ir.InterfaceMethod{Name: name, ReadOnly: ro, T: tfun})
+	}
+	if invalid {
+		decl.Sym.T = ir.TBuiltinInvalid
+		return
+	}
+	tiface.SetBody(methods, true)
+}
+
+// selfParam returns whether the parameter is a read-only reference,
+// and true if the parameter is '&Self' or '&var Self'.
+func selfParam(param *ir.ValDecl) (bool, bool) {
+	if param.Name.Tok != token.Placeholder {
+		return false, false
+	}
+	tptr, ok := param.Type.(*ir.PointerTypeExpr)
+	if !ok || tptr.Raw || tptr.Nullable {
+		return false, false
+	}
+	if ident, ok := tptr.X.(*ir.Ident); !ok || ident.Literal != ir.SelfType {
+		return false, false
+	}
+	return tptr.Decl.Is(token.Val), true
+}
+
+// tryInterfaceCast converts a reference to a struct to an interface.
+func (c *checker) tryInterfaceCast(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
+	tiface, ok := ir.ToBaseType(target).(*ir.InterfaceType)
+	if !ok {
+		return expr, false
+	}
+	tptr, ok := ir.ToBaseType(expr.Type()).(*ir.PointerType)
+	if !ok || tptr.Raw || tptr.Nullable || ir.ToBaseType(tptr.Elem).Kind() != ir.TStruct {
+		return expr, false
+	}
+	key := fmt.Sprintf("%s %s %s", expr.Pos(), tptr.Elem, tiface)
+	if !c.converted[key] {
+		c.converted[key] = true
+		c.conversions = append(c.conversions, &interfaceConversion{obj: c.object, expr: expr, tptr: tptr, tiface: tiface})
+	}
+	cast := &ir.CastExpr{X: expr}
+	cast.SetRange(expr.Pos(), expr.EndPos())
+	cast.T = target
+	return cast, true
+}
+
+func (c *checker) checkInterfaceConversions() {
+	for _, conv := range c.conversions {
+		if !conv.tiface.TypedBody {
+			continue
+		}
+		tstruct := ir.ToBaseType(conv.tptr.Elem).(*ir.StructType)
+		for _, method := range conv.tiface.Methods {
+			sym := tstruct.Scope().Lookup(method.Name)
+			if sym == nil || !sym.IsMethod() {
+				c.nodeError(conv.expr, "type '%s' does not implement '%s' (missing method '%s')", tstruct, conv.tiface, method.Name)
+				break
+			}
+			if obj, ok := c.objectMap[sym.UniqKey]; ok {
+				// The method is referenced by the method table
+				conv.obj.setDep(obj, true)
+			}
+			if isUntyped(sym.T) {
+				break
+			}
+			tfun := ir.ToBaseType(sym.T).(*ir.FuncType)
+			if !implementsMethod(tfun, tstruct, method) {
+				var tself ir.Type = ir.NewPointerType(tstruct, method.ReadOnly)
+				if len(tfun.Params) > 0 {
+					if tptr, ok := tfun.Params[0].T.(*ir.PointerType); ok && tptr.Elem.Equals(tstruct) {
+						tself = ir.NewPointerType(tptr.Elem, method.ReadOnly)
+					}
+				}
+				params := append([]ir.Field{{Name: ir.Self, T: tself}}, method.T.Params...)
+				texpected := ir.NewFuncType(params, false, method.T.Return, false)
+				c.nodeError(conv.expr, "type '%s' does not implement '%s' (method '%s' has type '%s', expected '%s')",
+					tstruct, conv.tiface, method.Name, tfun, texpected)
+				break
+			}
+			if conv.tptr.ReadOnly && !method.ReadOnly {
+				c.nodeError(conv.expr, "read-only reference cannot be used as '%s' (method '%s' takes '&var %s')", conv.tiface, method.Name, ir.SelfType)
+				break
+			}
+		}
+	}
+}
+
+// A method which takes '&Self' implements an interface method which takes '&var Self', but not the other way around.
+func implementsMethod(tfun *ir.FuncType, tstruct *ir.StructType, method ir.InterfaceMethod) bool {
+	if tfun.C || tfun.Variadic || len(tfun.Params) != len(method.T.Params)+1 {
+		return false
+	}
+	tself, ok := ir.ToBaseType(tfun.Params[0].T).(*ir.PointerType)
+	if !ok || tself.Raw || tself.Nullable || !tself.Elem.Equals(tstruct) {
+		return false
+	}
+	if method.ReadOnly && !tself.ReadOnly {
+		return false
+	}
+	for i, param := range method.T.Params {
+		if !param.T.Equals(tfun.Params[i+1].T) {
+			return false
+		}
+	}
+	return tfun.Return.Equals(method.T.Return)
+}
@@ -0,0 +1,215 @@
+package semantics
+
+import (
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// Narrowing of nullable references and results.
+//
+// A local variable of type ?&T is narrowed to &T in code that is only reachable
+// when the variable has been compared against null. Likewise, the value of a local
+// result can only be read in code that is only reachable when its ok field is true.
+// The narrowing is keyed by the variable name and is only applied if the identifier
+// resolves to the same symbol. Any assignment to the variable removes the narrowing.
+// A variable whose mutable address is taken anywhere in the function is never narrowed,
+// since it can be modified through the reference.
+
+func isNarrowableSymbol(sym *ir.Symbol) bool {
+	if sym == nil || sym.Kind != ir.ValSymbol || sym.IsTopDecl() || sym.IsField() {
+		return false
+	}
+	return ir.IsNullablePointerType(sym.T) || sym.T.Kind() == ir.TResult
+}
+
+func narrowedType(t ir.Type) ir.Type {
+	tptr := ir.ToBaseType(t).(*ir.PointerType)
+	return ir.NewPointerType(tptr.Elem, tptr.ReadOnly)
+}
+
+func isNullLit(expr ir.Expr) bool {
+	if lit, ok := expr.(*ir.BasicLit); ok {
+		return lit.Tok == token.Null
+	}
+	return false
+}
+
+// Returns the symbols that are known to be non-null, or to be ok results, if cond evaluates to value.
+func narrowedSymbols(cond ir.Expr, value bool) []*ir.Symbol {
+	switch cond := cond.(type) {
+	case *ir.DotExpr:
+		if ident, ok := cond.X.(*ir.Ident); ok && value && cond.Name.Literal == ir.ResultOk {
+			if isNarrowableSymbol(ident.Sym) && ident.Sym.T.Kind() == ir.TResult {
+				return []*ir.Symbol{ident.Sym}
+			}
+		}
+	case *ir.BinaryExpr:
+		switch cond.Op {
+		case token.Eq, token.Neq:
+			if (cond.Op == token.Neq) != value {
+				return nil
+			}
+			x := cond.Left
+			if isNullLit(x) {
+				x = cond.Right
+			} else if !isNullLit(cond.Right) {
+				return nil
+			}
+			if ident, ok := x.(*ir.Ident); ok && isNarrowableSymbol(ident.Sym) {
+				return []*ir.Symbol{ident.Sym}
+			}
+		case token.Land:
+			if value {
+				return append(narrowedSymbols(cond.Left, true), narrowedSymbols(cond.Right, true)...)
+			}
+		case token.Lor:
+			if !value {
+				return append(narrowedSymbols(cond.Left, false), narrowedSymbols(cond.Right, false)...)
+			}
+		}
+	case *ir.UnaryExpr:
+		if cond.Op == token.Lnot {
+			return narrowedSymbols(cond.X, !value)
+		}
+	}
+	return nil
+}
+
+// Narrows syms and returns the names that were not already narrowed.
+func (c *checker) narrow(syms []*ir.Symbol) []string {
+	var added []string
+	if c.narrowed == nil {
+		return added
+	}
+	for _, sym := range syms {
+		if c.addressed[sym.Name] {
+			continue
+		}
+		if prev, ok := c.narrowed[sym.Name]; !ok || prev != sym {
+			c.narrowed[sym.Name] = sym
+			added = append(added, sym.Name)
+		}
+	}
+	return added
+}
+
+// addrTakenNames returns the names of the variables whose mutable address is taken in the
+// body of decl, including function literals in the body. The names are collected before
+// the body is checked, since checking adds implicit address expressions.
+func (c *checker) addrTakenNames(decl *ir.FuncDecl) map[string]bool {
+	if names, ok := c.addrTaken[decl.Sym.UniqKey]; ok {
+		return names
+	}
+	names := make(map[string]bool)
+	ir.Walk(decl.Body, func(node ir.Node) bool {
+		if addr, ok := node.(*ir.AddrExpr); ok && !addr.Immutable {
+			if ident, ok := addr.X.(*ir.Ident); ok {
+				names[ident.Literal] = true
+			}
+		}
+		return true
+	})
+	c.addrTaken[decl.Sym.UniqKey] = names
+	return names
+}
+
+func (c *checker) unnarrow(names []string) {
+	for _, name := range names {
+		delete(c.narrowed, name)
+	}
+}
+
+func (c *checker) unnarrowAssigned(expr ir.Expr) {
+	if ident, ok := expr.(*ir.Ident); ok {
+		delete(c.narrowed, ident.Literal)
+	}
+}
+
+func (c *checker) saveNarrowed() map[string]*ir.Symbol {
+	saved := make(map[string]*ir.Symbol, len(c.narrowed))
+	for name, sym := range c.narrowed {
+		saved[name] = sym
+	}
+	return saved
+}
+
+// Removes narrowing that was added after saved was created.
+func (c *checker) restoreNarrowed(saved map[string]*ir.Symbol) {
+	for name, sym := range c.narrowed {
+		if prev, ok := saved[name]; !ok || prev != sym {
+			delete(c.narrowed, name)
+		}
+	}
+}
+
+func (c *checker) narrowIdent(expr *ir.Ident) {
+	if c.isNarrowed(expr) && ir.IsNullablePointerType(expr.Sym.T) {
+		expr.T = narrowedType(expr.Sym.T)
+	}
+}
+
+func (c *checker) isNarrowed(expr ir.Expr) bool {
+	if ident, ok := expr.(*ir.Ident); ok {
+		sym, ok := c.narrowed[ident.Literal]
+		return ok && sym == ident.Sym && isNarrowableSymbol(sym)
+	}
+	return false
+}
+
+// Returns true if control never reaches the end of the block.
+func isTerminatingBlock(block *ir.BlockStmt) bool {
+	if len(block.Stmts) == 0 {
+		return false
+	}
+	switch stmt := block.Stmts[len(block.Stmts)-1].(type) {
+	case *ir.ReturnStmt, *ir.BranchStmt, *ir.PanicStmt:
+		return true
+	case *ir.ExprStmt:
+		return isNoReturnCall(stmt.X)
+	}
+	return false
+}
+
+// Returns true if expr calls a function with the noreturn attribute.
+func isNoReturnCall(expr ir.Expr) bool {
+	if app, ok := expr.(*ir.AppExpr); ok {
+		if sym := ir.ExprSymbol(app.X); sym != nil && sym.Kind == ir.FuncSymbol {
+			return sym.Attribute(ir.AttrNoReturn) != nil
+		}
+	}
+	return false
+}
+
+// Returns the names of all variables that are assigned in stmt.
+func assignedNames(stmt ir.Stmt, names []string) []string {
+	switch stmt := stmt.(type) {
+	case *ir.BlockStmt:
+		for _, s := range stmt.Stmts {
+			names = assignedNames(s, names)
+		}
+	case *ir.IfStmt:
+		names = assignedNames(stmt.Body, names)
+		if stmt.Else != nil {
+			names = assignedNames(stmt.Else, names)
+		}
+	case *ir.MatchStmt:
+		for _, arm := range stmt.Arms {
+			names = assignedNames(arm.Body, names)
+		}
+		if stmt.Else != nil {
+			names = assignedNames(stmt.Else, names)
+		}
+	case *ir.ForStmt:
+		if stmt.Inc != nil {
+			names = assignedNames(stmt.Inc, names)
+		}
+		names = assignedNames(stmt.Body, names)
+	case *ir.DeferStmt:
+		names = assignedNames(stmt.S, names)
+	case *ir.AssignStmt:
+		if ident, ok := stmt.Left.(*ir.Ident); ok {
+			names = append(names, ident.Literal)
+		}
+	}
+	return names
+}
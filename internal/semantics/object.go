@@ -31,6 +31,7 @@ type object struct {
 	checked     bool
 	incomplete  bool
 	color       color
+	funcLit     *funcLit // Non-nil if the object is a function literal
 }
 
 type objectDep struct {
@@ -150,6 +151,13 @@ func (c *checker) createObjects(decl *ir.TopDecl, CUID int, modFQN string) []*ob
 		}
 	}
 	public := decl.Visibility.Is(token.Public)
+	decl.Attributes = c.checkAttributes(decl.Attributes, declAttributeTarget(decl.D), declDescription(decl.D))
+	attributes := decl.Attributes
+	if len(genericTypeParams(decl.D)) > 0 {
+		c.checkGenericAttributes(decl.D)
+		c.insertGenericSymbol(decl, CUID, modFQN, abi, public)
+		return nil
+	}
 	var objects []*object
 	switch decl := decl.D.(type) {
 	case *ir.ImportDecl:
@@ -196,6 +204,7 @@ func (c *checker) createObjects(decl *ir.TopDecl, CUID int, modFQN string) []*ob
 			if decl.Opaque {
 				if decl.Sym.T.Kind() == ir.TUnknown {
 					tstruct := ir.NewStructType(decl.Sym, decl.Scope)
+					tstruct.CUnion = decl.CUnion
 					tstruct.SetBody(nil, true)
 					decl.Sym.T = tstruct
 					objects = append(objects, newObject(decl, c.scope, def))
@@ -210,6 +219,7 @@ func (c *checker) createObjects(decl *ir.TopDecl, CUID int, modFQN string) []*ob
 				selfType.Sym = c.newTopDeclSymbol(ir.TypeSymbol, CUID, modFQN, abi, false, selfType.Name.Literal, token.NoPosition, true)
 				c.insertSymbol(methodScope, selfType.Name.Literal, selfType.Sym)
 				c.insertStructDeclBody(decl, methodScope)
+				setStructLayout(decl.Sym.T.(*ir.StructType), attributes)
 				objects = append(objects, newObject(decl, c.scope, def))
 				objects = append(objects, newObject(selfType, methodScope, true))
 				fieldScope := decl.Scope.Fresh("struct_fields", c.scope)
@@ -226,9 +236,40 @@ func (c *checker) createObjects(decl *ir.TopDecl, CUID int, modFQN string) []*ob
 				decl.Methods = nil
 			}
 		}
+	case *ir.UnionDecl:
+		sym := c.newTopDeclSymbol(ir.TypeSymbol, CUID, modFQN, abi, public, decl.Name.Literal, decl.Name.Pos(), true)
+		decl.Sym = c.insertSymbol(c.scope, sym.Name, sym)
+		if decl.Sym != nil {
+			decl.Scope = ir.NewScope("union_variants", nil, sym.CUID)
+			c.insertUnionDeclBody(decl)
+			objects = append(objects, newObject(decl, c.scope, true))
+		}
+	case *ir.EnumDecl:
+		sym := c.newTopDeclSymbol(ir.TypeSymbol, CUID, modFQN, abi, public, decl.Name.Literal, decl.Name.Pos(), true)
+		decl.Sym = c.insertSymbol(c.scope, sym.Name, sym)
+		if decl.Sym != nil {
+			decl.Scope = ir.NewScope("enum_members", nil, sym.CUID)
+			c.insertEnumDeclBody(decl)
+			objects = append(objects, newObject(decl, c.scope, true))
+		}
+	case *ir.InterfaceDecl:
+		sym := c.newTopDeclSymbol(ir.TypeSymbol, CUID, modFQN, abi, public, decl.Name.Literal, decl.Name.Pos(), true)
+		decl.Sym = c.insertSymbol(c.scope, sym.Name, sym)
+		if decl.Sym != nil {
+			decl.Sym.T = ir.NewInterfaceType(decl.Sym)
+			decl.Name.Sym = decl.Sym
+			objects = append(objects, newObject(decl, c.scope, true))
+		}
+	case *ir.StaticAssertDecl:
+		// The symbol is not inserted in any scope and only identifies the object
+		decl.Sym = c.newTopDeclSymbol(ir.ValSymbol, CUID, modFQN, abi, false, token.StaticAssert.String(), decl.Pos(), true)
+		objects = append(objects, newObject(decl, c.scope, true))
 	default:
 		panic(fmt.Sprintf("Unhandled decl %T", decl))
 	}
+	if sym := decl.D.Symbol(); sym != nil {
+		sym.Attributes = append(sym.Attributes, attributes...)
+	}
 	return objects
 }
 
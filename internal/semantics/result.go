@@ -0,0 +1,94 @@
+package semantics
+
+import (
+	"github.com/cjo5/dingo/internal/ir"
+	"github.com/cjo5/dingo/internal/token"
+)
+
+// A result is either a value or an error. Values and errors are implicitly converted
+// to a result where one is expected, and 'try' unwraps the value or returns the error
+// from the enclosing function. A result which is not used must be handled, and its
+// value can only be read after checking that it's ok, so an error cannot be silently
+// ignored.
+
+// tryResultCast converts a value or an error to a result. The value type is tried first,
+// and a constant which doesn't fit it is reported instead of being converted to the error.
+func (c *checker) tryResultCast(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
+	tres, ok := ir.ToBaseType(target).(*ir.ResultType)
+	if !ok || expr.Type().Kind() == ir.TResult {
+		return expr, false
+	}
+	for _, t := range []ir.Type{tres.T, tres.E} {
+		res := ensureCompatibleType(expr, t)
+		if res.Type().Equals(t) {
+			if isConstValueType(t) {
+				res = c.foldConstExpr(res)
+				if isInvalidType(res.Type()) {
+					return res, true
+				}
+			}
+			cast := &ir.CastExpr{X: res}
+			cast.SetRange(expr.Pos(), expr.EndPos())
+			cast.T = target
+			return cast, true
+		}
+	}
+	return expr, false
+}
+
+func (c *checker) checkTryExpr(expr *ir.TryExpr) ir.Expr {
+	expr.X = c.checkExpr(expr.X)
+	if tuntyped := checkUntypedExprs(expr.X); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+	expr.X = c.finalizeExpr(expr.X, nil)
+	tx := expr.X.Type()
+	tres, ok := ir.ToBaseType(tx).(*ir.ResultType)
+	if !ok {
+		c.nodeError(expr.X, "try expects a result type (got '%s')", tx)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+	if c.deferred {
+		c.nodeError(expr, "try cannot be used in a deferred statement")
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+	if c.blockExpr {
+		c.nodeError(expr, "'%s' cannot be used in a block expression", token.Try)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+	tret := c.funcReturnType()
+	if tret == nil {
+		tret = ir.TBuiltinVoid
+	} else if isUntyped(tret) {
+		expr.T = tret
+		return expr
+	}
+	tfunres, ok := ir.ToBaseType(tret).(*ir.ResultType)
+	if !ok {
+		c.nodeError(expr, "try can only be used in a function that returns a result type")
+		expr.T = ir.TBuiltinInvalid
+	} else if !tres.E.Equals(tfunres.E) {
+		c.nodeError(expr, "error type '%s' does not match the return type '%s'", tres.E, tret)
+		expr.T = ir.TBuiltinInvalid
+	} else {
+		expr.T = tres.T
+	}
+	return expr
+}
+
+// resultFieldType returns the type of the field, or nil if the result has no such field.
+func resultFieldType(tres *ir.ResultType, name string) ir.Type {
+	switch name {
+	case ir.ResultOk:
+		return ir.TBuiltinBool
+	case ir.ResultValue:
+		return tres.T
+	case ir.ResultError:
+		return tres.E
+	}
+	return nil
+}
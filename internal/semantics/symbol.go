@@ -184,11 +184,15 @@ func (c *checker) insertStructDeclBody(decl *ir.StructDecl, methodScope *ir.Scop
 	var fields []ir.Field
 	for _, field := range decl.Fields {
 		c.insertLocalValDeclSymbol(field, sym.CUID, sym.ModFQN)
+		c.setLocalAttributes(field, attrTargetField, "a field")
 		if field.Sym != nil {
 			fields = append(fields, ir.Field{Name: field.Name.Literal, T: ir.TBuiltinUnknown})
 		}
 	}
 	for _, method := range decl.Methods {
+		if len(method.TypeParams) > 0 {
+			c.nodeError(method.Name, "methods cannot have type parameters")
+		}
 		c.patchSelf(method, sym)
 		pubField := (method.Flags & ir.AstFlagPublic) != 0
 		name := "dg." + sym.Name + "." + method.Name.Literal
@@ -196,7 +200,9 @@ func (c *checker) insertStructDeclBody(decl *ir.StructDecl, methodScope *ir.Scop
 		sym = c.insertSymbol(c.scope, method.Name.Literal, sym)
 		method.Sym = sym
 		method.Name.Sym = sym
+		method.Attributes = c.checkAttributes(method.Attributes, attrTargetFunc, "a method")
 		if sym != nil {
+			sym.Attributes = method.Attributes
 			sym.Flags |= ir.SymFlagMethod
 			method.Scope = ir.NewScope("method", methodScope, sym.CUID)
 			if method.Body != nil {
@@ -208,11 +214,52 @@ func (c *checker) insertStructDeclBody(decl *ir.StructDecl, methodScope *ir.Scop
 		}
 	}
 	tstruct := ir.NewStructType(decl.Sym, decl.Scope)
+	tstruct.CUnion = decl.CUnion
 	tstruct.SetBody(fields, false) // Set untyped fields
 	decl.Sym.T = tstruct
 	decl.Name.Sym = decl.Sym
 }
 
+func (c *checker) insertUnionDeclBody(decl *ir.UnionDecl) {
+	sym := decl.Sym
+	defer c.setScope(c.setScope(decl.Scope))
+	var variants []ir.Variant
+	for _, variant := range decl.Variants {
+		key := c.nextSymKey()
+		variantSym := ir.NewSymbol(ir.ValSymbol, key, sym.CUID, sym.ModFQN, variant.Name.Literal, variant.Name.Pos())
+		variantSym.Public = sym.Public
+		variantSym.Flags = ir.SymFlagDefined | ir.SymFlagReadOnly | ir.SymFlagConst
+		variant.Sym = c.insertSymbol(c.scope, variantSym.Name, variantSym)
+		variant.Name.Sym = variant.Sym
+		if variant.Sym != nil {
+			var fields []ir.Field
+			for _, field := range variant.Fields {
+				fields = append(fields, ir.Field{Name: field.Name.Literal, T: ir.TBuiltinUnknown})
+			}
+			variants = append(variants, ir.Variant{Name: variant.Sym.Name, Tag: len(variants), Sym: variant.Sym, Fields: fields})
+		}
+	}
+	tunion := ir.NewUnionType(decl.Sym, decl.Scope)
+	tunion.SetBody(variants, false) // Set untyped fields
+	decl.Sym.T = tunion
+	decl.Name.Sym = decl.Sym
+}
+
+func (c *checker) insertEnumDeclBody(decl *ir.EnumDecl) {
+	sym := decl.Sym
+	defer c.setScope(c.setScope(decl.Scope))
+	for _, member := range decl.Members {
+		key := c.nextSymKey()
+		memberSym := ir.NewSymbol(ir.ValSymbol, key, sym.CUID, sym.ModFQN, member.Name.Literal, member.Name.Pos())
+		memberSym.Public = sym.Public
+		memberSym.Flags = ir.SymFlagDefined | ir.SymFlagReadOnly | ir.SymFlagConst
+		member.Sym = c.insertSymbol(c.scope, memberSym.Name, memberSym)
+		member.Name.Sym = member.Sym
+	}
+	decl.Sym.T = ir.NewEnumType(decl.Sym, decl.Scope) // Backing type and values are set when checked
+	decl.Name.Sym = decl.Sym
+}
+
 func (c *checker) patchSelf(decl *ir.FuncDecl, structSym *ir.Symbol) {
 	if len(decl.Params) == 0 {
 		return
@@ -222,7 +269,7 @@ func (c *checker) patchSelf(decl *ir.FuncDecl, structSym *ir.Symbol) {
 		return
 	}
 	typeExpr := param.Type
-	if ty2, ok := param.Type.(*ir.PointerTypeExpr); ok {
+	if ty2, ok := param.Type.(*ir.PointerTypeExpr); ok && !ty2.Raw && !ty2.Nullable {
 		typeExpr = ty2.X
 	}
 	modFQN := structSym.ModFQN
@@ -244,3 +291,29 @@ func (c *checker) patchSelf(decl *ir.FuncDecl, structSym *ir.Symbol) {
 		}
 	}
 }
+
+func (c *checker) insertMatchBindings(arm *ir.MatchArm) {
+	for _, pattern := range arm.Patterns {
+		app, ok := pattern.(*ir.AppExpr)
+		if !ok {
+			continue
+		}
+		if len(arm.Patterns) > 1 {
+			c.nodeError(app, "payload bindings are not allowed in an arm with multiple patterns")
+			continue
+		}
+		for _, arg := range app.Args {
+			ident, ok := arg.Value.(*ir.Ident)
+			if !ok {
+				c.nodeError(arg.Value, "payload binding must be an identifier")
+				continue
+			} else if ident.Tok.Is(token.Placeholder) {
+				continue
+			}
+			key := c.nextSymKey()
+			sym := ir.NewSymbol(ir.ValSymbol, key, c.object.CUID(), c.object.modFQN(), ident.Literal, ident.Pos())
+			sym.Flags = ir.SymFlagDefined | ir.SymFlagReadOnly
+			ident.Sym = c.insertSymbol(c.scope, sym.Name, sym)
+		}
+	}
+}
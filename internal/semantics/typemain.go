@@ -1,7 +1,9 @@
 package semantics
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
 
 	"github.com/cjo5/dingo/internal/ir"
 	"github.com/cjo5/dingo/internal/token"
@@ -16,10 +18,14 @@ func (c *checker) checkTypes() {
 		}
 	}
 	c.step++
-	keys := c.sortedIncompleteKeys()
-	for _, key := range keys {
-		decl := c.incomplete[key]
-		c.checkIncompleteObject(decl)
+	// Generic instances created in this step can add new incomplete objects
+	for count := 0; count < len(c.incomplete); {
+		count = len(c.incomplete)
+		keys := c.sortedIncompleteKeys()
+		for _, key := range keys {
+			decl := c.incomplete[key]
+			c.checkIncompleteObject(decl)
+		}
 	}
 }
 
@@ -104,11 +110,57 @@ func (c *checker) checkDecl(decl ir.Decl) {
 		c.checkFuncDecl(decl)
 	case *ir.StructDecl:
 		c.checkStructDecl(decl)
+	case *ir.UnionDecl:
+		c.checkUnionDecl(decl)
+	case *ir.EnumDecl:
+		c.checkEnumDecl(decl)
+	case *ir.InterfaceDecl:
+		c.checkInterfaceDecl(decl)
+	case *ir.StaticAssertDecl:
+		if isUnknownType(sym.T) {
+			c.checkStaticAssert(decl)
+			if !isUnknownExprType(decl.Cond) {
+				sym.T = ir.TBuiltinVoid
+			}
+		}
 	default:
 		panic(fmt.Sprintf("Unhandled decl %T", decl))
 	}
 }
 
+// checkStaticAssert reports an error if the condition is not a constant expression, or if it's false.
+// The condition is left with an unknown type if it depends on declarations that are not yet checked.
+func (c *checker) checkStaticAssert(decl *ir.StaticAssertDecl) {
+	decl.Cond = c.checkExpr(decl.Cond)
+	if decl.Msg != nil {
+		c.checkBasicLit(decl.Msg)
+	}
+	if isUntypedExpr(decl.Cond) {
+		return
+	}
+	decl.Cond = c.finalizeExpr(decl.Cond, ir.TBuiltinBool)
+	if isTypeMismatch(decl.Cond.Type(), ir.TBuiltinBool) {
+		c.nodeError(decl.Cond, "static assertion expects type '%s' (got '%s')", ir.TBool, decl.Cond.Type())
+		decl.Cond.SetType(ir.TBuiltinInvalid)
+		return
+	}
+	decl.Cond = c.foldConstExpr(decl.Cond)
+	if isInvalidType(decl.Cond.Type()) {
+		return
+	}
+	lit, ok := decl.Cond.(*ir.BasicLit)
+	if !ok {
+		c.nodeError(decl.Cond, "static assertion is not a constant expression")
+		decl.Cond.SetType(ir.TBuiltinInvalid)
+	} else if lit.Tok == token.False {
+		if decl.Msg != nil && !isInvalidType(decl.Msg.T) {
+			c.error(decl.Pos(), "static assertion failed: %s", decl.Msg.AsString())
+		} else {
+			c.error(decl.Pos(), "static assertion failed")
+		}
+	}
+}
+
 func (c *checker) checkUseDecl(decl *ir.UseDecl) {
 	c.resolveScopeLookup(decl.Name)
 	tuse := decl.Name.T
@@ -163,8 +215,11 @@ func (c *checker) checkValDecl(decl *ir.ValDecl) {
 		}
 		tinit := decl.Initializer.Type()
 		if tdecl.Equals(tinit) {
-			tval = tdecl
-		} else {
+			decl.Initializer = c.foldConstExpr(decl.Initializer)
+			if !isInvalidType(decl.Initializer.Type()) {
+				tval = tdecl
+			}
+		} else if !isInvalidType(tinit) {
 			c.nodeError(decl, "type mismatch '%s' and '%s'", tdecl, tinit)
 		}
 	} else {
@@ -172,6 +227,15 @@ func (c *checker) checkValDecl(decl *ir.ValDecl) {
 	}
 	if !isUntyped(tval) {
 		if decl.Initializer == nil {
+			if decl.DefaultInit() {
+				if isUntypedBody(tval) {
+					// Wait until it's known whether the type has a default value
+					decl.Sym.T = ir.TBuiltinUnknown
+					return
+				} else if !hasDefaultValue(tval) {
+					c.nodeError(decl.Name, "variable of type '%s' must be initialized", tval)
+				}
+			}
 			decl.Initializer = ir.NewDefaultInit(tval)
 		}
 		if decl.Decl.Is(token.Val) {
@@ -179,19 +243,35 @@ func (c *checker) checkValDecl(decl *ir.ValDecl) {
 		}
 		if checkCompileTimeConstant(decl.Initializer) {
 			decl.Sym.Flags |= ir.SymFlagConst
+			if lit, ok := decl.Initializer.(*ir.BasicLit); ok && decl.Decl.Is(token.Val) {
+				// References to the value can be evaluated in constant expressions
+				c.constVals[decl.Sym.Key] = lit
+			}
 		}
 	}
 	decl.Sym.T = tval
 }
 
+// defaultValue returns the initializer of a field or parameter, or nil if it doesn't have one.
+func defaultValue(decl *ir.ValDecl) ir.Expr {
+	if _, ok := decl.Initializer.(*ir.DefaultInit); ok {
+		return nil
+	}
+	return decl.Initializer
+}
+
 func checkCompileTimeConstant(expr ir.Expr) bool {
 	constant := true
 	switch t := expr.(type) {
 	case *ir.BasicLit:
 	case *ir.ConstExpr:
 	case *ir.DefaultInit:
+	case *ir.UnionLit:
+		constant = len(t.Args) == 0
 	case *ir.AppExpr:
-		if t.IsStruct {
+		if tstruct, ok := ir.ToBaseType(t.T).(*ir.StructType); ok && tstruct.CUnion {
+			constant = len(t.Args) == 0
+		} else if t.IsStruct {
 			for _, arg := range t.Args {
 				if !checkCompileTimeConstant(arg.Value) {
 					return false
@@ -206,10 +286,18 @@ func checkCompileTimeConstant(expr ir.Expr) bool {
 				return false
 			}
 		}
+	case *ir.TupleLit:
+		for _, elem := range t.Elems {
+			if !checkCompileTimeConstant(elem) {
+				return false
+			}
+		}
 	case *ir.Ident:
 		if t.Sym == nil || t.Sym.Kind != ir.FuncSymbol {
 			return false
 		}
+	case *ir.FuncLit:
+		constant = t.T.Kind() == ir.TFunc
 	default:
 		constant = false
 	}
@@ -222,6 +310,10 @@ func (c *checker) checkFuncDecl(decl *ir.FuncDecl) {
 		var tuntyped ir.Type
 		for _, param := range decl.Params {
 			c.checkLocalDecl(param)
+			c.setLocalAttributes(param, attrTargetParam, "a parameter")
+			if isClosureParam(param) {
+				c.noEscape[param.Sym.UniqKey] = true
+			}
 			if param.Sym != nil {
 				tuntyped = checkUntyped(param.Sym.T, tuntyped)
 			} else {
@@ -235,26 +327,71 @@ func (c *checker) checkFuncDecl(decl *ir.FuncDecl) {
 		decl.Return.Type = c.checkRootTypeExpr(decl.Return.Type, false)
 		tret := decl.Return.Type.Type()
 		tuntyped = checkUntyped(tuntyped, tret)
+		if tuntyped == nil && decl.Sym.ABI == ir.CABI {
+			// Fields must be known to find nested tuples
+			for _, param := range decl.Params {
+				if isUntypedLayout(param.Sym.T) {
+					tuntyped = ir.TBuiltinUnknown
+				}
+			}
+			if isUntypedLayout(tret) {
+				tuntyped = ir.TBuiltinUnknown
+			}
+		}
 		if tuntyped != nil {
 			decl.Sym.T = tuntyped
 		} else {
 			var params []ir.Field
+			hasDefault := false
 			for _, param := range decl.Params {
-				params = append(params, ir.Field{Name: param.Sym.Name, T: param.Type.Type()})
+				field := ir.Field{Name: param.Sym.Name, T: param.Type.Type()}
+				if init := defaultValue(param); init != nil {
+					hasDefault = true
+					if param.Sym.IsConst() {
+						field.Default = init
+					} else {
+						c.nodeError(init, "default value of parameter '%s' must be a compile-time constant", param.Sym.Name)
+					}
+				} else if hasDefault {
+					c.nodeError(param, "parameter '%s' without a default value cannot follow a parameter with a default value", param.Sym.Name)
+				}
+				params = append(params, field)
 			}
 			cabi := (decl.Sym.ABI == ir.CABI)
-			tfun := ir.NewFuncType(params, tret, cabi)
-			if isTypeMismatch(decl.Sym.T, tfun) {
+			tfun := ir.NewFuncType(params, decl.Variadic, tret, cabi)
+			if decl.Variadic && !cabi {
+				c.error(decl.Name.Pos(), "variadic function '%s' must have abi '%s'", decl.Name.Literal, ir.CABI)
+				decl.Sym.T = ir.TBuiltinInvalid
+			} else if decl.Variadic && !decl.SignatureOnly() {
+				c.error(decl.Name.Pos(), "variadic function '%s' cannot have a body", decl.Name.Literal)
+				decl.Sym.T = ir.TBuiltinInvalid
+			} else if ttuple := findTupleType(tfun); cabi && ttuple != nil {
+				c.error(decl.Name.Pos(), "function '%s' with abi '%s' cannot use tuple type '%s'", decl.Name.Literal, ir.CABI, ttuple)
+				decl.Sym.T = ir.TBuiltinInvalid
+			} else if isTypeMismatch(decl.Sym.T, tfun) {
 				c.error(decl.Name.Pos(), "redeclaration of '%s' (different declaration is at %s)", decl.Name.Literal, decl.Sym.Pos)
 				decl.Sym.T = ir.TBuiltinInvalid
 			} else {
 				decl.Sym.T = tfun
+				if decl.Sym.Attribute(ir.AttrNoReturn) != nil && tret.Kind() != ir.TVoid {
+					c.nodeError(decl.Return.Type, "function '%s' with attribute '%s' cannot have return type '%s'", decl.Name.Literal, ir.AttrNoReturn, tret)
+				}
+				if handler := decl.Sym.RuntimeHandler(); handler != "" {
+					c.checkRuntimeHandler(decl, tfun, handler)
+				}
 			}
 		}
 		c.object.checked = true
 	}
 	if !decl.SignatureOnly() {
+		prevNarrowed, prevAddressed := c.narrowed, c.addressed
+		c.narrowed = make(map[string]*ir.Symbol)
+		c.addressed = c.addrTakenNames(decl)
 		stmtList(decl.Body.Stmts, c.checkStmt)
+		c.narrowed, c.addressed = prevNarrowed, prevAddressed
+		if decl.Sym.Attribute(ir.AttrNoReturn) != nil && !c.object.incomplete && !isTerminatingBlock(decl.Body) {
+			c.error(decl.Body.EndPos(), "function '%s' with attribute '%s' can reach the end of its body", decl.Name.Literal, ir.AttrNoReturn)
+		}
 	}
 }
 
@@ -296,12 +433,193 @@ func (c *checker) checkStructDecl(decl *ir.StructDecl) {
 	var fields []ir.Field
 	for _, field := range decl.Fields {
 		if field.Sym != nil {
-			fields = append(fields, ir.Field{Name: field.Sym.Name, T: field.Type.Type()})
+			fields = append(fields, ir.Field{Name: field.Sym.Name, T: field.Type.Type(), Default: defaultValue(field)})
 		}
 	}
+	if decl.CUnion && typedBody && !c.checkCUnionFields(decl, fields) {
+		decl.Sym.T = ir.TBuiltinInvalid
+		return
+	}
 	tstruct.SetBody(fields, typedBody)
 }
 
+// The fields of a cunion share zero-initialized storage, so they cannot have
+// default values or types which don't have a default value.
+func (c *checker) checkCUnionFields(decl *ir.StructDecl, fields []ir.Field) bool {
+	valid := true
+	for i, field := range fields {
+		if field.Default != nil {
+			c.nodeError(field.Default, "field '%s' of cunion '%s' cannot have a default value", field.Name, decl.Name.Literal)
+			valid = false
+		} else if !hasDefaultValue(field.T) {
+			c.nodeError(decl.Fields[i].Name, "field '%s' of cunion '%s' cannot have type '%s'", field.Name, decl.Name.Literal, field.T)
+			valid = false
+		}
+	}
+	return valid
+}
+
+func (c *checker) checkUnionDecl(decl *ir.UnionDecl) {
+	tunion, ok := decl.Sym.T.(*ir.UnionType)
+	if !ok || tunion.TypedBody {
+		return
+	}
+	if len(decl.Variants) == 0 {
+		c.error(decl.Name.Pos(), "union '%s' must have at least one variant", decl.Name.Literal)
+		c.setUnionInvalid(decl)
+		return
+	}
+	var tuntyped ir.Type
+	for _, variant := range decl.Variants {
+		for _, field := range variant.Fields {
+			field.Type = c.checkRootTypeExpr(field.Type, true)
+			tfield := field.Type.Type()
+			tuntyped = checkUntyped(tfield, tuntyped)
+			if tuntyped == nil && isUntypedLayout(tfield) {
+				// The payload size depends on the field type
+				tuntyped = ir.TBuiltinUnknown
+			}
+		}
+	}
+	if tuntyped != nil {
+		if isInvalidType(tuntyped) {
+			c.setUnionInvalid(decl)
+		}
+		return
+	}
+	invalid := false
+	var variants []ir.Variant
+	for _, variant := range decl.Variants {
+		if variant.Sym == nil {
+			invalid = true
+			continue
+		}
+		var fields []ir.Field
+		names := make(map[string]bool)
+		for _, field := range variant.Fields {
+			name := field.Name.Literal
+			if field.Name.Tok != token.Placeholder {
+				if names[name] {
+					c.nodeError(field.Name, "duplicate field '%s' in variant '%s'", name, variant.Name.Literal)
+					invalid = true
+				}
+				names[name] = true
+			}
+			fields = append(fields, ir.Field{Name: name, T: field.Type.Type()})
+		}
+		variants = append(variants, ir.Variant{Name: variant.Sym.Name, Tag: len(variants), Sym: variant.Sym, Fields: fields})
+	}
+	if invalid {
+		c.setUnionInvalid(decl)
+		return
+	}
+	tunion.SetBody(variants, true)
+	for _, variant := range tunion.Variants {
+		variant.Sym.T = tunion
+		if len(variant.Fields) == 0 {
+			lit := &ir.UnionLit{Tag: variant.Tag}
+			lit.T = tunion
+			c.constMap[variant.Sym.Key] = lit
+		}
+	}
+}
+
+func (c *checker) setUnionInvalid(decl *ir.UnionDecl) {
+	decl.Sym.T = ir.TBuiltinInvalid
+	for _, variant := range decl.Variants {
+		if variant.Sym != nil {
+			variant.Sym.T = ir.TBuiltinInvalid
+		}
+	}
+}
+
+func (c *checker) checkEnumDecl(decl *ir.EnumDecl) {
+	tenum, ok := decl.Sym.T.(*ir.EnumType)
+	if !ok || tenum.TypedBody() {
+		return
+	}
+	tbacking := ir.TBuiltinInt
+	if decl.Backing != nil {
+		decl.Backing = c.checkRootTypeExpr(decl.Backing, true)
+		tbacking = decl.Backing.Type()
+	}
+	tuntyped := checkUntyped(tbacking)
+	for _, member := range decl.Members {
+		if member.Value != nil {
+			member.Value = c.checkExpr(member.Value)
+			tuntyped = checkUntyped(member.Value.Type(), tuntyped)
+		}
+	}
+	if tuntyped == nil && !ir.IsIntegerType(tbacking) {
+		c.nodeError(decl.Backing, "enum backing type must be an integer type (got '%s')", tbacking)
+		tuntyped = ir.TBuiltinInvalid
+	}
+	if tuntyped != nil {
+		if isInvalidType(tuntyped) {
+			c.setEnumInvalid(decl)
+		}
+		return
+	}
+	var members []ir.EnumValue
+	values := make(map[string]*ir.EnumMember)
+	next := big.NewInt(0)
+	invalid := false
+	for _, member := range decl.Members {
+		value := next
+		if member.Value != nil {
+			member.Value = c.finalizeExpr(member.Value, tbacking)
+			member.Value = c.foldConstExpr(member.Value)
+			lit := constIntLit(member.Value)
+			if isInvalidType(member.Value.Type()) {
+				invalid = true
+				continue
+			} else if lit == nil {
+				c.nodeError(member.Value, "enum value is not a constant integer expression")
+				invalid = true
+				continue
+			} else if isTypeMismatch(member.Value.Type(), tbacking) {
+				c.nodeError(member.Value, "enum value expects type '%s' (got '%s')", tbacking, member.Value.Type())
+				invalid = true
+				continue
+			}
+			value = lit.Raw.(*big.Int)
+		}
+		if !integerFitsType(value, tbacking) {
+			c.nodeError(member, "enum value %s of '%s' overflows backing type '%s'", value, member.Name.Literal, tbacking)
+			invalid = true
+			continue
+		}
+		if existing, ok := values[value.String()]; ok {
+			c.nodeError(member, "duplicate enum value %s ('%s' has the same value)", value, existing.Name.Literal)
+			invalid = true
+		}
+		values[value.String()] = member
+		next = big.NewInt(0).Add(value, big.NewInt(1))
+		if member.Sym != nil {
+			lit := &ir.BasicLit{Tok: token.Integer, Value: value.String(), Raw: value}
+			lit.SetRange(member.Pos(), member.EndPos())
+			lit.T = tbacking
+			c.constMap[member.Sym.Key] = lit
+			member.Sym.T = tenum
+			members = append(members, ir.EnumValue{Name: member.Sym.Name, Value: value})
+		}
+	}
+	if invalid {
+		c.setEnumInvalid(decl)
+		return
+	}
+	tenum.SetBody(tbacking, members)
+}
+
+func (c *checker) setEnumInvalid(decl *ir.EnumDecl) {
+	decl.Sym.T = ir.TBuiltinInvalid
+	for _, member := range decl.Members {
+		if member.Sym != nil {
+			member.Sym.T = ir.TBuiltinInvalid
+		}
+	}
+}
+
 func (c *checker) checkStmt(stmt ir.Stmt) {
 	switch stmt := stmt.(type) {
 	case *ir.BlockStmt:
@@ -311,10 +629,18 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 			c.closeScope()
 		}
 		prevScope := c.setScope(stmt.Scope)
+		narrowed := c.saveNarrowed()
 		stmtList(stmt.Stmts, c.checkStmt)
+		c.restoreNarrowed(narrowed)
 		c.setScope(prevScope)
 	case *ir.DeclStmt:
 		c.checkLocalDecl(stmt.D)
+	case *ir.StaticAssertStmt:
+		if isUnknownExprType(stmt.D.Cond) {
+			c.checkStaticAssert(stmt.D)
+		}
+	case *ir.DestructureStmt:
+		c.checkDestructureStmt(stmt)
 	case *ir.IfStmt:
 		if isUnknownExprType(stmt.Cond) {
 			stmt.Cond = c.checkExpr(stmt.Cond)
@@ -323,10 +649,19 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 				stmt.Cond.SetType(ir.TBuiltinInvalid)
 			}
 		}
+		narrowed := c.narrow(narrowedSymbols(stmt.Cond, true))
 		c.checkStmt(stmt.Body)
+		c.unnarrow(narrowed)
 		if stmt.Else != nil {
+			narrowed = c.narrow(narrowedSymbols(stmt.Cond, false))
 			c.checkStmt(stmt.Else)
+			c.unnarrow(narrowed)
+		} else if isTerminatingBlock(stmt.Body) {
+			// The condition is false for the remainder of the enclosing block
+			c.narrow(narrowedSymbols(stmt.Cond, false))
 		}
+	case *ir.MatchStmt:
+		c.checkMatchStmt(stmt)
 	case *ir.ForStmt:
 		if c.step == 0 {
 			c.openScope("for")
@@ -334,9 +669,15 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 			c.closeScope()
 		}
 		prevScope := c.setScope(stmt.Body.Scope)
+		narrowed := c.saveNarrowed()
+		// Variables that are assigned in the loop can be null in the next iteration
+		c.unnarrow(assignedNames(stmt, nil))
 		if stmt.Init != nil {
 			c.checkStmt(stmt.Init)
 		}
+		if stmt.Range != nil {
+			c.checkRangeClause(stmt.Range)
+		}
 		if stmt.Cond != nil {
 			if isUnknownExprType(stmt.Cond) {
 				stmt.Cond = c.checkExpr(stmt.Cond)
@@ -349,11 +690,31 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 		if stmt.Inc != nil {
 			c.checkStmt(stmt.Inc)
 		}
-		c.loop++
+		if stmt.Cond != nil {
+			c.narrow(narrowedSymbols(stmt.Cond, true))
+		}
+		if c.step == 0 && stmt.Label != nil {
+			if loop := c.lookupLoop(stmt.Label); loop != nil {
+				c.nodeError(stmt.Label, "duplicate label '%s' (previous label is at %s)", stmt.Label.Literal, loop.Label.Pos())
+			}
+		}
+		c.loops = append(c.loops, stmt)
 		stmtList(stmt.Body.Stmts, c.checkStmt)
-		c.loop--
+		c.loops = c.loops[:len(c.loops)-1]
+		c.restoreNarrowed(narrowed)
 		c.setScope(prevScope)
 	case *ir.ReturnStmt:
+		if c.blockExpr {
+			if c.step == 0 {
+				c.error(stmt.Pos(), "'%s' cannot be used in a block expression", token.Return)
+			}
+			return
+		}
+		if c.step == 0 {
+			if fun := c.object.d.(*ir.FuncDecl); fun.Sym.Attribute(ir.AttrNoReturn) != nil {
+				c.error(stmt.Pos(), "'%s' cannot be used in function '%s' with attribute '%s'", token.Return, fun.Name.Literal, ir.AttrNoReturn)
+			}
+		}
 		if stmt.X != nil && isUnknownExprType(stmt.X) {
 			stmt.X = c.checkExpr(stmt.X)
 			if isUntypedExpr(stmt.X) {
@@ -368,37 +729,82 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 		if stmt.X == nil {
 			stmt.X = ir.NewIdent1(token.Placeholder)
 			stmt.X.SetType(ir.TBuiltinVoid)
-		} else {
-			stmt.X = c.finalizeExpr(stmt.X, tret)
 		}
+		stmt.X = c.finalizeExpr(stmt.X, tret)
 		texpr := stmt.X.Type()
 		if isTypeMismatch(texpr, tret) {
 			c.error(stmt.Pos(), "function expects return type %s (got %s)", tret, texpr)
 			stmt.X.SetType(ir.TBuiltinInvalid)
 		}
 	case *ir.DeferStmt:
+		if c.blockExpr {
+			if c.step == 0 {
+				c.error(stmt.Pos(), "'%s' cannot be used in a block expression", stmt.Tok)
+			}
+			return
+		}
 		c.scope.Defer = true
+		if c.step == 0 && stmt.Tok.Is(token.Errdefer) {
+			if tret := c.funcReturnType(); tret != nil && !isUntyped(tret) && tret.Kind() != ir.TResult {
+				c.error(stmt.Pos(), "'%s' can only be used in a function that returns a result type", stmt.Tok)
+			}
+		}
+		prevDeferred := c.deferred
+		c.deferred = true
 		c.checkStmt(stmt.S)
+		c.deferred = prevDeferred
 	case *ir.BranchStmt:
-		if c.step == 0 && c.loop == 0 {
-			c.error(stmt.Pos(), "'%s' can only be used in a loop", stmt.Tok)
+		if c.step == 0 {
+			if len(c.loops) == 0 {
+				c.error(stmt.Pos(), "'%s' can only be used in a loop", stmt.Tok)
+			} else if stmt.Label != nil && c.lookupLoop(stmt.Label) == nil {
+				c.nodeError(stmt.Label, "unknown label '%s'", stmt.Label.Literal)
+			}
 		}
 	case *ir.AssignStmt:
 		if isUnknownExprType(stmt.Left) || isUnknownExprType(stmt.Right) {
-			stmt.Left = c.checkExpr(stmt.Left)
 			stmt.Right = c.checkExpr(stmt.Right)
+			c.unnarrowAssigned(stmt.Left)
+			stmt.Left = c.checkExpr(stmt.Left)
 			if checkUntypedExprs(stmt.Left, stmt.Right) != nil {
 				return
 			}
 			left := stmt.Left
 			err := false
-			if stmt.Assign != token.Assign {
+			shiftop := stmt.Assign.OneOf(token.ShlAssign, token.ShrAssign)
+			ptrop := ir.IsRawPointerType(left.Type()) && stmt.Assign.OneOf(token.AddAssign, token.SubAssign)
+			if shiftop || stmt.Assign.OneOf(token.BitAndAssign, token.BitOrAssign, token.BitXorAssign) {
+				if !ir.IsIntegerType(left.Type()) {
+					err = true
+					c.nodeError(left, "type %s is not an integer", left.Type())
+				}
+			} else if ptrop {
+				if isIncompleteType(ir.ToBaseType(left.Type()).(*ir.PointerType).Elem, nil) {
+					err = true
+					c.nodeError(left, "pointer arithmetic cannot be performed on type %s", left.Type())
+				}
+			} else if stmt.Assign != token.Assign {
 				if !ir.IsNumericType(left.Type()) {
 					err = true
 					c.nodeError(left, "type %s is not numeric", left.Type())
 				}
 			}
-			if !err {
+			if !err && shiftop {
+				stmt.Right = c.finalizeExpr(stmt.Right, left.Type())
+				if !ir.IsIntegerType(stmt.Right.Type()) {
+					err = true
+					c.nodeError(stmt.Right, "shift count has non-integer type %s", stmt.Right.Type())
+				} else {
+					stmt.Right = c.foldConstExpr(stmt.Right)
+					err = isInvalidType(stmt.Right.Type()) || !c.checkShiftCount(stmt.Right, left.Type())
+				}
+			} else if !err && ptrop {
+				stmt.Right = c.finalizeExpr(stmt.Right, ir.TBuiltinInt64)
+				if !ir.IsIntegerType(stmt.Right.Type()) {
+					err = true
+					c.nodeError(stmt.Right, "pointer offset has non-integer type %s", stmt.Right.Type())
+				}
+			} else if !err {
 				stmt.Right = c.finalizeExpr(stmt.Right, left.Type())
 				right := stmt.Right
 				if isTypeMismatch(left.Type(), right.Type()) {
@@ -416,6 +822,24 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 				}
 			}
 		}
+		c.unnarrowAssigned(stmt.Left)
+	case *ir.PanicStmt:
+		if isUnknownExprType(stmt.X) {
+			stmt.X = c.checkPanicMessage(stmt.X)
+		}
+	case *ir.AssertStmt:
+		if isUnknownExprType(stmt.Cond) {
+			stmt.Cond = c.checkExpr(stmt.Cond)
+			if isTypeMismatch(stmt.Cond.Type(), ir.TBuiltinBool) {
+				c.error(stmt.Cond.Pos(), "condition expects type %s (got %s)", ir.TBool, stmt.Cond.Type())
+				stmt.Cond.SetType(ir.TBuiltinInvalid)
+			}
+		}
+		if stmt.Msg != nil && isUnknownExprType(stmt.Msg) {
+			stmt.Msg = c.checkPanicMessage(stmt.Msg)
+		}
+		// The condition is true for the remainder of the enclosing block
+		c.narrow(narrowedSymbols(stmt.Cond, true))
 	case *ir.ExprStmt:
 		if isUnknownExprType(stmt.X) {
 			stmt.X = c.checkExpr(stmt.X)
@@ -423,6 +847,8 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 			tx := stmt.X.Type()
 			if tx.Kind() == ir.TModule {
 				c.nodeError(stmt.X, "invalid expression (type '%s')", tx)
+			} else if tx.Kind() == ir.TResult {
+				c.nodeError(stmt.X, "result of type '%s' must be handled or propagated with 'try'", tx)
 			}
 		}
 	default:
@@ -430,6 +856,417 @@ func (c *checker) checkStmt(stmt ir.Stmt) {
 	}
 }
 
+// checkPanicMessage checks the message of a panic or an assertion, which must be a string.
+func (c *checker) checkPanicMessage(msg ir.Expr) ir.Expr {
+	msg = c.checkExpr(msg)
+	if isUntypedExpr(msg) {
+		return msg
+	}
+	tstring := ir.NewSliceType(ir.TBuiltinByte, true, true)
+	msg = c.finalizeExpr(msg, tstring)
+	if isTypeMismatch(msg.Type(), tstring) {
+		msg = c.finalizeExpr(msg, nil)
+		c.error(msg.Pos(), "panic message expects type %s (got %s)", tstring, msg.Type())
+		msg.SetType(ir.TBuiltinInvalid)
+	}
+	return msg
+}
+
+// funcReturnType returns the return type of the function being checked, or nil if
+// the current object is not a function.
+func (c *checker) funcReturnType() ir.Type {
+	if fun, ok := c.object.d.(*ir.FuncDecl); ok {
+		return fun.Return.Type.Type()
+	}
+	return nil
+}
+
+func (c *checker) checkRangeClause(r *ir.RangeClause) {
+	if c.step == 0 {
+		// The range is checked before the loop variables are in scope
+		r.X = c.checkExpr(r.X)
+		if r.End != nil {
+			r.End = c.checkExpr(r.End)
+		}
+		if r.Index != nil {
+			c.insertLocalValDeclSymbol(r.Index, c.object.CUID(), c.object.modFQN())
+			r.Index.Sym.Flags |= ir.SymFlagReadOnly
+		}
+		c.insertLocalValDeclSymbol(r.Elem, c.object.CUID(), c.object.modFQN())
+		r.Elem.Sym.Flags |= ir.SymFlagReadOnly
+	} else if !isUnknownType(r.Elem.Sym.T) {
+		return
+	} else {
+		r.X = c.checkExpr(r.X)
+		if r.End != nil {
+			r.End = c.checkExpr(r.End)
+		}
+	}
+
+	if tuntyped := checkUntypedExprs(r.X, r.End); tuntyped != nil {
+		r.Elem.Sym.T = tuntyped
+		if r.Index != nil {
+			r.Index.Sym.T = tuntyped
+		}
+		return
+	}
+
+	var telem ir.Type
+	if r.End != nil {
+		telem = c.checkIntegerRange(r)
+	} else {
+		r.X = c.finalizeExpr(r.X, nil)
+		r.X = tryDeref(r.X)
+		switch tx := ir.ToBaseType(r.X.Type()).(type) {
+		case *ir.ArrayType:
+			telem = tx.Elem
+			if r.Ref {
+				if !r.X.Lvalue() {
+					c.nodeError(r.X, "expression is not an lvalue")
+				} else if r.X.ReadOnly() && !r.Immutable {
+					c.nodeError(r.X, "expression is read-only")
+				} else if isPackedField(r.X) {
+					c.nodeError(r.X, "a field in a packed struct cannot be iterated by reference")
+				}
+			}
+		case *ir.SliceType:
+			telem = tx.Elem
+			if r.Ref && tx.ReadOnly && !r.Immutable {
+				c.nodeError(r.X, "expression is read-only")
+			}
+		default:
+			c.nodeError(r.X, "range expects an array, slice or integer range (got '%s')", r.X.Type())
+		}
+	}
+
+	if telem == nil {
+		telem = ir.TBuiltinInvalid
+	} else if r.Ref {
+		telem = ir.NewPointerType(telem, r.Immutable)
+	}
+	r.Elem.Sym.T = telem
+	if r.Index != nil {
+		r.Index.Sym.T = ir.TBuiltinUSize
+	}
+}
+
+// The range is from X up to but not including End, and both must have the same integer type.
+func (c *checker) checkIntegerRange(r *ir.RangeClause) ir.Type {
+	if r.Index != nil {
+		c.nodeError(r.Index, "integer range cannot have an index variable")
+		return nil
+	} else if r.Ref {
+		c.nodeError(r.Elem, "integer range cannot be iterated by reference")
+		return nil
+	}
+	r.X = ensureCompatibleType(r.X, r.End.Type())
+	r.End = ensureCompatibleType(r.End, r.X.Type())
+	r.X = c.finalizeExpr(r.X, nil)
+	r.End = c.finalizeExpr(r.End, r.X.Type())
+	tstart := r.X.Type()
+	tend := r.End.Type()
+	if isUntyped(tstart) || isUntyped(tend) {
+		return nil
+	} else if !tstart.Equals(tend) {
+		c.nodeError(r.X, "type mismatch '%s' and '%s'", tstart, tend)
+		return nil
+	} else if !ir.IsIntegerType(tstart) {
+		c.nodeError(r.X, "integer range expects an integer type (got '%s')", tstart)
+		return nil
+	}
+	return tstart
+}
+
+// lookupLoop returns the innermost enclosing loop with the label.
+func (c *checker) lookupLoop(label *ir.Ident) *ir.ForStmt {
+	for i := len(c.loops) - 1; i >= 0; i-- {
+		if loop := c.loops[i]; loop.Label != nil && loop.Label.Literal == label.Literal {
+			return loop
+		}
+	}
+	return nil
+}
+
+func (c *checker) checkDestructureStmt(stmt *ir.DestructureStmt) {
+	c.checkLocalDecl(stmt.Tuple)
+	tuple := stmt.Tuple.Sym
+	if !isUntyped(tuple.T) && (stmt.Elems[0].Sym == nil || isUnknownType(stmt.Elems[0].Sym.T)) {
+		if ttuple, ok := ir.ToBaseType(tuple.T).(*ir.TupleType); !ok {
+			c.nodeError(stmt.Tuple.Initializer, "cannot destructure type '%s'", tuple.T)
+		} else if len(ttuple.Elems) != len(stmt.Elems) {
+			c.nodeError(stmt, "cannot destructure tuple with %d elements into %d variables", len(ttuple.Elems), len(stmt.Elems))
+		}
+	}
+	for _, elem := range stmt.Elems {
+		if c.step == 0 {
+			elem.Initializer.(*ir.TupleElemExpr).X.(*ir.Ident).Sym = tuple
+		}
+		c.checkLocalDecl(elem)
+	}
+}
+
+func (c *checker) checkMatchStmt(stmt *ir.MatchStmt) {
+	if c.step == 0 {
+		for _, arm := range stmt.Arms {
+			c.openScope("match_arm")
+			arm.Body.Scope = c.scope
+			c.insertMatchBindings(arm)
+			c.closeScope()
+		}
+	}
+	if isUnknownExprType(stmt.X) || hasUnknownMatchPattern(stmt) {
+		c.checkMatchPatterns(stmt)
+	}
+	for _, arm := range stmt.Arms {
+		prevScope := c.setScope(arm.Body.Scope)
+		stmtList(arm.Body.Stmts, c.checkStmt)
+		c.setScope(prevScope)
+	}
+	if stmt.Else != nil {
+		c.checkStmt(stmt.Else)
+	}
+}
+
+func hasUnknownMatchPattern(stmt *ir.MatchStmt) bool {
+	for _, arm := range stmt.Arms {
+		for _, pattern := range arm.Patterns {
+			if isUnknownExprType(pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// The exhaustiveness check is done once all patterns have been typed,
+// which ensures that the errors are only reported once.
+func (c *checker) checkMatchPatterns(stmt *ir.MatchStmt) {
+	if isUnknownExprType(stmt.X) {
+		stmt.X = c.checkExpr(stmt.X)
+		if isUntypedExpr(stmt.X) {
+			return
+		}
+		stmt.X = c.finalizeExpr(stmt.X, nil)
+		tx := stmt.X.Type()
+		switch ir.ToBaseType(tx).(type) {
+		case *ir.UnionType, *ir.EnumType:
+		default:
+			if tx.Kind() != ir.TBool && !ir.IsIntegerType(tx) {
+				c.nodeError(stmt.X, "match expects an integer, bool, enum or union (got '%s')", tx)
+				stmt.X.SetType(ir.TBuiltinInvalid)
+				return
+			}
+		}
+	} else if isInvalidType(stmt.X.Type()) {
+		return
+	}
+
+	tx := stmt.X.Type()
+	tunion, isUnion := ir.ToBaseType(tx).(*ir.UnionType)
+	pending := false
+	invalid := false
+
+	for _, arm := range stmt.Arms {
+		for i, pattern := range arm.Patterns {
+			if isUnknownExprType(pattern) {
+				if isUnion {
+					arm.Patterns[i] = c.checkVariantPattern(arm, pattern, tunion, tx)
+				} else {
+					arm.Patterns[i] = c.checkValuePattern(pattern, tx)
+				}
+			}
+			if isUnknownExprType(arm.Patterns[i]) {
+				pending = true
+			} else if isInvalidType(arm.Patterns[i].Type()) {
+				invalid = true
+			}
+		}
+	}
+
+	if pending || invalid {
+		return
+	}
+
+	matched := make(map[string]bool)
+	for _, arm := range stmt.Arms {
+		for _, pattern := range arm.Patterns {
+			key, name := matchPatternKey(pattern, tx)
+			if matched[key] {
+				c.nodeError(pattern, "pattern can never match ('%s' is already matched)", name)
+			}
+			matched[key] = true
+		}
+	}
+
+	var missing []string
+	switch t := ir.ToBaseType(tx).(type) {
+	case *ir.UnionType:
+		for _, variant := range t.Variants {
+			if !matched[variant.Name] {
+				missing = append(missing, variant.Name)
+			}
+		}
+	case *ir.EnumType:
+		for _, member := range t.Members {
+			if !matched[member.Value.String()] {
+				missing = append(missing, member.Name)
+			}
+		}
+	default:
+		if tx.Kind() != ir.TBool {
+			if stmt.Else == nil {
+				c.nodeError(stmt, "match on type '%s' must have an else arm", tx)
+			}
+			return
+		}
+		for _, name := range []string{token.False.String(), token.True.String()} {
+			if !matched[name] {
+				missing = append(missing, name)
+			}
+		}
+	}
+
+	if stmt.Else != nil {
+		if len(missing) == 0 {
+			c.nodeError(stmt.Else, "else arm can never match (all cases of '%s' are matched)", tx)
+		}
+	} else if len(missing) > 0 {
+		c.nodeError(stmt, "match is not exhaustive (missing %s)", quotedList(missing))
+	}
+}
+
+func (c *checker) checkValuePattern(pattern ir.Expr, tx ir.Type) ir.Expr {
+	pattern = c.checkExpr(pattern)
+	if isUntypedExpr(pattern) {
+		return pattern
+	}
+	pattern = c.finalizeExpr(pattern, tx)
+	if isInvalidType(pattern.Type()) {
+		return pattern
+	} else if isTypeMismatch(pattern.Type(), tx) {
+		c.nodeError(pattern, "pattern expects type '%s' (got '%s')", tx, pattern.Type())
+		pattern.SetType(ir.TBuiltinInvalid)
+	} else if tx.Kind() == ir.TBool {
+		if lit, ok := pattern.(*ir.BasicLit); !ok || !lit.Tok.OneOf(token.True, token.False) {
+			c.nodeError(pattern, "pattern is not a constant expression")
+			pattern.SetType(ir.TBuiltinInvalid)
+		}
+	} else if constIntLit(pattern) == nil {
+		c.nodeError(pattern, "pattern is not a constant expression")
+		pattern.SetType(ir.TBuiltinInvalid)
+	}
+	return pattern
+}
+
+// A variant pattern is replaced with a union literal that has the variant's tag.
+func (c *checker) checkVariantPattern(arm *ir.MatchArm, pattern ir.Expr, tunion *ir.UnionType, tx ir.Type) ir.Expr {
+	app, isApp := pattern.(*ir.AppExpr)
+	if isApp {
+		app.X = c.checkExpr2(app.X, modeBoth)
+		if isUntypedExpr(app.X) {
+			app.T = app.X.Type()
+			return app
+		}
+	} else {
+		pattern = c.checkExpr2(pattern, modeBoth)
+		if isUntypedExpr(pattern) {
+			return pattern
+		}
+	}
+
+	var variant *ir.Variant
+	target := pattern
+	if isApp {
+		target = app.X
+	}
+	if isTypeMismatch(target.Type(), tx) {
+		c.nodeError(target, "pattern expects type '%s' (got '%s')", tx, target.Type())
+	} else if constExpr, ok := target.(*ir.ConstExpr); ok {
+		if lit, ok := constExpr.X.(*ir.UnionLit); ok {
+			variant = &tunion.Variants[lit.Tag]
+		}
+	} else if sym := ir.ExprSymbol(target); sym != nil {
+		variant = tunion.VariantOf(sym)
+	}
+	if variant == nil {
+		if !isTypeMismatch(target.Type(), tx) {
+			c.nodeError(target, "pattern is not a variant of '%s'", tx)
+		}
+		pattern.SetType(ir.TBuiltinInvalid)
+		return pattern
+	}
+
+	if isApp {
+		if len(app.Args) > len(variant.Fields) {
+			c.nodeError(app, "too many bindings for variant '%s' (expected %d, got %d)", variant.Name, len(variant.Fields), len(app.Args))
+			app.T = ir.TBuiltinInvalid
+			return app
+		}
+		for argIndex, arg := range app.Args {
+			fieldIndex := argIndex
+			if arg.Name != nil {
+				fieldIndex = -1
+				for i, field := range variant.Fields {
+					if field.Name == arg.Name.Literal {
+						fieldIndex = i
+						break
+					}
+				}
+				if fieldIndex < 0 {
+					c.nodeError(arg.Name, "variant '%s' has no field '%s'", variant.Name, arg.Name.Literal)
+					app.T = ir.TBuiltinInvalid
+					continue
+				}
+			}
+			if ident, ok := arg.Value.(*ir.Ident); ok && ident.Sym != nil {
+				ident.Sym.T = variant.Fields[fieldIndex].T
+				ident.T = ident.Sym.T
+				arm.Bindings = append(arm.Bindings, &ir.MatchBinding{Name: ident, Field: fieldIndex})
+			}
+		}
+		if isInvalidType(app.Type()) {
+			return app
+		}
+	}
+
+	lit := &ir.UnionLit{Tag: variant.Tag}
+	lit.SetRange(pattern.Pos(), pattern.EndPos())
+	lit.T = tx
+	return lit
+}
+
+func matchPatternKey(pattern ir.Expr, tx ir.Type) (key string, name string) {
+	if lit, ok := pattern.(*ir.UnionLit); ok {
+		name = ir.ToBaseType(tx).(*ir.UnionType).Variants[lit.Tag].Name
+		return name, name
+	}
+	if lit := constIntLit(pattern); lit != nil {
+		key = lit.Raw.(*big.Int).String()
+		name = key
+		if tenum, ok := ir.ToBaseType(tx).(*ir.EnumType); ok {
+			for _, member := range tenum.Members {
+				if member.Value.String() == key {
+					name = member.Name
+				}
+			}
+		}
+		return key, name
+	}
+	name = pattern.(*ir.BasicLit).Tok.String()
+	return name, name
+}
+
+func quotedList(names []string) string {
+	var buf bytes.Buffer
+	for i, name := range names {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(fmt.Sprintf("'%s'", name))
+	}
+	return buf.String()
+}
+
 func (c *checker) checkExpr2(expr ir.Expr, mode int) ir.Expr {
 	prevMode := c.setMode(mode)
 	expr = c.checkExpr(expr)
@@ -461,6 +1298,10 @@ func (c *checker) checkExpr(expr ir.Expr) ir.Expr {
 		return c.checkSliceTypeExpr(expr)
 	case *ir.ArrayTypeExpr:
 		return c.checkArrayTypeExpr(expr)
+	case *ir.TupleTypeExpr:
+		return c.checkTupleTypeExpr(expr)
+	case *ir.ResultTypeExpr:
+		return c.checkResultTypeExpr(expr)
 	case *ir.FuncTypeExpr:
 		return c.checkFuncTypeExpr(expr)
 	case *ir.Ident:
@@ -473,18 +1314,32 @@ func (c *checker) checkExpr(expr ir.Expr) ir.Expr {
 		return c.checkBasicLit(expr)
 	case *ir.ArrayLit:
 		return c.checkArrayLit(expr)
+	case *ir.TupleLit:
+		return c.checkTupleLit(expr)
+	case *ir.TupleElemExpr:
+		return c.checkTupleElemExpr(expr)
 	case *ir.BinaryExpr:
 		return c.checkBinaryExpr(expr)
 	case *ir.UnaryExpr:
 		return c.checkUnaryExpr(expr)
 	case *ir.AddrExpr:
 		return c.checkAddrExpr(expr)
+	case *ir.TryExpr:
+		return c.checkTryExpr(expr)
+	case *ir.IfExpr:
+		return c.checkIfExpr(expr)
+	case *ir.BlockExpr:
+		return c.checkBlockExpr(expr)
 	case *ir.DerefExpr:
 		return c.checkDerefExpr(expr)
 	case *ir.IndexExpr:
 		return c.checkIndexExpr(expr)
 	case *ir.SliceExpr:
 		return c.checkSliceExpr(expr)
+	case *ir.InstanceExpr:
+		return c.checkInstanceExpr(expr)
+	case *ir.FuncLit:
+		return c.checkFuncLit(expr)
 	case *ir.AppExpr:
 		return c.checkAppExpr(expr)
 	case *ir.CastExpr:
@@ -493,8 +1348,18 @@ func (c *checker) checkExpr(expr ir.Expr) ir.Expr {
 		return c.checkLenExpr(expr)
 	case *ir.SizeofExpr:
 		return c.checkSizeofExpr(expr)
+	case *ir.OverflowExpr:
+		return c.checkOverflowExpr(expr)
+	case *ir.NewExpr:
+		return c.checkNewExpr(expr)
+	case *ir.MakeExpr:
+		return c.checkMakeExpr(expr)
+	case *ir.DeleteExpr:
+		return c.checkDeleteExpr(expr)
 	case *ir.ConstExpr:
 		return expr
+	case *ir.UnionLit:
+		return expr
 	default:
 		panic(fmt.Sprintf("Unhandled expr %T at %s", expr, expr.Pos()))
 	}
@@ -518,12 +1383,49 @@ func (c *checker) finalizeExpr(expr ir.Expr, target ir.Type) ir.Expr {
 	}
 
 	if checkIncomplete && isIncompleteType(texpr, nil) {
-		c.nodeError(expr, "expression has incomplete type '%s'", texpr)
+		if slice, ok := expr.(*ir.SliceExpr); ok && ir.IsRawPointerType(slice.X.Type()) {
+			c.nodeError(expr, "raw pointer of type '%s' can only be sliced by reference (use '&ptr[start:end]')", slice.X.Type())
+		} else {
+			c.nodeError(expr, "expression has incomplete type '%s'", texpr)
+		}
 		expr.SetType(ir.TBuiltinInvalid)
 		return expr
 	}
 
-	return ensureCompatibleType(expr, target)
+	if lit, ok := expr.(*ir.TupleLit); ok {
+		return c.finalizeTupleLit(lit, target)
+	}
+
+	if overflow, ok := expr.(*ir.OverflowExpr); ok && overflow.Op == token.CheckedAdd {
+		expr = c.finalizeCheckedAdd(overflow, target)
+	}
+
+	if ifexpr, ok := expr.(*ir.IfExpr); ok {
+		return c.finalizeIfExpr(ifexpr, target)
+	} else if block, ok := expr.(*ir.BlockExpr); ok {
+		return c.finalizeBlockExpr(block, target)
+	}
+
+	if dot, ok := expr.(*ir.DotExpr); ok && dot.X.Type().Kind() == ir.TInterface {
+		c.nodeError(expr, "interface method '%s' must be called", dot.Name.Literal)
+		expr.SetType(ir.TBuiltinInvalid)
+		return expr
+	}
+
+	if cast, ok := c.tryInterfaceCast(expr, target); ok {
+		return cast
+	}
+
+	if cast, ok := c.tryResultCast(expr, target); ok {
+		return cast
+	}
+
+	expr = ensureCompatibleType(expr, target)
+	if isConstValueType(expr.Type()) {
+		// Constants are folded so that overflow is reported wherever a value is converted to its type
+		expr = c.foldConstExpr(expr)
+	}
+	return expr
 }
 
 func (c *checker) checkRootTypeExpr(expr ir.Expr, checkVoid bool) ir.Expr {
@@ -549,7 +1451,16 @@ func (c *checker) checkPointerTypeExpr(expr *ir.PointerTypeExpr) ir.Expr {
 	}
 	ro := expr.Decl.Is(token.Val)
 	tx := expr.X.Type()
-	if tslice, ok := tx.(*ir.SliceType); ok {
+	if expr.Raw {
+		expr.T = ir.NewRawPointerType(tx, ro)
+	} else if expr.Nullable {
+		if tslice, ok := tx.(*ir.SliceType); ok && !tslice.Ptr {
+			c.nodeError(expr, "slice type '%s' cannot be nullable", tx)
+			expr.T = ir.TBuiltinInvalid
+		} else {
+			expr.T = ir.NewNullablePointerType(tx, ro)
+		}
+	} else if tslice, ok := tx.(*ir.SliceType); ok {
 		if !tslice.Ptr {
 			tslice.Ptr = true
 			tslice.ReadOnly = ro
@@ -577,7 +1488,6 @@ func (c *checker) checkSliceTypeExpr(expr *ir.SliceTypeExpr) ir.Expr {
 func (c *checker) checkArrayTypeExpr(expr *ir.ArrayTypeExpr) ir.Expr {
 	if isUnknownExprType(expr.Size) {
 		expr.Size = c.checkExpr2(expr.Size, modeExpr)
-		expr.Size = c.finalizeExpr(expr.Size, nil)
 	}
 	expr.X = c.checkExpr(expr.X)
 	if tuntyped := checkUntypedExprs(expr.Size, expr.X); tuntyped != nil {
@@ -585,17 +1495,7 @@ func (c *checker) checkArrayTypeExpr(expr *ir.ArrayTypeExpr) ir.Expr {
 		return expr
 	}
 	size := 0
-	if !ir.IsIntegerType(expr.Size.Type()) {
-		c.error(expr.Size.Pos(), "array size expects an integer type (got %s)", expr.Size.Type())
-	} else if lit, ok := expr.Size.(*ir.BasicLit); !ok {
-		c.error(expr.Size.Pos(), "array size is not a constant expression")
-	} else if lit.NegatigeInteger() {
-		c.error(expr.Size.Pos(), "array size cannot be negative")
-	} else if lit.Zero() {
-		c.error(expr.Size.Pos(), "array size cannot be zero")
-	} else {
-		size = int(lit.AsU64())
-	}
+	expr.Size, size = c.checkArraySize(expr.Size)
 	if size == 0 {
 		expr.T = ir.TBuiltinInvalid
 		return expr
@@ -605,10 +1505,57 @@ func (c *checker) checkArrayTypeExpr(expr *ir.ArrayTypeExpr) ir.Expr {
 	return expr
 }
 
+func (c *checker) checkTupleTypeExpr(expr *ir.TupleTypeExpr) ir.Expr {
+	var tuntyped ir.Type
+	for i, elem := range expr.Elems {
+		expr.Elems[i] = c.checkExpr(elem)
+		tuntyped = checkUntyped(expr.Elems[i].Type(), tuntyped)
+	}
+	if tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+	var elems []ir.Type
+	for _, elem := range expr.Elems {
+		elems = append(elems, elem.Type())
+	}
+	expr.T = ir.NewTupleType(elems)
+	return expr
+}
+
+func (c *checker) checkResultTypeExpr(expr *ir.ResultTypeExpr) ir.Expr {
+	expr.Value = c.checkExpr(expr.Value)
+	expr.Error = c.checkExpr(expr.Error)
+	if tuntyped := checkUntypedExprs(expr.Value, expr.Error); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+	tval := expr.Value.Type()
+	terr := expr.Error.Type()
+	if terr.Kind() == ir.TVoid {
+		c.nodeError(expr.Error, "result type cannot have error type '%s'", terr)
+		expr.T = ir.TBuiltinInvalid
+	} else if tval.Kind() == ir.TResult || terr.Kind() == ir.TResult {
+		c.nodeError(expr, "result type cannot be nested")
+		expr.T = ir.TBuiltinInvalid
+	} else if tval.Equals(terr) {
+		c.nodeError(expr, "result type must have different value and error types (got '%s')", tval)
+		expr.T = ir.TBuiltinInvalid
+	} else {
+		expr.T = ir.NewResultType(tval, terr)
+	}
+	return expr
+}
+
 func (c *checker) checkFuncTypeExpr(expr *ir.FuncTypeExpr) ir.Expr {
 	var params []ir.Field
 	var tuntyped ir.Type
 	for i, param := range expr.Params {
+		if param.Initializer != nil {
+			c.nodeError(param.Initializer, "parameter of function type cannot have a default value")
+			param.Initializer = nil
+		}
+		c.setLocalAttributes(param, attrTargetParam, "a parameter")
 		expr.Params[i].Type = c.checkRootTypeExpr(param.Type, true)
 		tparam := expr.Params[i].Type.Type()
 		params = append(params, ir.Field{Name: param.Name.Literal, T: tparam})
@@ -621,7 +1568,11 @@ func (c *checker) checkFuncTypeExpr(expr *ir.FuncTypeExpr) ir.Expr {
 	}
 	cabi := false
 	if expr.ABI != nil {
-		if expr.ABI.Literal == ir.CABI {
+		if expr.Closure {
+			c.error(expr.ABI.Pos(), "closure type cannot have an abi")
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		} else if expr.ABI.Literal == ir.CABI {
 			cabi = true
 		} else if !ir.IsValidABI(expr.ABI.Literal) {
 			c.error(expr.ABI.Pos(), "unknown abi '%s'", expr.ABI.Literal)
@@ -629,7 +1580,31 @@ func (c *checker) checkFuncTypeExpr(expr *ir.FuncTypeExpr) ir.Expr {
 			return expr
 		}
 	}
-	expr.T = ir.NewFuncType(params, expr.Return.Type.Type(), cabi)
+	if expr.Variadic && !cabi {
+		c.error(expr.Pos(), "variadic function type must have abi '%s'", ir.CABI)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+	if cabi {
+		for _, texpr := range append(paramTypes(expr.Params), expr.Return.Type) {
+			if isUntypedLayout(texpr.Type()) {
+				// Fields must be known to find nested tuples
+				expr.T = ir.TBuiltinUnknown
+				return expr
+			}
+			if ttuple := findNestedTupleType(texpr.Type()); ttuple != nil {
+				c.nodeError(texpr, "function type with abi '%s' cannot use tuple type '%s'", ir.CABI, ttuple)
+				expr.T = ir.TBuiltinInvalid
+				return expr
+			}
+		}
+	}
+	tfun := ir.NewFuncType(params, expr.Variadic, expr.Return.Type.Type(), cabi)
+	if expr.Closure {
+		expr.T = ir.NewClosureType(tfun)
+	} else {
+		expr.T = tfun
+	}
 	return expr
 }
 
@@ -644,7 +1619,13 @@ func (c *checker) resolveIdent(expr *ir.Ident) {
 			c.nodeError(expr, "unknown identifier '%s'", expr.Literal)
 			return
 		}
+		expr.Sym = c.tryCapture(c.object.funcLit, expr, expr.Sym)
+		if expr.Sym == nil {
+			expr.T = ir.TBuiltinInvalid
+			return
+		}
 	}
+	c.updateCaptureType(expr.Sym)
 	c.trySetDep(expr.Sym, true)
 	if isUntyped(expr.Sym.T) {
 		expr.T = expr.Sym.T
@@ -653,7 +1634,10 @@ func (c *checker) resolveIdent(expr *ir.Ident) {
 	sym := expr.Sym
 	valid := true
 	if c.mode != modeBoth {
-		if c.isTypeMode() {
+		if sym.T.Kind() == ir.TGeneric {
+			valid = false
+			c.nodeError(expr, "generic '%s' requires type arguments", sym.Name)
+		} else if c.isTypeMode() {
 			if sym.Kind != ir.TypeSymbol {
 				valid = false
 				c.nodeError(expr, "'%s' is not a type", sym.Name)
@@ -669,11 +1653,15 @@ func (c *checker) resolveIdent(expr *ir.Ident) {
 		if !sym.Public && sym.CUID != c.object.CUID() {
 			valid = false
 			c.nodeError(expr, "'%s' is private and cannot be accessed from a different compilation unit", expr.Literal)
+		} else {
+			valid = c.checkNoEscape(expr)
 		}
 	}
 	expr.T = ir.TBuiltinInvalid
 	if valid {
 		expr.T = expr.Sym.T
+		c.narrowIdent(expr)
+		c.checkDeprecated(expr)
 	}
 }
 
@@ -731,20 +1719,36 @@ func (c *checker) resolveScopeLookup(expr *ir.ScopeLookup) {
 
 func (c *checker) maybeConstExpr(ident *ir.Ident) ir.Expr {
 	sym := ident.Sym
-	if sym.IsBuiltin() && sym.IsConst() {
-		res := &ir.ConstExpr{
-			X: c.constMap[sym.Key],
+	if sym.IsConst() {
+		if x, ok := c.constMap[sym.Key]; ok {
+			res := &ir.ConstExpr{
+				X: x,
+			}
+			res.T = sym.T
+			res.SetRange(ident.Pos(), ident.EndPos())
+			return res
 		}
-		res.T = sym.T
-		res.SetRange(ident.Pos(), ident.EndPos())
-		return res
 	}
 	return ident
 }
 
+func (c *checker) checkVariantPayload(ident *ir.Ident) bool {
+	if c.mode == modeBoth || ident.Sym.Kind != ir.ValSymbol {
+		return true
+	}
+	if tunion, ok := ir.ToBaseType(ident.T).(*ir.UnionType); ok {
+		if variant := tunion.VariantOf(ident.Sym); variant != nil && len(variant.Fields) > 0 {
+			c.nodeError(ident, "variant '%s' expects payload arguments", variant.Name)
+			ident.T = ir.TBuiltinInvalid
+			return false
+		}
+	}
+	return true
+}
+
 func (c *checker) checkIdent(expr *ir.Ident) ir.Expr {
 	c.resolveIdent(expr)
-	if !isUntyped(expr.T) {
+	if !isUntyped(expr.T) && c.checkVariantPayload(expr) {
 		return c.maybeConstExpr(expr)
 	}
 	return expr
@@ -755,7 +1759,10 @@ func (c *checker) checkScopeLookup(expr *ir.ScopeLookup) ir.Expr {
 	if !isUntyped(expr.T) {
 		last := expr.Last()
 		last.SetRange(expr.Pos(), expr.EndPos())
-		return c.maybeConstExpr(last)
+		if c.checkVariantPayload(last) {
+			return c.maybeConstExpr(last)
+		}
+		return last
 	}
 	return expr
 }
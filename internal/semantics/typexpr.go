@@ -32,23 +32,7 @@ func (c *checker) checkArrayLit(expr *ir.ArrayLit) ir.Expr {
 	size := 0
 
 	if expr.Size != nil {
-		// TODO: Merge with ArrayTypeExpr
-		expr.Size = c.finalizeExpr(expr.Size, nil)
-		if !ir.IsIntegerType(expr.Size.Type()) {
-			c.error(expr.Size.Pos(), "array size expects an integer type (got '%s')", expr.Size.Type())
-			expr.Size.SetType(ir.TBuiltinInvalid)
-		} else if lit, ok := expr.Size.(*ir.BasicLit); !ok {
-			c.error(expr.Size.Pos(), "array size is not a constant expression")
-			expr.Size.SetType(ir.TBuiltinInvalid)
-		} else if lit.NegatigeInteger() {
-			c.error(expr.Size.Pos(), "array size cannot be negative")
-			expr.Size.SetType(ir.TBuiltinInvalid)
-		} else if lit.Zero() {
-			c.error(expr.Size.Pos(), "array size cannot be zero")
-			expr.Size.SetType(ir.TBuiltinInvalid)
-		} else {
-			size = int(lit.AsU64())
-		}
+		expr.Size, size = c.checkArraySize(expr.Size)
 		if size == 0 {
 			expr.T = ir.TBuiltinInvalid
 			return expr
@@ -90,33 +74,153 @@ func (c *checker) checkArrayLit(expr *ir.ArrayLit) ir.Expr {
 	return expr
 }
 
+// checkArraySize evaluates the size of an array type or literal. The returned size is 0 if it's invalid.
+func (c *checker) checkArraySize(expr ir.Expr) (ir.Expr, int) {
+	expr = c.finalizeExpr(expr, nil)
+	if !ir.IsIntegerType(expr.Type()) {
+		c.error(expr.Pos(), "array size expects an integer type (got '%s')", expr.Type())
+		expr.SetType(ir.TBuiltinInvalid)
+		return expr, 0
+	}
+	expr = c.foldConstExpr(expr)
+	if isInvalidType(expr.Type()) {
+		return expr, 0
+	}
+	size := 0
+	if lit, ok := expr.(*ir.BasicLit); !ok {
+		c.error(expr.Pos(), "array size is not a constant expression")
+	} else if lit.NegatigeInteger() {
+		c.error(expr.Pos(), "array size cannot be negative")
+	} else if lit.Zero() {
+		c.error(expr.Pos(), "array size cannot be zero")
+	} else if !lit.Raw.(*big.Int).IsInt64() {
+		c.error(expr.Pos(), "array size %s is too large", lit.Raw)
+	} else {
+		size = int(lit.AsU64())
+	}
+	if size == 0 {
+		expr.SetType(ir.TBuiltinInvalid)
+	}
+	return expr, size
+}
+
+func (c *checker) checkTupleLit(expr *ir.TupleLit) ir.Expr {
+	var tuntyped ir.Type
+	for i, elem := range expr.Elems {
+		expr.Elems[i] = c.checkExpr(elem)
+		tuntyped = checkUntyped(expr.Elems[i].Type(), tuntyped)
+	}
+	if tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+	var elems []ir.Type
+	for _, elem := range expr.Elems {
+		telem := elem.Type()
+		if isTypeOneOf(telem, ir.TVoid, ir.TModule) {
+			c.nodeError(elem, "tuple element cannot have type '%s'", telem)
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
+		elems = append(elems, telem)
+	}
+	expr.T = ir.NewTupleType(elems)
+	return expr
+}
+
+// The elements of a tuple literal are finalized with the element types of the target.
+func (c *checker) finalizeTupleLit(expr *ir.TupleLit, target ir.Type) ir.Expr {
+	var ttarget *ir.TupleType
+	if target != nil {
+		if t, ok := ir.ToBaseType(target).(*ir.TupleType); ok && len(t.Elems) == len(expr.Elems) {
+			ttarget = t
+		}
+	}
+	var elems []ir.Type
+	for i, elem := range expr.Elems {
+		var telem ir.Type
+		if ttarget != nil {
+			telem = ttarget.Elems[i]
+		}
+		expr.Elems[i] = c.finalizeExpr(elem, telem)
+		elems = append(elems, expr.Elems[i].Type())
+	}
+	expr.T = ir.NewTupleType(elems)
+	return expr
+}
+
+func (c *checker) checkTupleElemExpr(expr *ir.TupleElemExpr) ir.Expr {
+	expr.X = c.checkExpr(expr.X)
+	if tuntyped := checkUntypedExprs(expr.X); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+	if ttuple, ok := ir.ToBaseType(expr.X.Type()).(*ir.TupleType); ok && expr.Index < len(ttuple.Elems) {
+		expr.T = ttuple.Elems[expr.Index]
+	} else {
+		// The error is reported by the destructure statement
+		expr.T = ir.TBuiltinInvalid
+	}
+	return expr
+}
+
 func (c *checker) checkBinaryExpr(expr *ir.BinaryExpr) ir.Expr {
 	expr.Left = c.checkExpr(expr.Left)
-	expr.Right = c.checkExpr(expr.Right)
+	if expr.Op.OneOf(token.Land, token.Lor) {
+		// The right operand is only evaluated if the left operand is true (and) or false (or)
+		narrowed := c.narrow(narrowedSymbols(expr.Left, expr.Op == token.Land))
+		expr.Right = c.checkExpr(expr.Right)
+		c.unnarrow(narrowed)
+	} else {
+		expr.Right = c.checkExpr(expr.Right)
+	}
 
 	if tuntyped := checkUntypedExprs(expr.Left, expr.Right); tuntyped != nil {
 		expr.T = tuntyped
 		return expr
 	}
 
-	expr.Left = ensureCompatibleType(expr.Left, expr.Right.Type())
+	if res := c.tryOperatorMethod(expr); res != nil {
+		return res
+	}
+
+	badop := false
+	logicop := expr.Op.OneOf(token.Land, token.Lor)
+	eqop := expr.Op.OneOf(token.Eq, token.Neq)
+	orderop := expr.Op.OneOf(token.Gt, token.GtEq, token.Lt, token.LtEq)
+	mathop := expr.Op.OneOf(token.Add, token.Sub, token.Mul, token.Div, token.Mod)
+	bitop := expr.Op.OneOf(token.BitAnd, token.BitOr, token.BitXor)
+	shiftop := expr.Op.OneOf(token.Shl, token.Shr)
+
+	if !shiftop {
+		expr.Left = ensureCompatibleType(expr.Left, expr.Right.Type())
+	}
 	expr.Right = ensureCompatibleType(expr.Right, expr.Left.Type())
 
 	tleft := expr.Left.Type()
 	tright := expr.Right.Type()
 
+	// The shift count can have a different integer type than the shifted value
+	if shiftop && ir.IsIntegerType(tleft) && ir.IsIntegerType(tright) {
+		expr.Right = c.foldConstExpr(expr.Right)
+		if isInvalidType(expr.Right.Type()) || !c.checkShiftCount(expr.Right, tleft) {
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
+		expr.T = tleft
+		return expr
+	}
+
+	if mathop && (ir.IsRawPointerType(tleft) || ir.IsRawPointerType(tright)) {
+		return c.checkPointerArithmetic(expr)
+	}
+
 	if !tleft.Equals(tright) {
 		c.nodeError(expr, "type mismatch '%s' and '%s'", tleft, tright)
 		expr.T = ir.TBuiltinInvalid
 		return expr
 	}
 
-	badop := false
-	logicop := expr.Op.OneOf(token.Land, token.Lor)
-	eqop := expr.Op.OneOf(token.Eq, token.Neq)
-	orderop := expr.Op.OneOf(token.Gt, token.GtEq, token.Lt, token.LtEq)
-	mathop := expr.Op.OneOf(token.Add, token.Sub, token.Mul, token.Div, token.Mod)
-
 	toperand := expr.Left.Type()
 	texpr := toperand
 	if logicop || eqop || orderop {
@@ -126,13 +230,19 @@ func (c *checker) checkBinaryExpr(expr *ir.BinaryExpr) ir.Expr {
 	if ir.IsNumericType(toperand) {
 		if logicop {
 			badop = true
+		} else if (bitop || shiftop) && !ir.IsIntegerType(toperand) {
+			badop = true
 		}
 	} else if toperand.Kind() == ir.TBool {
-		if orderop || mathop {
+		if orderop || mathop || bitop || shiftop {
 			badop = true
 		}
 	} else if toperand.Kind() == ir.TPointer {
-		if orderop || mathop {
+		if (orderop && !ir.IsRawPointerType(toperand)) || mathop || bitop || shiftop {
+			badop = true
+		}
+	} else if toperand.Kind() == ir.TEnum {
+		if !eqop {
 			badop = true
 		}
 	} else {
@@ -149,6 +259,296 @@ func (c *checker) checkBinaryExpr(expr *ir.BinaryExpr) ir.Expr {
 	return expr
 }
 
+// Raw pointers can be offset by an integer, and the difference between two raw pointers
+// is the number of elements between them.
+// checkShiftCount reports a constant shift count which is negative or not less than the bit size of
+// the shifted type. Shifts of untyped constants are checked when they are evaluated.
+func (c *checker) checkShiftCount(count ir.Expr, t ir.Type) bool {
+	if t.Kind() == ir.TConstInt {
+		return true
+	}
+	lit := constIntLit(count)
+	if lit == nil {
+		return true
+	}
+	val := lit.Raw.(*big.Int)
+	if val.Sign() < 0 {
+		c.nodeError(count, "negative shift count %s", val)
+		return false
+	} else if bits := c.target.Sizeof(t) * 8; val.Cmp(big.NewInt(int64(bits))) >= 0 {
+		c.nodeError(count, "shift count %s is too large for type '%s'", val, t)
+		return false
+	}
+	return true
+}
+
+func (c *checker) checkPointerArithmetic(expr *ir.BinaryExpr) ir.Expr {
+	var tptr ir.Type
+	if ir.IsRawPointerType(expr.Left.Type()) {
+		tptr = expr.Left.Type()
+		if expr.Op == token.Sub && ir.IsRawPointerType(expr.Right.Type()) {
+			expr.T = ir.TBuiltinInt64
+			if !tptr.Equals(expr.Right.Type()) {
+				c.nodeError(expr, "type mismatch '%s' and '%s'", tptr, expr.Right.Type())
+				expr.T = ir.TBuiltinInvalid
+				return expr
+			}
+		} else {
+			expr.Right = c.finalizeExpr(expr.Right, ir.TBuiltinInt64)
+			if ir.IsIntegerType(expr.Right.Type()) {
+				expr.T = tptr
+			}
+		}
+	} else if expr.Op == token.Add {
+		tptr = expr.Right.Type()
+		expr.Left = c.finalizeExpr(expr.Left, ir.TBuiltinInt64)
+		if ir.IsIntegerType(expr.Left.Type()) {
+			expr.T = tptr
+		}
+	}
+
+	if expr.T == nil || expr.Op.OneOf(token.Mul, token.Div, token.Mod) {
+		c.nodeError(expr, "operator '%s' cannot be performed on types %s and %s", expr.Op, expr.Left.Type(), expr.Right.Type())
+		expr.T = ir.TBuiltinInvalid
+	} else if telem := ir.ToBaseType(tptr).(*ir.PointerType).Elem; isIncompleteType(telem, nil) {
+		c.nodeError(expr, "pointer arithmetic cannot be performed on type '%s'", tptr)
+		expr.T = ir.TBuiltinInvalid
+	}
+
+	return expr
+}
+
+func (c *checker) checkIfExpr(expr *ir.IfExpr) ir.Expr {
+	if isUnknownExprType(expr.Cond) {
+		expr.Cond = c.checkExpr(expr.Cond)
+		if isTypeMismatch(expr.Cond.Type(), ir.TBuiltinBool) {
+			c.error(expr.Cond.Pos(), "condition expects type %s (got %s)", ir.TBool, expr.Cond.Type())
+			expr.Cond.SetType(ir.TBuiltinInvalid)
+		}
+	}
+
+	narrowed := c.narrow(narrowedSymbols(expr.Cond, true))
+	c.checkBlockExpr(expr.Then)
+	c.unnarrow(narrowed)
+	narrowed = c.narrow(narrowedSymbols(expr.Cond, false))
+	expr.Else = c.checkExpr(expr.Else)
+	c.unnarrow(narrowed)
+
+	if tuntyped := checkUntypedExprs(expr.Cond, expr.Then, expr.Else); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	// The branches are unified in the same way as the operands of a binary expression
+	if !expr.Then.Type().Equals(expr.Else.Type()) {
+		expr.Then = c.finalizeBlockExpr(expr.Then, expr.Else.Type())
+		expr.Else = c.finalizeExpr(expr.Else, expr.Then.Type())
+		if !expr.Then.Type().Equals(expr.Else.Type()) {
+			// Untyped constants get their default type before the mismatch is reported
+			expr.Then = c.finalizeBlockExpr(expr.Then, nil)
+			expr.Else = c.finalizeExpr(expr.Else, nil)
+		}
+	}
+
+	return c.setIfExprType(expr)
+}
+
+func (c *checker) setIfExprType(expr *ir.IfExpr) ir.Expr {
+	tthen := expr.Then.Type()
+	telse := expr.Else.Type()
+	if isUntyped(tthen) || isUntyped(telse) {
+		expr.T = ir.TBuiltinInvalid
+	} else if !tthen.Equals(telse) {
+		c.nodeError(expr, "if expression has branches of different types '%s' and '%s'", tthen, telse)
+		expr.T = ir.TBuiltinInvalid
+	} else {
+		expr.T = tthen
+	}
+	return expr
+}
+
+func (c *checker) finalizeIfExpr(expr *ir.IfExpr, target ir.Type) ir.Expr {
+	expr.Then = c.finalizeBlockExpr(expr.Then, target)
+	expr.Else = c.finalizeExpr(expr.Else, target)
+	return c.setIfExprType(expr)
+}
+
+// The statements of a block expression cannot leave the block, so return, defer, try,
+// and break or continue of an enclosing loop are not allowed.
+func (c *checker) checkBlockExpr(expr *ir.BlockExpr) ir.Expr {
+	if !isUnknownExprType(expr) {
+		return expr
+	}
+	if c.step == 0 {
+		c.openScope("block")
+		expr.Block.Scope = c.scope
+		c.closeScope()
+	}
+	prevScope := c.setScope(expr.Block.Scope)
+	prevLoops := c.loops
+	prevBlockExpr := c.blockExpr
+	c.loops = nil
+	c.blockExpr = true
+	narrowed := c.saveNarrowed()
+	stmtList(expr.Block.Stmts, c.checkStmt)
+	expr.X = c.checkExpr(expr.X)
+	c.restoreNarrowed(narrowed)
+	c.loops = prevLoops
+	c.blockExpr = prevBlockExpr
+	c.setScope(prevScope)
+
+	tx := expr.X.Type()
+	if tx.Kind() == ir.TVoid {
+		c.nodeError(expr.X, "block expression must have a value (got '%s')", tx)
+		expr.T = ir.TBuiltinInvalid
+	} else {
+		expr.T = tx
+	}
+	return expr
+}
+
+func (c *checker) finalizeBlockExpr(expr *ir.BlockExpr, target ir.Type) *ir.BlockExpr {
+	expr.X = c.finalizeExpr(expr.X, target)
+	expr.X = c.foldConstExpr(expr.X)
+	expr.T = expr.X.Type()
+	if addr := findLocalAddr(expr.X, expr.Block.Scope); addr != nil {
+		// The stack of the block is released when the value has been computed
+		c.nodeError(addr, "reference to '%s' cannot escape the block expression it's declared in", addrRoot(addr).(*ir.Ident).Literal)
+		expr.T = ir.TBuiltinInvalid
+	}
+	return expr
+}
+
+// findLocalAddr returns an address expression in the value of a block expression which refers to a
+// variable declared in the block. Only expressions that pass on the references in their operands are
+// searched, and calls other than struct literals are assumed to not return their arguments.
+func findLocalAddr(expr ir.Expr, scope *ir.Scope) *ir.AddrExpr {
+	var operands []ir.Expr
+	switch expr := expr.(type) {
+	case *ir.AddrExpr:
+		if ident, ok := addrRoot(expr).(*ir.Ident); ok && ident.Sym != nil && scope.Symbols[ident.Sym.Name] == ident.Sym {
+			return expr
+		}
+	case *ir.CastExpr:
+		operands = append(operands, expr.X)
+	case *ir.IfExpr:
+		operands = append(operands, expr.Then, expr.Else)
+	case *ir.BlockExpr:
+		operands = append(operands, expr.X)
+	case *ir.ArrayLit:
+		operands = expr.Initializers
+	case *ir.TupleLit:
+		operands = expr.Elems
+	case *ir.AppExpr:
+		if expr.IsStruct {
+			for _, arg := range expr.Args {
+				operands = append(operands, arg.Value)
+			}
+		}
+	case *ir.UnionLit:
+		for _, arg := range expr.Args {
+			operands = append(operands, arg.Value)
+		}
+	}
+	for _, operand := range operands {
+		if addr := findLocalAddr(operand, scope); addr != nil {
+			return addr
+		}
+	}
+	return nil
+}
+
+// addrRoot returns the variable, or other expression, that stores the value referred to by addr.
+func addrRoot(addr *ir.AddrExpr) ir.Expr {
+	x := addr.X
+	for {
+		switch expr := x.(type) {
+		case *ir.DotExpr:
+			if expr.X.Type() == nil || expr.X.Type().Kind() == ir.TPointer {
+				return x
+			}
+			x = expr.X
+		case *ir.IndexExpr:
+			if expr.X.Type() == nil || expr.X.Type().Kind() != ir.TArray {
+				return x
+			}
+			x = expr.X
+		case *ir.SliceExpr:
+			if expr.X.Type() == nil || expr.X.Type().Kind() != ir.TArray {
+				return x
+			}
+			x = expr.X
+		default:
+			return x
+		}
+	}
+}
+
+// Operators on structs are calls to methods of the struct. The method for the ordering
+// operators returns a negative, zero or positive integer which is compared against 0.
+var operatorMethods = map[token.Token]string{
+	token.Add:  "add",
+	token.Sub:  "sub",
+	token.Mul:  "mul",
+	token.Div:  "div",
+	token.Mod:  "mod",
+	token.Eq:   "eq",
+	token.Neq:  "eq",
+	token.Gt:   "cmp",
+	token.GtEq: "cmp",
+	token.Lt:   "cmp",
+	token.LtEq: "cmp",
+}
+
+func (c *checker) tryOperatorMethod(expr *ir.BinaryExpr) ir.Expr {
+	name, ok := operatorMethods[expr.Op]
+	if !ok {
+		return nil
+	}
+	call := c.checkOperatorMethod(expr, expr.Left, name, expr.Right)
+	if call == nil {
+		return nil
+	} else if isUnknownExprType(call) {
+		// The call is created again when the expression is checked next time
+		expr.T = ir.TBuiltinUnknown
+		return expr
+	}
+
+	var res ir.Expr
+	switch expr.Op {
+	case token.Neq:
+		res = &ir.UnaryExpr{Op: token.Lnot, X: call}
+	case token.Gt, token.GtEq, token.Lt, token.LtEq:
+		zero := &ir.BasicLit{Tok: token.Integer, Value: "0"}
+		zero.SetRange(expr.EndPos(), expr.EndPos())
+		res = &ir.BinaryExpr{Left: call, Op: expr.Op, Right: zero}
+	default:
+		return call
+	}
+	res.SetRange(expr.Pos(), expr.EndPos())
+	return c.checkExpr(res)
+}
+
+// checkOperatorMethod rewrites an operator on a struct to a call of the method with the given name.
+// Nil is returned if x is not a struct or the struct doesn't have the method.
+func (c *checker) checkOperatorMethod(expr ir.Expr, x ir.Expr, name string, arg ir.Expr) ir.Expr {
+	tstruct, ok := ir.ToBaseType(x.Type()).(*ir.StructType)
+	if !ok || tstruct.Opaque() {
+		return nil
+	}
+	if sym := tstruct.Scope().Lookup(name); sym == nil || !sym.IsMethod() {
+		return nil
+	}
+	dot := &ir.DotExpr{X: x, Name: ir.NewIdent2(token.Ident, name)}
+	dot.Name.SetRange(expr.Pos(), expr.EndPos())
+	dot.SetRange(expr.Pos(), expr.EndPos())
+	argExpr := &ir.ArgExpr{Value: arg}
+	argExpr.SetRange(arg.Pos(), arg.EndPos())
+	call := &ir.AppExpr{X: dot, Args: []*ir.ArgExpr{argExpr}}
+	call.SetRange(expr.Pos(), expr.EndPos())
+	return c.checkExpr(call)
+}
+
 func (c *checker) checkUnaryExpr(expr *ir.UnaryExpr) ir.Expr {
 	expr.X = c.checkExpr(expr.X)
 	tx := expr.X.Type()
@@ -178,6 +578,12 @@ func (c *checker) checkUnaryExpr(expr *ir.UnaryExpr) ir.Expr {
 			c.error(expr.Pos(), "logical not cannot be performed on type '%s')", tx)
 
 		}
+	case token.BitNot:
+		if ir.IsIntegerType(tx) {
+			expr.T = tx
+		} else {
+			c.error(expr.Pos(), "bitwise not cannot be performed on type '%s'", tx)
+		}
 	default:
 		panic(fmt.Sprintf("Unhandled unary op %s", expr.Op))
 	}
@@ -202,6 +608,8 @@ func (c *checker) checkAddrExpr(expr *ir.AddrExpr) ir.Expr {
 		c.error(expr.X.Pos(), "expression is not an lvalue")
 	} else if expr.X.ReadOnly() && !expr.Immutable {
 		c.error(expr.X.Pos(), "expression is read-only")
+	} else if isPackedField(expr.X) {
+		c.error(expr.X.Pos(), "cannot take the address of a field in a packed struct")
 	} else {
 		if tslice, ok := tx.(*ir.SliceType); ok {
 			if !tslice.Ptr {
@@ -234,7 +642,9 @@ func (c *checker) checkDerefExpr(expr *ir.DerefExpr) ir.Expr {
 
 	switch t := ir.ToBaseType(tx).(type) {
 	case *ir.PointerType:
-		expr.T = t.Elem
+		if !c.checkNullableDeref(expr.X) {
+			expr.T = t.Elem
+		}
 	default:
 		c.error(expr.X.Pos(), "dereference operator cannot be used on type '%s'", tx)
 	}
@@ -268,8 +678,29 @@ func (c *checker) checkDotExpr(expr *ir.DotExpr) ir.Expr {
 			expr.T = expr.Name.T
 			c.setScope(prevScope)
 		}
+	case *ir.InterfaceType:
+		c.trySetDep(tx.Sym, true)
+		if !tx.TypedBody {
+			expr.T = ir.TBuiltinUnknown
+		} else if index := tx.MethodIndex(expr.Name.Literal); index >= 0 {
+			expr.T = tx.Methods[index].T
+		} else {
+			c.nodeError(expr.Name, "interface '%s' has no method '%s'", tx, expr.Name.Literal)
+		}
+	case *ir.ResultType:
+		if t := resultFieldType(tx, expr.Name.Literal); t != nil {
+			if expr.Name.Literal == ir.ResultValue && !c.isNarrowed(expr.X) {
+				c.nodeError(expr, "value of result type '%s' can only be read after checking '%s'", expr.X.Type(), ir.ResultOk)
+			} else {
+				expr.T = t
+			}
+		} else {
+			c.nodeError(expr.Name, "result type '%s' has no field '%s'", expr.X.Type(), expr.Name.Literal)
+		}
 	default:
-		c.nodeError(expr.X, "dot operator cannot be used on type '%s'", expr.X.Type())
+		if !c.checkNullableDeref(expr.X) {
+			c.nodeError(expr.X, "dot operator cannot be used on type '%s'", expr.X.Type())
+		}
 	}
 
 	if expr.T == nil {
@@ -280,25 +711,71 @@ func (c *checker) checkDotExpr(expr *ir.DotExpr) ir.Expr {
 }
 
 func (c *checker) checkIndexExpr(expr *ir.IndexExpr) ir.Expr {
+	if isUnknownExprType(expr.X) && (c.mode == modeExpr || c.mode == modeBoth) {
+		switch expr.X.(type) {
+		case *ir.Ident, *ir.ScopeLookup:
+			// Generic with a single type argument
+			expr.X = c.checkExpr2(expr.X, modeBoth)
+			if expr.X.Type().Kind() == ir.TGeneric {
+				inst := &ir.InstanceExpr{X: expr.X, Args: []ir.Expr{expr.Index}}
+				inst.SetRange(expr.Pos(), expr.EndPos())
+				return c.checkInstanceExpr(inst)
+			} else if sym := ir.ExprSymbol(expr.X); sym != nil && sym.Kind == ir.TypeSymbol && !isUntypedExpr(expr.X) {
+				c.nodeError(expr.X, "type '%s' cannot be used in expression", sym.T)
+				expr.T = ir.TBuiltinInvalid
+				return expr
+			}
+		}
+	}
+
 	expr.X = c.checkExpr(expr.X)
 	expr.X = c.finalizeExpr(expr.X, nil)
 
 	expr.Index = c.checkExpr(expr.Index)
-	expr.Index = c.finalizeExpr(expr.Index, nil)
 
 	if tuntyped := checkUntypedExprs(expr.X, expr.Index); tuntyped != nil {
 		expr.T = tuntyped
 		return expr
 	}
 
-	expr.X = tryDeref(expr.X)
+	if !ir.IsRawPointerType(expr.X.Type()) {
+		expr.X = tryDeref(expr.X)
+	}
+
+	// The index method returns a reference to the element
+	if call := c.checkOperatorMethod(expr, expr.X, "index", expr.Index); call != nil {
+		if isUnknownExprType(call) {
+			expr.T = ir.TBuiltinUnknown
+			return expr
+		} else if tptr, ok := ir.ToBaseType(call.Type()).(*ir.PointerType); ok && !tptr.Raw && !tptr.Nullable {
+			deref := &ir.DerefExpr{X: call}
+			deref.SetRange(expr.Pos(), expr.EndPos())
+			return c.checkExpr(deref)
+		}
+		return call
+	}
+
+	expr.Index = c.finalizeExpr(expr.Index, nil)
+	expr.Index = c.foldConstExpr(expr.Index)
+	if isInvalidType(expr.Index.Type()) {
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
 	var telem ir.Type
 
 	switch tx := ir.ToBaseType(expr.X.Type()).(type) {
 	case *ir.ArrayType:
+		if ir.IsIntegerType(expr.Index.Type()) && !c.checkConstIndex(expr.Index, tx, false) {
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
 		telem = tx.Elem
 	case *ir.SliceType:
 		telem = tx.Elem
+	case *ir.PointerType:
+		if tx.Raw && !isIncompleteType(tx.Elem, nil) {
+			telem = tx.Elem
+		}
 	}
 
 	if telem != nil {
@@ -308,7 +785,7 @@ func (c *checker) checkIndexExpr(expr *ir.IndexExpr) ir.Expr {
 		} else {
 			expr.T = telem
 		}
-	} else {
+	} else if !c.checkNullableDeref(expr.X) {
 		c.error(expr.X.Pos(), "index operator cannot be used on type '%s'", expr.X.Type())
 	}
 
@@ -326,11 +803,13 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 	if expr.Start != nil {
 		expr.Start = c.checkExpr(expr.Start)
 		expr.Start = c.finalizeExpr(expr.Start, nil)
+		expr.Start = c.foldConstExpr(expr.Start)
 	}
 
 	if expr.End != nil {
 		expr.End = c.checkExpr(expr.End)
 		expr.End = c.finalizeExpr(expr.End, nil)
+		expr.End = c.foldConstExpr(expr.End)
 	}
 
 	if tuntyped := checkUntypedExprs(expr.X, expr.Start, expr.End); tuntyped != nil {
@@ -338,7 +817,9 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 		return expr
 	}
 
-	expr.X = tryDeref(expr.X)
+	if !ir.IsRawPointerType(expr.X.Type()) {
+		expr.X = tryDeref(expr.X)
+	}
 
 	tx := expr.X.Type()
 	ro := expr.X.ReadOnly()
@@ -351,8 +832,10 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 		telem = t.Elem
 		ro = t.ReadOnly
 	case *ir.PointerType:
-		telem = t.Elem
-		ro = t.ReadOnly
+		if !t.Nullable {
+			telem = t.Elem
+			ro = t.ReadOnly
+		}
 	}
 
 	if telem != nil {
@@ -374,6 +857,23 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 			}
 		}
 
+		if tarray, ok := ir.ToBaseType(tx).(*ir.ArrayType); ok && !err {
+			if expr.Start != nil && !c.checkConstIndex(expr.Start, tarray, true) {
+				err = true
+			}
+			if expr.End != nil && !c.checkConstIndex(expr.End, tarray, true) {
+				err = true
+			}
+			if !err && expr.Start != nil && expr.End != nil {
+				start := constIntLit(expr.Start)
+				end := constIntLit(expr.End)
+				if start != nil && end != nil && start.Raw.(*big.Int).Cmp(end.Raw.(*big.Int)) > 0 {
+					c.nodeError(expr, "invalid slice indices %s > %s", start.Raw, end.Raw)
+					err = true
+				}
+			}
+		}
+
 		if !err {
 			if expr.Start == nil {
 				expr.Start = createIntLit(0, ir.TBuiltinUSize)
@@ -406,7 +906,7 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 		if !err {
 			expr.T = ir.NewSliceType(telem, ro, false)
 		}
-	} else {
+	} else if !c.checkNullableDeref(expr.X) {
 		c.nodeError(expr.X, "slice operator cannot be used on type '%s'", tx)
 	}
 
@@ -417,16 +917,35 @@ func (c *checker) checkSliceExpr(expr *ir.SliceExpr) ir.Expr {
 	return expr
 }
 
+// checkConstIndex reports a constant index which is out of bounds for an array. The indexes of a
+// slice expression may be equal to the length of the array.
+func (c *checker) checkConstIndex(index ir.Expr, tarray *ir.ArrayType, slice bool) bool {
+	lit := constIntLit(index)
+	if lit == nil {
+		return true
+	}
+	val := lit.Raw.(*big.Int)
+	max := int64(tarray.Size)
+	if !slice {
+		max--
+	}
+	if val.Sign() < 0 || val.Cmp(big.NewInt(max)) > 0 {
+		c.nodeError(index, "index %s out of bounds for array of length %d", val, tarray.Size)
+		return false
+	}
+	return true
+}
+
 func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 	var tuntyped ir.Type
 	if isUnknownExprType(expr.X) {
-		expr.X = c.checkExpr2(expr.X, modeBoth)
+		expr.X = c.checkOperand(expr.X, modeBoth)
 		tx := expr.X.Type()
 		if isUntyped(tx) {
 			tuntyped = tx
 		} else {
 			ok := false
-			if tx.Kind() == ir.TFunc {
+			if isTypeOneOf(tx, ir.TFunc, ir.TClosure, ir.TGeneric) {
 				ok = true
 			} else if tx.Kind() == ir.TStruct {
 				if sym := ir.ExprSymbol(expr.X); sym != nil {
@@ -434,6 +953,12 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 						ok = true
 					}
 				}
+			} else if tunion, isUnion := ir.ToBaseType(tx).(*ir.UnionType); isUnion {
+				if sym := ir.ExprSymbol(expr.X); sym != nil {
+					if variant := tunion.VariantOf(sym); variant != nil && len(variant.Fields) > 0 {
+						ok = true
+					}
+				}
 			}
 			if !ok {
 				c.nodeError(expr.X, "application operator cannot be used on type '%s'", tx)
@@ -443,14 +968,28 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 		}
 	}
 
+	if tgeneric, ok := expr.X.Type().(*ir.GenericType); ok && tuntyped == nil {
+		if inst := c.inferTypeArgs(expr, tgeneric); inst != nil {
+			expr.X = inst
+		} else {
+			return expr
+		}
+	}
+
 	if sym := ir.ExprSymbol(expr.X); sym != nil {
 		if c.object.sym().Kind != ir.FuncSymbol && sym.Kind != ir.FuncSymbol {
 			c.trySetDep(sym, false)
 		}
 	}
 
+	// Arguments to struct and union literals are stored and therefore escape
+	call := isUntypedExpr(expr.X) || isTypeOneOf(expr.X.Type(), ir.TFunc, ir.TClosure)
 	for i, arg := range expr.Args {
-		expr.Args[i].Value = c.checkExpr(arg.Value)
+		if call {
+			expr.Args[i].Value = c.checkOperand(arg.Value, c.mode)
+		} else {
+			expr.Args[i].Value = c.checkExpr(arg.Value)
+		}
 		tuntyped = checkUntyped(expr.Args[i].Value.Type(), tuntyped)
 	}
 
@@ -461,17 +1000,19 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 
 	tx := expr.X.Type()
 
-	if tx.Kind() == ir.TFunc {
+	if isTypeOneOf(tx, ir.TFunc, ir.TClosure) {
 		doCheck := true
 		if dot, ok := expr.X.(*ir.DotExpr); ok {
 			if sym := ir.ExprSymbol(expr.X); sym != nil && sym.Kind == ir.FuncSymbol && sym.IsMethod() {
 				obj := dot.X
 				tobj := obj.Type()
+				packed := false
 				if obj.Type().Kind() != ir.TPointer {
 					if deref, ok := obj.(*ir.DerefExpr); ok {
 						obj = deref.X
 						obj.SetRange(deref.Pos(), deref.EndPos())
 					} else {
+						packed = isPackedField(obj)
 						addr := &ir.AddrExpr{
 							X: obj,
 						}
@@ -498,7 +1039,10 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 					}
 				}
 
-				if firstParamOK {
+				if firstParamOK && packed {
+					doCheck = false
+					c.nodeError(expr, "method '%s' cannot be called on a field in a packed struct", dot.Name.Literal)
+				} else if firstParamOK {
 					firstArg := &ir.ArgExpr{Value: obj}
 					expr.Args = append([]*ir.ArgExpr{firstArg}, expr.Args...)
 					expr.X = dot.Name
@@ -510,18 +1054,33 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 				}
 			}
 		}
-		tfun := ir.ToBaseType(tx).(*ir.FuncType)
+		tfun := toFuncType(tx)
 		if doCheck {
-			expr.Args = c.checkArgumentList(tx, expr.Args, tfun.Params, false)
+			expr.Args = c.checkArgumentList(tx, expr.Args, tfun.Params, tfun.Variadic, false)
 		}
 		expr.T = tfun.Return
+	} else if tunion, ok := ir.ToBaseType(tx).(*ir.UnionType); ok {
+		if !tunion.TypedBody {
+			expr.T = ir.TBuiltinUnknown
+			return expr
+		}
+		variant := tunion.VariantOf(ir.ExprSymbol(expr.X))
+		lit := &ir.UnionLit{Tag: variant.Tag}
+		lit.Args = c.checkArgumentList(tunion, expr.Args, variant.Fields, false, false)
+		lit.SetRange(expr.Pos(), expr.EndPos())
+		lit.T = tx
+		return lit
 	} else { // struct
 		tstruct := ir.ToBaseType(tx).(*ir.StructType)
 		if !tstruct.TypedBody {
 			expr.T = ir.TBuiltinUnknown
 			return expr
 		}
-		expr.Args = c.checkArgumentList(tstruct, expr.Args, tstruct.Fields, true)
+		if tstruct.CUnion {
+			expr.Args = c.checkCUnionArgs(tstruct, expr.Args)
+		} else {
+			expr.Args = c.checkArgumentList(tstruct, expr.Args, tstruct.Fields, false, true)
+		}
 		expr.T = tx
 		expr.IsStruct = true
 	}
@@ -529,7 +1088,7 @@ func (c *checker) checkAppExpr(expr *ir.AppExpr) ir.Expr {
 	return expr
 }
 
-func (c *checker) checkArgumentList(tobj ir.Type, args []*ir.ArgExpr, fields []ir.Field, autofill bool) []*ir.ArgExpr {
+func (c *checker) checkArgumentList(tobj ir.Type, args []*ir.ArgExpr, fields []ir.Field, variadic bool, autofill bool) []*ir.ArgExpr {
 	named := false
 	mixed := false
 	endPos := token.NoPosition
@@ -557,6 +1116,10 @@ func (c *checker) checkArgumentList(tobj ir.Type, args []*ir.ArgExpr, fields []i
 				mixed = true
 			} else if argIndex < len(fields) {
 				fieldIndex = argIndex
+			} else if variadic {
+				arg.Value = c.promoteVariadicArg(arg.Value, argIndex)
+				argsRes = append(argsRes, arg)
+				continue
 			} else {
 				break
 			}
@@ -570,7 +1133,7 @@ func (c *checker) checkArgumentList(tobj ir.Type, args []*ir.ArgExpr, fields []i
 					arg.Value = c.finalizeExpr(arg.Value, field.T)
 					if isTypeMismatch(arg.Value.Type(), field.T) {
 						kind := "parameter"
-						if tobj.Kind() == ir.TStruct {
+						if tobj.Kind() == ir.TStruct || tobj.Kind() == ir.TUnion {
 							kind = "field"
 						}
 						if arg.Name != nil {
@@ -587,31 +1150,113 @@ func (c *checker) checkArgumentList(tobj ir.Type, args []*ir.ArgExpr, fields []i
 		named = true
 	}
 
-	if named {
-		if autofill {
-			for fieldIndex, field := range fields {
-				if argsRes[fieldIndex] == nil {
-					arg := &ir.ArgExpr{}
-					arg.Value = ir.NewDefaultInit(field.T)
-					argsRes[fieldIndex] = arg
-				}
+	if named || len(args) < len(fields) {
+		// Missing arguments are filled in with the default values of the fields
+		missing := false
+		for fieldIndex, field := range fields {
+			if argsRes[fieldIndex] != nil {
+				continue
 			}
-		} else if !mixed {
-			for fieldIndex, field := range fields {
-				if argsRes[fieldIndex] == nil {
+			arg := &ir.ArgExpr{}
+			if field.Default != nil {
+				arg.Value = field.Default
+			} else if named && autofill {
+				if !hasDefaultValue(field.T) {
 					c.error(endPos, "no argument for '%s' at position %d", field.Name, fieldIndex+1)
 				}
+				arg.Value = ir.NewDefaultInit(field.T)
+			} else {
+				if named && !mixed {
+					c.error(endPos, "no argument for '%s' at position %d", field.Name, fieldIndex+1)
+				}
+				missing = true
+				continue
 			}
+			argsRes[fieldIndex] = arg
 		}
-	} else if len(args) > len(fields) {
+		if missing && !named {
+			c.error(endPos, "too few arguments (expected %d, got %d)", len(fields), len(args))
+		}
+	} else if len(args) > len(fields) && !variadic {
 		c.error(endPos, "too many arguments (expected %d, got %d)", len(fields), len(args))
-	} else if len(args) < len(fields) {
-		c.error(endPos, "too few arguments (expected %d, got %d)", len(fields), len(args))
 	}
 
 	return argsRes
 }
 
+// A cunion is initialized with at most one argument, which is stored in the named field
+// or in the first field. The argument is always named after it has been checked.
+func (c *checker) checkCUnionArgs(tstruct *ir.StructType, args []*ir.ArgExpr) []*ir.ArgExpr {
+	if len(args) > 1 {
+		c.nodeError(args[1], "cunion '%s' must be initialized with at most one argument (got %d)", tstruct, len(args))
+		return nil
+	}
+	for _, arg := range args {
+		fieldIndex := 0
+		if arg.Name != nil {
+			fieldIndex = tstruct.FieldIndex(arg.Name.Literal)
+			if fieldIndex < 0 {
+				c.nodeError(arg, "unknown named argument '%s'", arg.Name.Literal)
+				return nil
+			}
+		} else if len(tstruct.Fields) == 0 {
+			c.nodeError(arg, "too many arguments (expected 0, got %d)", len(args))
+			return nil
+		} else {
+			arg.Name = ir.NewIdent2(token.Ident, tstruct.Fields[0].Name)
+			arg.Name.SetRange(arg.Pos(), arg.Pos())
+		}
+		field := tstruct.Fields[fieldIndex]
+		arg.Value = c.finalizeExpr(arg.Value, field.T)
+		if isTypeMismatch(arg.Value.Type(), field.T) {
+			c.nodeError(arg, "field '%s' expects type '%s' (got '%s')", field.Name, field.T, arg.Value.Type())
+		}
+	}
+	return args
+}
+
+// Arguments passed to the variadic part of a C function undergo the default argument promotions.
+func (c *checker) promoteVariadicArg(arg ir.Expr, argIndex int) ir.Expr {
+	texpr := arg.Type()
+	if texpr.Kind() == ir.TConstFloat {
+		arg = c.finalizeExpr(arg, ir.TBuiltinFloat64)
+	} else {
+		arg = c.finalizeExpr(arg, nil)
+	}
+	texpr = arg.Type()
+	if isUntyped(texpr) {
+		return arg
+	}
+
+	tbase := ir.ToBaseType(texpr)
+	if tenum, ok := tbase.(*ir.EnumType); ok {
+		tbase = tenum.Backing
+	}
+	var tpromoted ir.Type
+	switch {
+	case ir.IsIntegerType(tbase):
+		if tbase.Kind() < ir.TInt32 {
+			tpromoted = ir.TBuiltinInt32
+		}
+	case tbase.Kind() == ir.TFloat32:
+		tpromoted = ir.TBuiltinFloat64
+	case ir.IsFloatType(tbase) || tbase.Kind() == ir.TPointer:
+	case tbase.Kind() == ir.TFunc && tbase.(*ir.FuncType).C:
+	default:
+		c.nodeError(arg, "variadic argument at position %d cannot have type '%s'", argIndex+1, texpr)
+		arg.SetType(ir.TBuiltinInvalid)
+		return arg
+	}
+
+	if tpromoted != nil {
+		cast := &ir.CastExpr{X: arg}
+		cast.SetRange(arg.Pos(), arg.EndPos())
+		cast.T = tpromoted
+		return cast
+	}
+	return arg
+}
+
 func (c *checker) checkCastExpr(expr *ir.CastExpr) ir.Expr {
 	expr.ToType = c.checkRootTypeExpr(expr.ToType, true)
 	expr.X = c.checkExpr(expr.X)
@@ -657,7 +1302,9 @@ func (c *checker) checkLenExpr(expr *ir.LenExpr) ir.Expr {
 	case *ir.SliceType:
 		expr.T = ir.TBuiltinUSize
 	default:
-		c.error(expr.X.Pos(), "type '%s' does not have a length", tx)
+		if !c.checkNullableDeref(expr.X) {
+			c.error(expr.X.Pos(), "type '%s' does not have a length", tx)
+		}
 		expr.T = ir.TBuiltinInvalid
 	}
 	return expr
@@ -678,6 +1325,207 @@ func (c *checker) checkSizeofExpr(expr *ir.SizeofExpr) ir.Expr {
 	return createIntLit(size, ir.TBuiltinUSize)
 }
 
+// The operands of wrapping_add, checked_add and saturating_add must have the same integer type.
+// The result of checked_add is a tuple with the wrapped sum and a flag which is true if the addition overflowed.
+func (c *checker) checkOverflowExpr(expr *ir.OverflowExpr) ir.Expr {
+	expr.Left = c.checkExpr(expr.Left)
+	expr.Right = c.checkExpr(expr.Right)
+	if tuntyped := checkUntypedExprs(expr.Left, expr.Right); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	if expr.Left.Type().Kind() == ir.TConstInt && expr.Right.Type().Kind() == ir.TConstInt {
+		// The operands get their type from the context like the operands of binary arithmetic,
+		// but the result wraps or saturates in that type
+		if expr.Op == token.CheckedAdd {
+			expr.T = ir.NewTupleType([]ir.Type{ir.TBuiltinConstInt, ir.TBuiltinBool})
+		} else {
+			expr.T = ir.TBuiltinConstInt
+		}
+		return expr
+	}
+
+	return c.finalizeOverflowExpr(expr, nil)
+}
+
+// The untyped operands of checked_add get their type from the first element of a tuple target.
+func (c *checker) finalizeCheckedAdd(expr *ir.OverflowExpr, target ir.Type) ir.Expr {
+	if expr.Left.Type().Kind() != ir.TConstInt || expr.Right.Type().Kind() != ir.TConstInt {
+		return expr
+	}
+	var telem ir.Type
+	if ttuple, ok := ir.ToBaseType(target).(*ir.TupleType); ok && len(ttuple.Elems) == 2 {
+		telem = ttuple.Elems[0]
+	}
+	return c.finalizeOverflowExpr(expr, telem)
+}
+
+// finalizeOverflowExpr sets the type of the operands, which is the integer type telem if they are untyped.
+func (c *checker) finalizeOverflowExpr(expr *ir.OverflowExpr, telem ir.Type) ir.Expr {
+	if telem != nil && !ir.IsIntegerType(telem) {
+		telem = nil
+	}
+	expr.Left = ensureCompatibleType(expr.Left, expr.Right.Type())
+	expr.Right = ensureCompatibleType(expr.Right, expr.Left.Type())
+	expr.Left = c.finalizeExpr(expr.Left, telem)
+	expr.Right = c.finalizeExpr(expr.Right, expr.Left.Type())
+
+	tleft := expr.Left.Type()
+	tright := expr.Right.Type()
+	if isInvalidType(tleft) || isInvalidType(tright) {
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	if !ir.IsIntegerType(tleft) || !ir.IsIntegerType(tright) {
+		c.nodeError(expr, "%s expects integer operands (got '%s' and '%s')", expr.Op, tleft, tright)
+		expr.T = ir.TBuiltinInvalid
+	} else if !tleft.Equals(tright) {
+		c.nodeError(expr, "type mismatch '%s' and '%s'", tleft, tright)
+		expr.T = ir.TBuiltinInvalid
+	} else if expr.Op == token.CheckedAdd {
+		expr.T = ir.NewTupleType([]ir.Type{tleft, ir.TBuiltinBool})
+	} else {
+		expr.T = tleft
+	}
+	return expr
+}
+
+// new(T) returns a reference to a heap allocated value of type T, which is default initialized
+// unless an initial value is given.
+func (c *checker) checkNewExpr(expr *ir.NewExpr) ir.Expr {
+	expr.X = c.checkRootTypeExpr(expr.X, true)
+	if expr.Init != nil {
+		expr.Init = c.checkExpr(expr.Init)
+	}
+	if tuntyped := checkUntypedExprs(expr.X, expr.Init); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	tx := expr.X.Type()
+	if isInvalidType(tx) {
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	if expr.Init != nil {
+		expr.Init = c.finalizeExpr(expr.Init, tx)
+		tinit := expr.Init.Type()
+		if !tx.Equals(tinit) {
+			if !isInvalidType(tinit) {
+				c.nodeError(expr.Init, "type mismatch '%s' and '%s'", tx, tinit)
+			}
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
+		expr.Init = c.foldConstExpr(expr.Init)
+		if isInvalidType(expr.Init.Type()) {
+			expr.T = ir.TBuiltinInvalid
+			return expr
+		}
+	} else if isUntypedBody(tx) {
+		// Wait until it's known whether the type has a default value
+		expr.T = ir.TBuiltinUnknown
+		return expr
+	} else if !hasDefaultValue(tx) {
+		c.nodeError(expr, "value of type '%s' must be initialized", tx)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	expr.T = ir.NewPointerType(tx, false)
+	return expr
+}
+
+// make([T], n) returns a slice of a heap allocated array with n default initialized elements.
+func (c *checker) checkMakeExpr(expr *ir.MakeExpr) ir.Expr {
+	expr.X = c.checkExpr2(expr.X, modeType)
+	expr.Size = c.checkExpr(expr.Size)
+	if tuntyped := checkUntypedExprs(expr.X, expr.Size); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	tx := expr.X.Type()
+	expr.Size = c.finalizeExpr(expr.Size, nil)
+	expr.Size = c.foldConstExpr(expr.Size)
+	tsize := expr.Size.Type()
+	if isInvalidType(tx) || isInvalidType(tsize) {
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	tslice, ok := ir.ToBaseType(tx).(*ir.SliceType)
+	if !ok || tslice.Ptr {
+		c.nodeError(expr.X, "make expects a slice type (got '%s')", tx)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	telem := tslice.Elem
+	if isIncompleteType(telem, nil) {
+		c.nodeError(expr.X, "incomplete type '%s'", telem)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	} else if isUntypedBody(telem) {
+		expr.T = ir.TBuiltinUnknown
+		return expr
+	} else if !hasDefaultValue(telem) {
+		c.nodeError(expr.X, "elements of type '%s' must be initialized", telem)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	if !ir.IsIntegerType(tsize) {
+		c.nodeError(expr.Size, "size expects an integer type (got '%s')", tsize)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	} else if lit := constIntLit(expr.Size); lit != nil && lit.Raw.(*big.Int).Sign() < 0 {
+		c.nodeError(expr.Size, "negative size %s", lit.Raw)
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	expr.T = ir.NewSliceType(telem, false, true)
+	return expr
+}
+
+// delete(p) frees the memory referenced by a pointer or slice, or the environment of a closure. Only memory
+// allocated with new or make, or by an escaping function literal, can be freed.
+func (c *checker) checkDeleteExpr(expr *ir.DeleteExpr) ir.Expr {
+	expr.X = c.checkExpr(expr.X)
+	if tuntyped := checkUntypedExprs(expr.X); tuntyped != nil {
+		expr.T = tuntyped
+		return expr
+	}
+
+	expr.X = c.finalizeExpr(expr.X, nil)
+	tx := expr.X.Type()
+	if isInvalidType(tx) {
+		expr.T = ir.TBuiltinInvalid
+		return expr
+	}
+
+	switch t := ir.ToBaseType(tx).(type) {
+	case *ir.PointerType:
+		expr.T = ir.TBuiltinVoid
+	case *ir.SliceType:
+		if t.Ptr {
+			expr.T = ir.TBuiltinVoid
+		}
+	case *ir.ClosureType:
+		expr.T = ir.TBuiltinVoid
+	}
+
+	if expr.T == nil {
+		c.nodeError(expr.X, "delete expects a reference, slice, raw pointer or closure (got '%s')", tx)
+		expr.T = ir.TBuiltinInvalid
+	}
+	return expr
+}
+
 func createIntLit(val int, t ir.Type) ir.Expr {
 	lit := &ir.BasicLit{Tok: token.Integer, Value: strconv.FormatInt(int64(val), 10)}
 	lit.T = t
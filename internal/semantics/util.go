@@ -1,6 +1,8 @@
 package semantics
 
 import (
+	"math/big"
+
 	"github.com/cjo5/dingo/internal/ir"
 )
 
@@ -79,6 +81,10 @@ func isUntypedBody(t ir.Type) bool {
 	case *ir.BasicType:
 	case *ir.StructType:
 		return !t.TypedBody
+	case *ir.UnionType:
+		return !t.TypedBody
+	case *ir.EnumType:
+		return !t.TypedBody()
 	case *ir.ArrayType:
 		return isUntypedBody(t.Elem)
 	case *ir.SliceType:
@@ -91,6 +97,41 @@ func isUntypedBody(t ir.Type) bool {
 				return true
 			}
 		}
+	case *ir.ClosureType:
+		return isUntypedBody(t.F)
+	case *ir.InterfaceType:
+		return !t.TypedBody
+	case *ir.ResultType:
+		return isUntypedBody(t.T) || isUntypedBody(t.E)
+	case *ir.TupleType:
+		for _, elem := range t.Elems {
+			if isUntypedBody(elem) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isUntypedLayout is similar to isUntypedBody but ignores indirect types.
+func isUntypedLayout(t ir.Type) bool {
+	switch t := ir.ToBaseType(t).(type) {
+	case *ir.StructType:
+		return !t.TypedBody
+	case *ir.UnionType:
+		return !t.TypedBody
+	case *ir.EnumType:
+		return !t.TypedBody()
+	case *ir.ArrayType:
+		return isUntypedLayout(t.Elem)
+	case *ir.ResultType:
+		return isUntypedLayout(t.T) || isUntypedLayout(t.E)
+	case *ir.TupleType:
+		for _, elem := range t.Elems {
+			if isUntypedLayout(elem) {
+				return true
+			}
+		}
 	}
 	return false
 }
@@ -120,14 +161,78 @@ func isIncompleteType(t ir.Type, outer ir.Type) bool {
 		}
 	case *ir.PointerType:
 		incomplete = isIncompleteType(t.Elem, t)
+	case *ir.ResultType:
+		// A result without a value only holds the error
+		if t.T.Kind() != ir.TVoid {
+			incomplete = isIncompleteType(t.T, t)
+		}
+		incomplete = incomplete || isIncompleteType(t.E, t)
+	case *ir.TupleType:
+		for _, elem := range t.Elems {
+			if isIncompleteType(elem, t) {
+				incomplete = true
+				break
+			}
+		}
 	}
 	return incomplete
 }
 
+// findTupleType returns the first tuple in the parameter or return types of t, or nil if there is none.
+// Tuples have no C equivalent and cannot be used by functions with C ABI, not even as a struct field
+// or array element.
+func findTupleType(t *ir.FuncType) ir.Type {
+	for _, param := range t.Params {
+		if ttuple := findNestedTupleType(param.T); ttuple != nil {
+			return ttuple
+		}
+	}
+	return findNestedTupleType(t.Return)
+}
+
+// findNestedTupleType only looks at types which are stored by value in t.
+func findNestedTupleType(t ir.Type) ir.Type {
+	switch tbase := ir.ToBaseType(t).(type) {
+	case *ir.TupleType:
+		return t
+	case *ir.ArrayType:
+		return findNestedTupleType(tbase.Elem)
+	case *ir.StructType:
+		for _, field := range tbase.Fields {
+			if ttuple := findNestedTupleType(field.T); ttuple != nil {
+				return ttuple
+			}
+		}
+	}
+	return nil
+}
+
+func paramTypes(params []*ir.ValDecl) []ir.Expr {
+	var types []ir.Expr
+	for _, param := range params {
+		types = append(types, param.Type)
+	}
+	return types
+}
+
+// toFuncType returns the function type of a function or closure, or nil if t is neither.
+func toFuncType(t ir.Type) *ir.FuncType {
+	switch t := ir.ToBaseType(t).(type) {
+	case *ir.FuncType:
+		return t
+	case *ir.ClosureType:
+		return t.F
+	}
+	return nil
+}
+
 func tryDeref(expr ir.Expr) ir.Expr {
 	var tres ir.Type
 	switch t1 := ir.ToBaseType(expr.Type()).(type) {
 	case *ir.PointerType:
+		if t1.Nullable {
+			break
+		}
 		switch t2 := ir.ToBaseType(t1.Elem).(type) {
 		case *ir.StructType:
 			tres = t2
@@ -149,6 +254,62 @@ func tryDeref(expr ir.Expr) ir.Expr {
 	return expr
 }
 
+// Returns true if a value of type t can be default initialized.
+// References must always point to a valid value, so they have no default value.
+// A result is either a value or an error, so it has no default value either.
+func hasDefaultValue(t ir.Type) bool {
+	switch t := ir.ToBaseType(t).(type) {
+	case *ir.PointerType:
+		return t.Raw || t.Nullable
+	case *ir.ResultType:
+		return false
+	case *ir.InterfaceType:
+		// An interface value always refers to an object
+		return false
+	case *ir.StructType:
+		if t.CUnion {
+			// The storage is zero-initialized
+			return true
+		}
+		for _, field := range t.Fields {
+			if field.Default == nil && !hasDefaultValue(field.T) {
+				return false
+			}
+		}
+	case *ir.ArrayType:
+		return hasDefaultValue(t.Elem)
+	case *ir.TupleType:
+		for _, elem := range t.Elems {
+			if !hasDefaultValue(elem) {
+				return false
+			}
+		}
+	case *ir.EnumType:
+		// The default value is the first member
+		return len(t.Members) > 0
+	case *ir.UnionType:
+		// The default value is the first variant
+		if len(t.Variants) > 0 {
+			for _, field := range t.Variants[0].Fields {
+				if !hasDefaultValue(field.T) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Returns true and reports an error if expr is a nullable reference
+// that has not been checked against null.
+func (c *checker) checkNullableDeref(expr ir.Expr) bool {
+	if ir.IsNullablePointerType(expr.Type()) {
+		c.nodeError(expr, "cannot dereference nullable type '%s'", expr.Type())
+		return true
+	}
+	return false
+}
+
 func ensureCompatibleType(expr ir.Expr, target ir.Type) ir.Expr {
 	if promoted, ok := tryPromoteConstType(expr, target); ok {
 		return promoted
@@ -178,9 +339,11 @@ func tryPromoteConstType(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
 		}
 	} else if texpr.Kind() == ir.TNull {
 		if target == nil {
-			target = ir.NewPointerType(ir.TBuiltinInt8, false)
+			target = ir.NewNullablePointerType(ir.TBuiltinInt8, false)
 		}
-		if isTypeOneOf(target, ir.TPointer, ir.TSlice, ir.TFunc) {
+		if tptr, ok := ir.ToBaseType(target).(*ir.PointerType); ok {
+			promote = tptr.Raw || tptr.Nullable
+		} else if isTypeOneOf(target, ir.TSlice, ir.TFunc, ir.TClosure) {
 			promote = true
 		}
 	}
@@ -189,6 +352,13 @@ func tryPromoteConstType(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
 			lit.SetType(target)
 			return lit, true
 		}
+		if overflow, ok := expr.(*ir.OverflowExpr); ok && ir.IsIntegerType(target) {
+			// The result wraps or saturates in the type of the context
+			overflow.Left = ensureCompatibleType(overflow.Left, target)
+			overflow.Right = ensureCompatibleType(overflow.Right, target)
+			overflow.T = target
+			return overflow, true
+		}
 		cast := &ir.CastExpr{}
 		cast.SetRange(expr.Pos(), expr.EndPos())
 		cast.X = expr
@@ -232,16 +402,22 @@ func tryImplicitCast(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
 		}
 	case *ir.PointerType:
 		if from.Kind() == ir.TUnknown {
-			if isTypeOneOf(to, ir.TPointer, ir.TSlice, ir.TFunc) {
+			if isTypeOneOf(to, ir.TPointer, ir.TSlice, ir.TFunc, ir.TClosure) {
 				cast = true
 			}
-		} else if to, ok := to.(*ir.PointerType); ok {
+		} else if to, ok := to.(*ir.PointerType); ok && from.Raw == to.Raw && (to.Nullable || !from.Nullable) {
 			if !from.ReadOnly && to.ReadOnly {
 				cast = (to.Elem.Kind() == ir.TVoid) || (from.Elem.Equals(to.Elem))
 			} else if from.ReadOnly == to.ReadOnly {
 				cast = (to.Elem.Kind() == ir.TVoid) && (from.Elem.Kind() != ir.TVoid)
+				// A reference can be used where a nullable reference is expected
+				cast = cast || (from.Nullable != to.Nullable && from.Elem.Equals(to.Elem))
 			}
 		}
+	case *ir.FuncType:
+		if to, ok := to.(*ir.ClosureType); ok {
+			cast = !from.C && from.Equals(to.F)
+		}
 	}
 	if cast {
 		cast := &ir.CastExpr{X: expr}
@@ -251,7 +427,7 @@ func tryImplicitCast(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
 	}
 	switch to := to.(type) {
 	case *ir.PointerType:
-		if to.ReadOnly && to.Elem.Equals(from) {
+		if to.ReadOnly && !to.Raw && !to.Nullable && to.Elem.Equals(from) && !isPackedField(expr) {
 			addr := &ir.AddrExpr{
 				X:         expr,
 				Immutable: true,
@@ -263,3 +439,60 @@ func tryImplicitCast(expr ir.Expr, target ir.Type) (ir.Expr, bool) {
 	}
 	return expr, false
 }
+
+// isPackedField returns true if expr is stored in a field of a packed struct. A reference to
+// such a field could be misaligned.
+func isPackedField(expr ir.Expr) bool {
+	switch expr := expr.(type) {
+	case *ir.DotExpr:
+		if tstruct, ok := ir.ToBaseType(expr.X.Type()).(*ir.StructType); ok {
+			return tstruct.Packed || isPackedField(expr.X)
+		}
+	case *ir.IndexExpr:
+		if expr.X.Type().Kind() == ir.TArray {
+			return isPackedField(expr.X)
+		}
+	case *ir.SliceExpr:
+		if expr.X.Type().Kind() == ir.TArray {
+			return isPackedField(expr.X)
+		}
+	}
+	return false
+}
+
+func constIntLit(expr ir.Expr) *ir.BasicLit {
+	switch expr := expr.(type) {
+	case *ir.BasicLit:
+		if _, ok := expr.Raw.(*big.Int); ok {
+			return expr
+		}
+	case *ir.ConstExpr:
+		return constIntLit(expr.X)
+	}
+	return nil
+}
+
+func integerFitsType(val *big.Int, t ir.Type) bool {
+	var min, max *big.Int
+	switch ir.ToBaseType(t).Kind() {
+	case ir.TUInt64, ir.TUSize:
+		min, max = ir.BigIntZero, ir.MaxU64
+	case ir.TUInt32:
+		min, max = ir.BigIntZero, ir.MaxU32
+	case ir.TUInt16:
+		min, max = ir.BigIntZero, ir.MaxU16
+	case ir.TUInt8:
+		min, max = ir.BigIntZero, ir.MaxU8
+	case ir.TInt64:
+		min, max = ir.MinI64, ir.MaxI64
+	case ir.TInt32:
+		min, max = ir.MinI32, ir.MaxI32
+	case ir.TInt16:
+		min, max = ir.MinI16, ir.MaxI16
+	case ir.TInt8:
+		min, max = ir.MinI8, ir.MaxI8
+	default:
+		return true
+	}
+	return val.Cmp(min) >= 0 && val.Cmp(max) <= 0
+}
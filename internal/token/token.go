@@ -29,12 +29,17 @@ const (
 	Lbrack
 	Rbrack
 	Dot
+	Ellipsis // ...
+	DotDot   // ..
 	Comma
 	Semicolon
 	Colon
 	ScopeSep    // ::
 	Placeholder // _
 	Reference   // &
+	Nullable    // ?
+	Fallible    // !
+	Hash        // #
 
 	// Arithmetic
 	Add
@@ -45,6 +50,14 @@ const (
 	Inc
 	Dec
 
+	// Bitwise
+	BitAnd // & (lexed as Reference)
+	BitOr
+	BitXor
+	BitNot
+	Shl
+	Shr
+
 	// Relational
 	Eq
 	Neq
@@ -59,21 +72,39 @@ const (
 	MulAssign
 	DivAssign
 	ModAssign
+	BitAndAssign
+	BitOrAssign
+	BitXorAssign
+	ShlAssign
+	ShrAssign
 
 	keywordBeg
 	If
 	Else
 	Elif
+	Match
 	For
+	In
 	While
 	Return
+	Try
 	Defer
+	Errdefer
 	Continue
 	Break
 	As
 	Lenof
 	Sizeof
+	WrappingAdd
+	CheckedAdd
+	SaturatingAdd
+	New
+	Make
+	Delete
 	Typeof
+	StaticAssert
+	Panic
+	Assert
 	Module
 	Include
 	Import
@@ -82,7 +113,12 @@ const (
 	Val
 	Typealias
 	Func
+	Closure
 	Struct
+	Enum
+	Union
+	CUnion
+	Interface
 	Public
 	Private
 	Extern
@@ -115,12 +151,17 @@ var tokens = [...]string{
 	Lbrack:      "[",
 	Rbrack:      "]",
 	Dot:         ".",
+	Ellipsis:    "...",
+	DotDot:      "..",
 	Comma:       ",",
 	Semicolon:   ";",
 	Colon:       ":",
 	ScopeSep:    "::",
 	Placeholder: "_",
 	Reference:   "&",
+	Nullable:    "?",
+	Fallible:    "!",
+	Hash:        "#",
 
 	Add: "+",
 	Sub: "-",
@@ -130,6 +171,13 @@ var tokens = [...]string{
 	Inc: "++",
 	Dec: "--",
 
+	BitAnd: "&",
+	BitOr:  "|",
+	BitXor: "^",
+	BitNot: "~",
+	Shl:    "<<",
+	Shr:    ">>",
+
 	Eq:   "==",
 	Neq:  "!=",
 	Gt:   ">",
@@ -144,31 +192,55 @@ var tokens = [...]string{
 	DivAssign: "/=",
 	ModAssign: "%=",
 
-	If:        "if",
-	Else:      "else",
-	Elif:      "elif",
-	For:       "for",
-	While:     "while",
-	Return:    "return",
-	Defer:     "defer",
-	Continue:  "continue",
-	Break:     "break",
-	As:        "as",
-	Lenof:     "len",
-	Sizeof:    "sizeof",
-	Typeof:    "typeof",
-	Module:    "module",
-	Include:   "include",
-	Import:    "import",
-	Use:       "use",
-	Var:       "var",
-	Val:       "val",
-	Typealias: "typealias",
-	Func:      "fun",
-	Struct:    "struct",
-	Public:    "pub",
-	Private:   "priv",
-	Extern:    "extern",
+	BitAndAssign: "&=",
+	BitOrAssign:  "|=",
+	BitXorAssign: "^=",
+	ShlAssign:    "<<=",
+	ShrAssign:    ">>=",
+
+	If:            "if",
+	Else:          "else",
+	Elif:          "elif",
+	Match:         "match",
+	For:           "for",
+	In:            "in",
+	While:         "while",
+	Return:        "return",
+	Try:           "try",
+	Defer:         "defer",
+	Errdefer:      "errdefer",
+	Continue:      "continue",
+	Break:         "break",
+	As:            "as",
+	Lenof:         "len",
+	Sizeof:        "sizeof",
+	WrappingAdd:   "wrapping_add",
+	CheckedAdd:    "checked_add",
+	SaturatingAdd: "saturating_add",
+	New:           "new",
+	Make:          "make",
+	Delete:        "delete",
+	Typeof:        "typeof",
+	StaticAssert:  "static_assert",
+	Panic:         "panic",
+	Assert:        "assert",
+	Module:        "module",
+	Include:       "include",
+	Import:        "import",
+	Use:           "use",
+	Var:           "var",
+	Val:           "val",
+	Typealias:     "typealias",
+	Func:          "fun",
+	Closure:       "closure",
+	Struct:        "struct",
+	Enum:          "enum",
+	Union:         "union",
+	CUnion:        "cunion",
+	Interface:     "interface",
+	Public:        "pub",
+	Private:       "priv",
+	Extern:        "extern",
 
 	Land: "and",
 	Lor:  "or",
@@ -221,7 +293,8 @@ func (tok Token) IsKeyword() bool {
 // IsAssignOp returns true if the token represents an assignment operator.
 func (tok Token) IsAssignOp() bool {
 	switch tok {
-	case Assign, AddAssign, SubAssign, MulAssign, DivAssign, ModAssign:
+	case Assign, AddAssign, SubAssign, MulAssign, DivAssign, ModAssign,
+		BitAndAssign, BitOrAssign, BitXorAssign, ShlAssign, ShrAssign:
 		return true
 	}
 	return false
@@ -231,6 +304,7 @@ func (tok Token) IsAssignOp() bool {
 func (tok Token) IsBinaryOp() bool {
 	switch tok {
 	case Add, Sub, Mul, Div, Mod,
+		BitAnd, BitOr, BitXor, Shl, Shr,
 		Eq, Neq, Gt, GtEq, Lt, LtEq,
 		Land, Lor:
 		return true